@@ -20,6 +20,7 @@ import (
 	"os"
 
 	"github.com/danushkaf/aws-nlb-ingress-controller/pkg/controller"
+	"github.com/danushkaf/aws-nlb-ingress-controller/pkg/controller/ingress"
 	"github.com/danushkaf/aws-nlb-ingress-controller/pkg/webhook"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
@@ -31,10 +32,18 @@ import (
 func main() {
 	var metricsAddr string
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
+	flag.StringVar(&ingress.ControllerOptions.Provisioner, "provisioner", ingress.ProvisionerCloudFormation, "The backend that provisions the load balancers, cloudformation, direct or export.")
+	flag.StringVar(&ingress.ControllerOptions.DefaultBackendService, "default-backend-service", "", "The namespace/name:port of the service for requests no path of an ingress matches.")
+	flag.StringVar(&ingress.ControllerOptions.ExportDir, "export-dir", "", "The directory the export provisioner writes the stack templates to, ConfigMaps next to the ingresses when empty.")
 	flag.Parse()
 	logf.SetLogger(logf.ZapLogger(false))
 	log := logf.Log.WithName("entrypoint")
 
+	if err := ingress.ControllerOptions.Validate(); err != nil {
+		log.Error(err, "invalid controller options")
+		os.Exit(1)
+	}
+
 	// Get a config to talk to the apiserver
 	log.Info("setting up client for manager")
 	cfg, err := config.GetConfig()
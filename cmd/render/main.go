@@ -0,0 +1,136 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// render prints the CloudFormation template and reverse proxy resources the controller would create for an ingress,
+// without cluster or AWS access
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/danushkaf/aws-nlb-ingress-controller/pkg/controller/ingress"
+	"github.com/danushkaf/aws-nlb-ingress-controller/pkg/network"
+	"go.uber.org/zap"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	"sigs.k8s.io/yaml"
+)
+
+// Files written to the output directory
+const (
+	templateFile = "template.yaml"
+	nginxFile    = "nginx.conf"
+	proxyFile    = "proxy.yaml"
+)
+
+func main() {
+	var ingressPath, networkPath, outputDir string
+	flag.StringVar(&ingressPath, "ingress", "", "The Ingress manifest to render.")
+	flag.StringVar(&networkPath, "network", "", "The JSON or YAML description of the worker node network.")
+	flag.StringVar(&outputDir, "output-dir", "", "The directory to write the files to, stdout when empty.")
+	flag.StringVar(&ingress.ControllerOptions.DefaultBackendService, "default-backend-service", "", "The namespace/name:port of the controller default backend.")
+	flag.Parse()
+
+	if ingressPath == "" || networkPath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := ingress.ControllerOptions.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(2)
+	}
+
+	if err := run(ingressPath, networkPath, outputDir); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ingressPath string, networkPath string, outputDir string) error {
+	instance := &extensionsv1beta1.Ingress{}
+	if err := readFile(ingressPath, instance); err != nil {
+		return err
+	}
+
+	if instance.Namespace == "" {
+		instance.Namespace = "default"
+	}
+
+	description := &network.Description{}
+	if err := readFile(networkPath, description); err != nil {
+		return err
+	}
+
+	rendered, err := ingress.Render(instance, description, zap.NewNop())
+	if err != nil {
+		return err
+	}
+
+	proxy := bytes.NewBuffer([]byte{})
+	for _, object := range rendered.Objects {
+		b, err := yaml.Marshal(object)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(proxy, "---\n%s", b)
+	}
+
+	files := []struct {
+		name    string
+		content []byte
+	}{
+		{templateFile, rendered.Template},
+		{nginxFile, []byte(rendered.NginxConfig)},
+		{proxyFile, proxy.Bytes()},
+	}
+
+	if outputDir == "" {
+		for _, file := range files {
+			fmt.Printf("# %s\n%s\n", file.name, file.content)
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if err := ioutil.WriteFile(filepath.Join(outputDir, file.name), file.content, 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readFile decodes the JSON or YAML file into v
+func readFile(path string, v interface{}) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if err := yaml.UnmarshalStrict(b, v); err != nil {
+		return fmt.Errorf("unable to decode %s: %s", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,416 @@
+package cloudformation
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	cfn "github.com/awslabs/goformation/v4/cloudformation"
+	"github.com/awslabs/goformation/v4/cloudformation/apigateway"
+)
+
+// Resource names and output keys of the API Gateway REST API fronting the NLB
+const (
+	RestAPIResourceName       = "RestAPI"
+	VPCLinkResourceName       = "VPCLink"
+	StageResourceName         = "Stage"
+	APIResourceResourceName   = "Resource"
+	MethodResourceName        = "Method"
+	DeploymentResourceName    = "Deployment"
+	UsagePlanResourceName     = "UsagePlan"
+	APIKeyResourceName        = "APIKey"
+	UsagePlanKeyResourceName  = "UsagePlanKey"
+	OutputKeyAPIGateway       = "APIGateway"
+	OutputKeyAPIGatewayURL    = "APIGatewayURL"
+	DefaultAPIGatewayStage    = "prod"
+	DefaultAPIGatewayEndpoint = "REGIONAL"
+	proxyPathPart             = "{proxy+}"
+)
+
+var (
+	apiGatewayStageName     = regexp.MustCompile("^[A-Za-z0-9_]+$")
+	apiGatewayEndpointTypes = []string{"REGIONAL", "EDGE", "PRIVATE"}
+	apiGatewayMethods       = []string{"ANY", "DELETE", "GET", "HEAD", "OPTIONS", "PATCH", "POST", "PUT"}
+	apiGatewayQuotaPeriods  = []string{"DAY", "WEEK", "MONTH"}
+)
+
+// APIGatewayConfig describes the REST API created in front of the NLB through a VPC Link
+type APIGatewayConfig struct {
+	Enabled      bool          `json:"enabled"`
+	StageName    string        `json:"stageName,omitempty"`
+	EndpointType string        `json:"endpointType,omitempty"`
+	Resources    []APIResource `json:"resources,omitempty"`
+	UsagePlans   []UsagePlan   `json:"usagePlans,omitempty"`
+}
+
+func oneOf(value string, values []string) bool {
+	for _, v := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the REST API configuration before it is rendered
+func (c *APIGatewayConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if !apiGatewayStageName.MatchString(c.StageName) {
+		return fmt.Errorf("api gateway stage name may only contain alphanumeric characters and underscores, got %q", c.StageName)
+	}
+
+	if !oneOf(c.EndpointType, apiGatewayEndpointTypes) {
+		return fmt.Errorf("api gateway endpoint type must be one of %v, got %q", apiGatewayEndpointTypes, c.EndpointType)
+	}
+
+	for _, resource := range c.Resources {
+		if !strings.HasPrefix(resource.Path, "/") {
+			return fmt.Errorf("api gateway resource path must start with /, got %q", resource.Path)
+		}
+
+		for _, method := range resource.Methods {
+			if !oneOf(method, apiGatewayMethods) {
+				return fmt.Errorf("api gateway resource %s method must be one of %v, got %q", resource.Path, apiGatewayMethods, method)
+			}
+		}
+	}
+
+	names := map[string]bool{}
+	for _, plan := range c.UsagePlans {
+		if plan.PlanName == "" {
+			return fmt.Errorf("api gateway usage plans need a plan_name")
+		}
+
+		if names[plan.PlanName] {
+			return fmt.Errorf("api gateway usage plan %s is defined twice", plan.PlanName)
+		}
+		names[plan.PlanName] = true
+
+		if plan.QuotaLimit > 0 && !oneOf(plan.QuotaPeriod, apiGatewayQuotaPeriods) {
+			return fmt.Errorf("api gateway usage plan %s quota_period must be one of %v, got %q", plan.PlanName, apiGatewayQuotaPeriods, plan.QuotaPeriod)
+		}
+
+		for _, key := range plan.APIKeys {
+			if key.Name == "" {
+				return fmt.Errorf("api gateway usage plan %s api keys need a name", plan.PlanName)
+			}
+		}
+	}
+
+	return nil
+}
+
+// apiKeyRequired reports whether any usage plan hands out API keys, in which case every method requires one
+func (c *APIGatewayConfig) apiKeyRequired() bool {
+	for _, plan := range c.UsagePlans {
+		if len(plan.APIKeys) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// resource returns the configuration of the API resource for path, ANY without parameters when there is none
+func (c *APIGatewayConfig) resource(path string) APIResource {
+	for _, resource := range c.Resources {
+		if normalizeAPIPath(resource.Path) == path {
+			if len(resource.Methods) == 0 {
+				resource.Methods = []string{"ANY"}
+			}
+			return resource
+		}
+	}
+
+	return APIResource{Path: path, Methods: []string{"ANY"}}
+}
+
+// normalizeAPIPath drops the trailing slash of an ingress path, the empty path is the root
+func normalizeAPIPath(path string) string {
+	path = strings.TrimRight(path, "/")
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+func apiResourceName(path string) string {
+	return resourceName(APIResourceResourceName, path, path)
+}
+
+// apiResourceID references the API Gateway resource for path
+func apiResourceID(path string) string {
+	if path == "/" {
+		return cfn.GetAtt(RestAPIResourceName, "RootResourceId")
+	}
+	return cfn.Ref(apiResourceName(path))
+}
+
+func joinAPIPath(parent string, part string) string {
+	if parent == "/" {
+		return "/" + part
+	}
+	return parent + "/" + part
+}
+
+// methodSettingPath encodes path for the ResourcePath of a stage method setting, every slash is encoded as ~1
+// behind a leading slash, e.g. /~1api~1v1, and the root is a single slash
+func methodSettingPath(path string) string {
+	if path == "/" {
+		return path
+	}
+	return "/" + strings.Replace(path, "/", "~1", -1)
+}
+
+func buildAWSApiGatewayRestAPI(endpointType string) *apigateway.RestApi {
+	return &apigateway.RestApi{
+		Name:             cfn.Sub(fmt.Sprintf("${%s}", AWSStackName)),
+		ApiKeySourceType: "HEADER",
+		EndpointConfiguration: &apigateway.RestApi_EndpointConfiguration{
+			Types: []string{endpointType},
+		},
+	}
+}
+
+func buildAWSApiGatewayVPCLink() *apigateway.VpcLink {
+	return &apigateway.VpcLink{
+		Name:       cfn.Sub(fmt.Sprintf("${%s}", AWSStackName)),
+		TargetArns: []string{cfn.Ref(LoadBalancerResourceName)},
+	}
+}
+
+func buildAWSApiGatewayResource(parentPath string, pathPart string) *apigateway.Resource {
+	return &apigateway.Resource{
+		ParentId:  apiResourceID(parentPath),
+		PathPart:  pathPart,
+		RestApiId: cfn.Ref(RestAPIResourceName),
+	}
+}
+
+// buildAWSApiGatewayMethod proxies method on the resource at path to the same path on the NLB through the VPC Link
+func buildAWSApiGatewayMethod(path string, method string, resource APIResource, proxy bool, apiKeyRequired bool) *apigateway.Method {
+	requestParameters := map[string]bool{}
+	integrationParameters := map[string]string{}
+	for location, params := range map[string][]Param{
+		"path":        resource.ProxyPathParams,
+		"querystring": resource.ProxyQueryParams,
+		"header":      resource.ProxyHeaderParams,
+	} {
+		for _, param := range params {
+			requestParameters[fmt.Sprintf("method.request.%s.%s", location, param.Param)] = param.Required
+			integrationParameters[fmt.Sprintf("integration.request.%s.%s", location, param.Param)] = fmt.Sprintf("method.request.%s.%s", location, param.Param)
+		}
+	}
+
+	resourcePath, uri := path, path
+	if proxy {
+		resourcePath = joinAPIPath(path, proxyPathPart)
+		uri = joinAPIPath(path, "{proxy}")
+		requestParameters["method.request.path.proxy"] = true
+		integrationParameters["integration.request.path.proxy"] = "method.request.path.proxy"
+	}
+
+	var cacheKeyParameters []string
+	if resource.CachingEnabled {
+		for parameter := range requestParameters {
+			cacheKeyParameters = append(cacheKeyParameters, parameter)
+		}
+		sort.Strings(cacheKeyParameters)
+	}
+
+	if len(requestParameters) == 0 {
+		requestParameters, integrationParameters = nil, nil
+	}
+
+	return &apigateway.Method{
+		ApiKeyRequired:    apiKeyRequired,
+		AuthorizationType: "NONE",
+		HttpMethod:        method,
+		RequestParameters: requestParameters,
+		ResourceId:        apiResourceID(resourcePath),
+		RestApiId:         cfn.Ref(RestAPIResourceName),
+		Integration: &apigateway.Method_Integration{
+			CacheKeyParameters:    cacheKeyParameters,
+			ConnectionId:          cfn.Ref(VPCLinkResourceName),
+			ConnectionType:        "VPC_LINK",
+			IntegrationHttpMethod: method,
+			RequestParameters:     integrationParameters,
+			Type:                  "HTTP_PROXY",
+			Uri:                   cfn.Sub(fmt.Sprintf("http://${%s.DNSName}%s", LoadBalancerResourceName, uri)),
+		},
+	}
+}
+
+// buildAWSApiGatewayDeployment deploys the methods, dependsOn lists them so the deployment waits for all of them
+func buildAWSApiGatewayDeployment(dependsOn []string) *apigateway.Deployment {
+	return &apigateway.Deployment{
+		RestApiId:                  cfn.Ref(RestAPIResourceName),
+		AWSCloudFormationDependsOn: dependsOn,
+	}
+}
+
+func buildAWSApiGatewayStage(deploymentName string, stageName string, methodSettings []apigateway.Stage_MethodSetting) *apigateway.Stage {
+	stage := &apigateway.Stage{
+		DeploymentId:   cfn.Ref(deploymentName),
+		MethodSettings: methodSettings,
+		RestApiId:      cfn.Ref(RestAPIResourceName),
+		StageName:      stageName,
+	}
+
+	for _, setting := range methodSettings {
+		if setting.CachingEnabled {
+			stage.CacheClusterEnabled = true
+			stage.CacheClusterSize = "0.5"
+		}
+	}
+
+	return stage
+}
+
+func buildAWSApiGatewayUsagePlan(plan UsagePlan) *apigateway.UsagePlan {
+	// Method throttling is keyed by resource path and method, e.g. /api/v1/foobar/GET
+	var throttle map[string]apigateway.UsagePlan_ThrottleSettings
+	for _, method := range plan.MethodThrottlingParameters {
+		if throttle == nil {
+			throttle = map[string]apigateway.UsagePlan_ThrottleSettings{}
+		}
+		throttle[method.Path] = apigateway.UsagePlan_ThrottleSettings{
+			BurstLimit: method.BurstLimit,
+			RateLimit:  method.RateLimit,
+		}
+	}
+
+	usagePlan := &apigateway.UsagePlan{
+		UsagePlanName: plan.PlanName,
+		Description:   plan.Description,
+		ApiStages: []apigateway.UsagePlan_ApiStage{
+			{
+				ApiId:    cfn.Ref(RestAPIResourceName),
+				Stage:    cfn.Ref(StageResourceName),
+				Throttle: throttle,
+			},
+		},
+	}
+
+	if plan.QuotaLimit > 0 {
+		usagePlan.Quota = &apigateway.UsagePlan_QuotaSettings{
+			Limit:  plan.QuotaLimit,
+			Offset: plan.QuotaOffset,
+			Period: plan.QuotaPeriod,
+		}
+	}
+
+	if plan.ThrottleBurstLimit > 0 || plan.ThrottleRateLimit > 0 {
+		usagePlan.Throttle = &apigateway.UsagePlan_ThrottleSettings{
+			BurstLimit: plan.ThrottleBurstLimit,
+			RateLimit:  plan.ThrottleRateLimit,
+		}
+	}
+
+	return usagePlan
+}
+
+func buildAWSApiGatewayAPIKey(key APIKey) *apigateway.ApiKey {
+	return &apigateway.ApiKey{
+		CustomerId:                 key.CustomerID,
+		Enabled:                    true,
+		GenerateDistinctId:         key.GenerateDistinctID,
+		Name:                       key.Name,
+		AWSCloudFormationDependsOn: []string{StageResourceName},
+	}
+}
+
+func buildAWSApiGatewayUsagePlanKey(usagePlanName string, apiKeyName string) *apigateway.UsagePlanKey {
+	return &apigateway.UsagePlanKey{
+		KeyId:       cfn.Ref(apiKeyName),
+		KeyType:     "API_KEY",
+		UsagePlanId: cfn.Ref(usagePlanName),
+	}
+}
+
+// addAPIGatewayResources adds the REST API with a resource per ingress path to the template.
+// Every path also gets a {proxy+} child, ingress paths match everything below them.
+func addAPIGatewayResources(template *cfn.Template, config *APIGatewayConfig, ingressPaths []string) {
+	template.Resources[RestAPIResourceName] = buildAWSApiGatewayRestAPI(config.EndpointType)
+	template.Resources[VPCLinkResourceName] = buildAWSApiGatewayVPCLink()
+
+	paths := map[string]bool{}
+	for _, path := range ingressPaths {
+		paths[normalizeAPIPath(path)] = true
+	}
+
+	methods := []string{}
+	methodSettings := []apigateway.Stage_MethodSetting{}
+	for _, path := range sortedSet(paths) {
+		// Parent resources are shared between paths with a common prefix
+		parent := "/"
+		for _, part := range strings.Split(strings.TrimPrefix(path, "/"), "/") {
+			if part == "" {
+				continue
+			}
+			child := joinAPIPath(parent, part)
+			template.Resources[apiResourceName(child)] = buildAWSApiGatewayResource(parent, part)
+			parent = child
+		}
+
+		proxyPath := joinAPIPath(path, proxyPathPart)
+		template.Resources[apiResourceName(proxyPath)] = buildAWSApiGatewayResource(path, proxyPathPart)
+
+		resource := config.resource(path)
+		for _, method := range resource.Methods {
+			for _, proxy := range []bool{false, true} {
+				resourcePath := path
+				if proxy {
+					resourcePath = proxyPath
+				}
+
+				name := resourceName(MethodResourceName+method, resourcePath, resourcePath)
+				template.Resources[name] = buildAWSApiGatewayMethod(path, method, resource, proxy, config.apiKeyRequired())
+				methods = append(methods, name)
+
+				if resource.CachingEnabled {
+					httpMethod := method
+					if method == "ANY" {
+						httpMethod = "*"
+					}
+					methodSettings = append(methodSettings, apigateway.Stage_MethodSetting{
+						CachingEnabled: true,
+						HttpMethod:     httpMethod,
+						ResourcePath:   methodSettingPath(resourcePath),
+					})
+				}
+			}
+		}
+	}
+
+	// Deployments are immutable, a new logical ID makes CloudFormation deploy the changed API
+	sort.Strings(methods)
+	deploymentName := resourceName(DeploymentResourceName, "", OutputValue(config)+OutputValue(sortedSet(paths)))
+	template.Resources[deploymentName] = buildAWSApiGatewayDeployment(methods)
+	template.Resources[StageResourceName] = buildAWSApiGatewayStage(deploymentName, config.StageName, methodSettings)
+
+	for _, plan := range config.UsagePlans {
+		usagePlanName := resourceName(UsagePlanResourceName, plan.PlanName, plan.PlanName)
+		template.Resources[usagePlanName] = buildAWSApiGatewayUsagePlan(plan)
+
+		for _, key := range plan.APIKeys {
+			apiKeyName := resourceName(APIKeyResourceName, key.Name, plan.PlanName+"/"+key.Name)
+			template.Resources[apiKeyName] = buildAWSApiGatewayAPIKey(key)
+			template.Resources[resourceName(UsagePlanKeyResourceName, key.Name, plan.PlanName+"/"+key.Name)] = buildAWSApiGatewayUsagePlanKey(usagePlanName, apiKeyName)
+		}
+	}
+
+	template.Outputs[OutputKeyAPIGatewayURL] = Output{Value: cfn.Sub(fmt.Sprintf("https://${%s}.execute-api.${%s}.amazonaws.com/%s", RestAPIResourceName, AWSRegion, config.StageName))}
+}
+
+func sortedSet(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,124 @@
+package cloudformation
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/awslabs/goformation/v4/cloudformation/elasticloadbalancingv2"
+)
+
+// attributeValidator checks the value of a single load balancer or target group attribute
+type attributeValidator func(value string) error
+
+func validateBool(value string) error {
+	if value != "true" && value != "false" {
+		return fmt.Errorf("must be true or false, got %q", value)
+	}
+	return nil
+}
+
+func validateString(value string) error {
+	if value == "" {
+		return fmt.Errorf("must not be empty")
+	}
+	return nil
+}
+
+func validateIntRange(min, max int) attributeValidator {
+	return func(value string) error {
+		if i, err := strconv.Atoi(value); err != nil || i < min || i > max {
+			return fmt.Errorf("must be an integer between %d and %d, got %q", min, max, value)
+		}
+		return nil
+	}
+}
+
+func validateOneOf(values ...string) attributeValidator {
+	return func(value string) error {
+		for _, v := range values {
+			if value == v {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of %v, got %q", values, value)
+	}
+}
+
+// LoadBalancerAttributes are the attribute keys supported by network load balancers
+var LoadBalancerAttributes = map[string]attributeValidator{
+	"access_logs.s3.enabled":            validateBool,
+	"access_logs.s3.bucket":             validateString,
+	"access_logs.s3.prefix":             validateString,
+	"deletion_protection.enabled":       validateBool,
+	"load_balancing.cross_zone.enabled": validateBool,
+	"dns_record.client_routing_policy":  validateOneOf("availability_zone_affinity", "partial_availability_zone_affinity", "any_availability_zone"),
+}
+
+// TargetGroupAttributes are the attribute keys supported by network load balancer target groups
+var TargetGroupAttributes = map[string]attributeValidator{
+	"deregistration_delay.timeout_seconds":                validateIntRange(0, 3600),
+	"deregistration_delay.connection_termination.enabled": validateBool,
+	"preserve_client_ip.enabled":                          validateBool,
+	"proxy_protocol_v2.enabled":                           validateBool,
+	"stickiness.enabled":                                  validateBool,
+	"stickiness.type":                                     validateOneOf("source_ip"),
+	"load_balancing.cross_zone.enabled":                   validateOneOf("true", "false", "use_load_balancer_configuration"),
+}
+
+// AttributesConfig holds the attributes set on the load balancer and its target group
+type AttributesConfig struct {
+	LoadBalancer map[string]string `json:"loadBalancer,omitempty"`
+	TargetGroup  map[string]string `json:"targetGroup,omitempty"`
+}
+
+// Validate checks the attributes against the keys and values NLB accepts
+func (c *AttributesConfig) Validate() error {
+	if err := validateAttributes("load balancer", c.LoadBalancer, LoadBalancerAttributes); err != nil {
+		return err
+	}
+
+	return validateAttributes("target group", c.TargetGroup, TargetGroupAttributes)
+}
+
+func validateAttributes(kind string, attributes map[string]string, known map[string]attributeValidator) error {
+	for _, key := range sortedKeys(attributes) {
+		validate, ok := known[key]
+		if !ok {
+			return fmt.Errorf("unknown %s attribute %q", kind, key)
+		}
+
+		if err := validate(attributes[key]); err != nil {
+			return fmt.Errorf("%s attribute %s %s", kind, key, err)
+		}
+	}
+
+	return nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func buildLoadBalancerAttributes(attributes map[string]string) []elasticloadbalancingv2.LoadBalancer_LoadBalancerAttribute {
+	var lbAttributes []elasticloadbalancingv2.LoadBalancer_LoadBalancerAttribute
+	for _, key := range sortedKeys(attributes) {
+		lbAttributes = append(lbAttributes, elasticloadbalancingv2.LoadBalancer_LoadBalancerAttribute{Key: key, Value: attributes[key]})
+	}
+
+	return lbAttributes
+}
+
+func buildTargetGroupAttributes(attributes map[string]string) []elasticloadbalancingv2.TargetGroup_TargetGroupAttribute {
+	var tgAttributes []elasticloadbalancingv2.TargetGroup_TargetGroupAttribute
+	for _, key := range sortedKeys(attributes) {
+		tgAttributes = append(tgAttributes, elasticloadbalancingv2.TargetGroup_TargetGroupAttribute{Key: key, Value: attributes[key]})
+	}
+
+	return tgAttributes
+}
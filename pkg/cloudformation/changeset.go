@@ -0,0 +1,134 @@
+package cloudformation
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/cloudformation/cloudformationiface"
+)
+
+// ChangeSetPrefix prefixes the names of the change sets created by the controller
+const ChangeSetPrefix = "nlb-ingress-"
+
+// PlannedChange is a resource change of a change set.
+// Conditional replacements count as replacements, CloudFormation only knows at execution time.
+type PlannedChange struct {
+	LogicalID    string `json:"logicalId"`
+	ResourceType string `json:"resourceType"`
+	Action       string `json:"action"`
+	Replacement  bool   `json:"replacement"`
+}
+
+// ChangeSetName derives the change set name from the template, so a change set is only created once per template
+func ChangeSetName(templateBody []byte) string {
+	h := fnv.New32a()
+	h.Write(templateBody)
+	return fmt.Sprintf("%s%08x", ChangeSetPrefix, h.Sum32())
+}
+
+// IsChangeSetNotFound tests if the error recieved for DescribeChangeSet denotes the change set does not exist
+func IsChangeSetNotFound(err error) bool {
+	if aErr, ok := err.(awserr.Error); ok {
+		return aErr.Code() == cloudformation.ErrCodeChangeSetNotFoundException
+	}
+	return false
+}
+
+// IsChangeSetPending tests if the change set is still being created
+func IsChangeSetPending(changeSet *cloudformation.DescribeChangeSetOutput) bool {
+	status := aws.StringValue(changeSet.Status)
+	return status == cloudformation.ChangeSetStatusCreatePending || status == cloudformation.ChangeSetStatusCreateInProgress
+}
+
+// IsChangeSetEmpty tests if the change set failed because the template matches the stack
+func IsChangeSetEmpty(changeSet *cloudformation.DescribeChangeSetOutput) bool {
+	reason := aws.StringValue(changeSet.StatusReason)
+	return aws.StringValue(changeSet.Status) == cloudformation.ChangeSetStatusFailed &&
+		(strings.Contains(reason, "didn't contain changes") || strings.Contains(reason, "No updates are to be performed"))
+}
+
+// DescribeChangeSet returns the change set with the changes of all pages
+func DescribeChangeSet(cfnSvc cloudformationiface.CloudFormationAPI, stackName string, changeSetName string) (*cloudformation.DescribeChangeSetOutput, error) {
+	in := &cloudformation.DescribeChangeSetInput{
+		StackName:     aws.String(stackName),
+		ChangeSetName: aws.String(changeSetName),
+	}
+
+	out, err := cfnSvc.DescribeChangeSet(in)
+	if err != nil {
+		return nil, err
+	}
+
+	for next := out.NextToken; next != nil; {
+		in.NextToken = next
+		page, err := cfnSvc.DescribeChangeSet(in)
+		if err != nil {
+			return nil, err
+		}
+
+		out.Changes = append(out.Changes, page.Changes...)
+		next = page.NextToken
+	}
+
+	return out, nil
+}
+
+// DeleteStaleChangeSets deletes the change sets created for earlier templates that were never executed
+func DeleteStaleChangeSets(cfnSvc cloudformationiface.CloudFormationAPI, stackName string, keep string) error {
+	out, err := cfnSvc.ListChangeSets(&cloudformation.ListChangeSetsInput{
+		StackName: aws.String(stackName),
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, summary := range out.Summaries {
+		name := aws.StringValue(summary.ChangeSetName)
+		if name == keep || !strings.HasPrefix(name, ChangeSetPrefix) {
+			continue
+		}
+
+		if _, err := cfnSvc.DeleteChangeSet(&cloudformation.DeleteChangeSetInput{
+			StackName:     aws.String(stackName),
+			ChangeSetName: aws.String(name),
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PlannedChanges lists the resource changes of the change set
+func PlannedChanges(changeSet *cloudformation.DescribeChangeSetOutput) []PlannedChange {
+	changes := []PlannedChange{}
+	for _, change := range changeSet.Changes {
+		if change.ResourceChange == nil {
+			continue
+		}
+
+		replacement := aws.StringValue(change.ResourceChange.Replacement)
+		changes = append(changes, PlannedChange{
+			LogicalID:    aws.StringValue(change.ResourceChange.LogicalResourceId),
+			ResourceType: aws.StringValue(change.ResourceChange.ResourceType),
+			Action:       aws.StringValue(change.ResourceChange.Action),
+			Replacement:  replacement == cloudformation.ReplacementTrue || replacement == cloudformation.ReplacementConditional,
+		})
+	}
+
+	return changes
+}
+
+// HasReplacement tests if any of the changes replaces a resource
+func HasReplacement(changes []PlannedChange) bool {
+	for _, change := range changes {
+		if change.Replacement {
+			return true
+		}
+	}
+	return false
+}
@@ -3,6 +3,8 @@ package cloudformation
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 
 	cfn "github.com/awslabs/goformation/v4/cloudformation"
 	"github.com/awslabs/goformation/v4/cloudformation/ec2"
@@ -19,12 +21,68 @@ const (
 	AWSRegion                        = "AWS::Region"
 	LoadBalancerResourceName         = "LoadBalancer"
 	ListnerResourceName              = "Listener"
+	TLSListenerResourceName          = "TLSListener"
+	ListenerCertificateResourceName  = "ListenerCertificate"
 	SecurityGroupIngressResourceName = "SecurityGroupIngress"
+	HealthCheckIngressResourceName   = "HealthCheckSecurityGroupIngress"
+	IPv6IngressResourceSuffix        = "IPv6"
 	TargetGroupResourceName          = "TargetGroup"
 	OutputKeyIngressRules            = "IngressRules"
 	OutputKeyNLBEndpoint             = "NLBHostName"
+	OutputKeyListeners               = "Listeners"
+	OutputKeyScheme                  = "Scheme"
+	OutputKeyTargetType              = "TargetType"
+	OutputKeyHealthCheck             = "HealthCheck"
+	OutputKeyAttributes              = "Attributes"
+	OutputKeyAddresses               = "Addresses"
+	OutputKeyIPAddressType           = "IPAddressType"
+	OutputKeySourceRanges            = "SourceRanges"
+	OutputKeyDNS                     = "DNS"
+	TargetTypeInstance               = "instance"
+	TargetTypeIP                     = "ip"
 )
 
+// ListenerConfig describes the listeners to create on the NLB
+type ListenerConfig struct {
+	TCP             bool     `json:"tcp"`
+	CertificateARNs []string `json:"certificateArns,omitempty"`
+	SSLPolicy       string   `json:"sslPolicy,omitempty"`
+	ALPNPolicy      string   `json:"alpnPolicy,omitempty"`
+}
+
+// DefaultListenerConfig is used when no listener configuration is provided, a single TCP listener on port 80
+var DefaultListenerConfig = ListenerConfig{TCP: true}
+
+// AddressConfig holds the Elastic IP allocations and private IPv4 addresses pinned to the NLB, one per Availability Zone
+type AddressConfig struct {
+	AllocationIDs        []string `json:"allocationIds,omitempty"`
+	PrivateIPv4Addresses []string `json:"privateIpv4Addresses,omitempty"`
+}
+
+// IngressRulesOutput serializes the rules and default backend of an ingress for the IngressRules output.
+// A single rule without a default backend keeps the paths only value of earlier versions,
+// so stacks created before all rules were honored are not updated for it.
+func IngressRulesOutput(rules []extensionsv1beta1.IngressRule, backend *extensionsv1beta1.IngressBackend) string {
+	if backend == nil && len(rules) == 1 && rules[0].HTTP != nil {
+		return OutputValue(rules[0].HTTP.Paths)
+	}
+
+	return OutputValue(struct {
+		Rules   []extensionsv1beta1.IngressRule   `json:"rules,omitempty"`
+		Backend *extensionsv1beta1.IngressBackend `json:"backend,omitempty"`
+	}{rules, backend})
+}
+
+// OutputValue serializes v to the string stored in the stack outputs, used to detect changes between reconciles
+func OutputValue(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+
+	return string(b)
+}
+
 func buildAWSElasticLoadBalancingV2Listener() *elasticloadbalancingv2.Listener {
 	return &elasticloadbalancingv2.Listener{
 		LoadBalancerArn: cfn.Ref(LoadBalancerResourceName),
@@ -39,33 +97,93 @@ func buildAWSElasticLoadBalancingV2Listener() *elasticloadbalancingv2.Listener {
 	}
 }
 
-func buildAWSElasticLoadBalancingV2LoadBalancer(subnetIDs []string) *elasticloadbalancingv2.LoadBalancer {
-	return &elasticloadbalancingv2.LoadBalancer{
-		IpAddressType: "ipv4",
-		Scheme:        "internal",
-		Subnets:       subnetIDs,
-		Tags: []tags.Tag{
-			{
-				Key:   "com.github.amazon-nlb-ingress-controller/stack",
-				Value: cfn.Ref(AWSStackName),
+func buildAWSElasticLoadBalancingV2TLSListener(certificateARN string, sslPolicy string, alpnPolicy string) *Listener {
+	listener := &Listener{
+		Listener: elasticloadbalancingv2.Listener{
+			LoadBalancerArn: cfn.Ref(LoadBalancerResourceName),
+			Protocol:        "TLS",
+			Port:            443,
+			SslPolicy:       sslPolicy,
+			Certificates: []elasticloadbalancingv2.Listener_Certificate{
+				{CertificateArn: certificateARN},
+			},
+			DefaultActions: []elasticloadbalancingv2.Listener_Action{
+				elasticloadbalancingv2.Listener_Action{
+					TargetGroupArn: cfn.Ref(TargetGroupResourceName),
+					Type:           "forward",
+				},
 			},
 		},
-		Type: "network",
+	}
+
+	if alpnPolicy != "" {
+		listener.AlpnPolicy = []string{alpnPolicy}
+	}
+
+	return listener
+}
+
+func buildAWSElasticLoadBalancingV2ListenerCertificate(certificateARNs []string) *elasticloadbalancingv2.ListenerCertificate {
+	certificates := make([]elasticloadbalancingv2.ListenerCertificate_Certificate, len(certificateARNs))
+	for i, certificateARN := range certificateARNs {
+		certificates[i] = elasticloadbalancingv2.ListenerCertificate_Certificate{CertificateArn: certificateARN}
+	}
+
+	return &elasticloadbalancingv2.ListenerCertificate{
+		ListenerArn:  cfn.Ref(TLSListenerResourceName),
+		Certificates: certificates,
 	}
 }
 
-func buildAWSElasticLoadBalancingV2TargetGroup(vpcID string, instanceIDs []string, nodePort int, dependsOn []string) *elasticloadbalancingv2.TargetGroup {
-	targets := make([]elasticloadbalancingv2.TargetGroup_TargetDescription, len(instanceIDs))
-	for i, instanceID := range instanceIDs {
-		targets[i] = elasticloadbalancingv2.TargetGroup_TargetDescription{Id: instanceID}
+func buildAWSElasticLoadBalancingV2LoadBalancer(scheme string, ipAddressType string, subnetIDs []string, subnetMappings []network.SubnetMapping) *LoadBalancer {
+	loadBalancer := &LoadBalancer{
+		LoadBalancer: elasticloadbalancingv2.LoadBalancer{
+			IpAddressType: ipAddressType,
+			Scheme:        scheme,
+			Tags: []tags.Tag{
+				{
+					Key:   "com.github.amazon-nlb-ingress-controller/stack",
+					Value: cfn.Ref(AWSStackName),
+				},
+			},
+			Type: "network",
+		},
+	}
+
+	// Subnets and SubnetMappings are mutually exclusive
+	if len(subnetMappings) == 0 {
+		loadBalancer.Subnets = subnetIDs
+		return loadBalancer
+	}
+
+	for _, mapping := range subnetMappings {
+		loadBalancer.SubnetMappings = append(loadBalancer.SubnetMappings, LoadBalancerSubnetMapping{
+			AllocationId:       mapping.AllocationID,
+			PrivateIPv4Address: mapping.PrivateIPv4Address,
+			SubnetId:           mapping.SubnetID,
+		})
+	}
+
+	return loadBalancer
+}
+
+func buildAWSElasticLoadBalancingV2TargetGroup(vpcID string, targetType string, instanceIDs []string, nodePort int, healthCheck *HealthCheckConfig, dependsOn []string) *elasticloadbalancingv2.TargetGroup {
+	// ip targets are the proxy pods, they come and go with the deployment and are registered by the controller
+	var targets []elasticloadbalancingv2.TargetGroup_TargetDescription
+	if targetType == TargetTypeInstance {
+		targets = make([]elasticloadbalancingv2.TargetGroup_TargetDescription, len(instanceIDs))
+		for i, instanceID := range instanceIDs {
+			targets[i] = elasticloadbalancingv2.TargetGroup_TargetDescription{Id: instanceID}
+		}
 	}
 
 	return &elasticloadbalancingv2.TargetGroup{
-		HealthCheckIntervalSeconds: 30,
-		HealthCheckPort:            "traffic-port",
-		HealthCheckProtocol:        "TCP",
-		HealthCheckTimeoutSeconds:  10,
-		HealthyThresholdCount:      3,
+		HealthCheckIntervalSeconds: healthCheck.IntervalSeconds,
+		HealthCheckPath:            healthCheck.Path,
+		HealthCheckPort:            healthCheck.Port,
+		HealthCheckProtocol:        healthCheck.Protocol,
+		HealthCheckTimeoutSeconds:  healthCheck.TimeoutSeconds,
+		HealthyThresholdCount:      healthCheck.HealthyThresholdCount,
 		Port:                       nodePort,
 		Protocol:                   "TCP",
 		Tags: []tags.Tag{
@@ -74,9 +192,9 @@ func buildAWSElasticLoadBalancingV2TargetGroup(vpcID string, instanceIDs []strin
 				Value: cfn.Ref(AWSStackName),
 			},
 		},
-		TargetType:              "instance",
+		TargetType:              targetType,
 		Targets:                 targets,
-		UnhealthyThresholdCount: 3,
+		UnhealthyThresholdCount: healthCheck.UnhealthyThresholdCount,
 		VpcId:                   vpcID,
 	}
 }
@@ -96,42 +214,283 @@ func buildAWSEC2SecurityGroupIngresses(securityGroupIds []string, cidr string, n
 	return sgIngresses
 }
 
+func buildAWSEC2SecurityGroupIPv6Ingresses(securityGroupIds []string, cidrIpv6 string, nodePort int) []*ec2.SecurityGroupIngress {
+	sgIngresses := make([]*ec2.SecurityGroupIngress, len(securityGroupIds))
+	for i, sgID := range securityGroupIds {
+		sgIngresses[i] = &ec2.SecurityGroupIngress{
+			IpProtocol: "TCP",
+			CidrIpv6:   cidrIpv6,
+			FromPort:   nodePort,
+			ToPort:     nodePort,
+			GroupId:    sgID,
+		}
+	}
+
+	return sgIngresses
+}
+
+// addSecurityGroupIngresses adds an ingress rule for port on every security group for each source range,
+// IPv6 ranges get their own resources
+func addSecurityGroupIngresses(template *cfn.Template, resourceName string, securityGroupIds []string, sourceRanges []string, port int) {
+	ipv4, ipv6 := 0, 0
+	for _, sourceRange := range sourceRanges {
+		if strings.Contains(sourceRange, ":") {
+			for _, sgI := range buildAWSEC2SecurityGroupIPv6Ingresses(securityGroupIds, sourceRange, port) {
+				template.Resources[fmt.Sprintf("%s%s%d", resourceName, IPv6IngressResourceSuffix, ipv6)] = sgI
+				ipv6++
+			}
+			continue
+		}
+
+		for _, sgI := range buildAWSEC2SecurityGroupIngresses(securityGroupIds, sourceRange, port) {
+			template.Resources[fmt.Sprintf("%s%d", resourceName, ipv4)] = sgI
+			ipv4++
+		}
+	}
+}
+
 //TemplateConfig is the structure of configuration used to provide data to build the cf template
 type TemplateConfig struct {
-	Network  *network.Network
-	Rule     extensionsv1beta1.IngressRule
+	Network *network.Network
+	Rules   []extensionsv1beta1.IngressRule
+	// Backend is the default backend of the ingress, for requests no rule matches
+	Backend *extensionsv1beta1.IngressBackend
+	// NodePort is the port targets receive traffic on, the proxy container port for ip targets
 	NodePort int
+	// HealthCheckNodePort is the port of the proxy healthz server, the proxy container port for ip targets
+	HealthCheckNodePort int
+	Listeners           *ListenerConfig
+	Scheme              string
+	TargetType          string
+	HealthCheck         *HealthCheckConfig
+	Attributes          *AttributesConfig
+	Addresses           *AddressConfig
+	IPAddressType       string
+	// TLS are the hosts and secrets the proxy terminates TLS for, behind a TCP listener on port 443
+	TLS []extensionsv1beta1.IngressTLS
+	// TLSNodePort is the port targets receive the TLS traffic on, the proxy TLS container port for ip targets
+	TLSNodePort int
+	// SourceRanges are the CIDRs allowed to reach the targets, all CIDR blocks of the VPC when empty
+	SourceRanges    []string
+	DNS             *DNSConfig
+	EndpointService *EndpointServiceConfig
+	APIGateway      *APIGatewayConfig
+	Monitoring      *MonitoringConfig
+}
+
+// WithDefaults returns a copy of the config with the defaults filled in for the settings that are not set
+func (cfg *TemplateConfig) WithDefaults() *TemplateConfig {
+	c := *cfg
+	if c.TargetType == "" {
+		c.TargetType = TargetTypeInstance
+	}
+
+	if c.HealthCheck == nil {
+		c.HealthCheck = &DefaultHealthCheckConfig
+	}
+
+	if c.Attributes == nil {
+		c.Attributes = &AttributesConfig{}
+	}
+
+	if c.Listeners == nil {
+		c.Listeners = &DefaultListenerConfig
+	}
+
+	if c.IPAddressType == "" {
+		c.IPAddressType = network.IPAddressTypeIPv4
+	}
+
+	if c.Scheme == "" {
+		c.Scheme = network.SchemeInternal
+	}
+
+	if c.DNS == nil {
+		c.DNS = &DNSConfig{}
+	}
+
+	if c.EndpointService == nil {
+		c.EndpointService = &EndpointServiceConfig{}
+	}
+
+	if c.APIGateway == nil {
+		c.APIGateway = &APIGatewayConfig{}
+	}
+
+	if c.Monitoring == nil {
+		c.Monitoring = &MonitoringConfig{}
+	}
+
+	if c.Addresses == nil {
+		c.Addresses = &AddressConfig{}
+	}
+
+	return &c
+}
+
+// TargetHealthCheck is the health check of the target group, on the proxy healthz port unless the port is configured
+func (cfg *TemplateConfig) TargetHealthCheck() *HealthCheckConfig {
+	healthCheck := *cfg.HealthCheck
+	if healthCheck.Port == "" {
+		healthCheck.Port = strconv.Itoa(cfg.HealthCheckNodePort)
+	}
+
+	return &healthCheck
+}
+
+// VpcRanges are the CIDR blocks of the vpc the load balancer nodes send traffic from
+func (cfg *TemplateConfig) VpcRanges() []string {
+	vpcRanges := network.VpcCidrBlocks(cfg.Network.Vpc)
+	if cfg.IPAddressType == network.IPAddressTypeDualStack {
+		vpcRanges = append(vpcRanges, network.VpcIPv6CidrBlocks(cfg.Network.Vpc)...)
+	}
+
+	return vpcRanges
+}
+
+// Paths are the paths of all rules, in order and without duplicates. The default backend serves the root path.
+func (cfg *TemplateConfig) Paths() []string {
+	paths, seen := []string{}, map[string]bool{}
+	add := func(path string) {
+		if path == "" {
+			path = "/"
+		}
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+
+	for _, rule := range cfg.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, path := range rule.HTTP.Paths {
+			add(path.Path)
+		}
+	}
+
+	if cfg.Backend != nil {
+		add("/")
+	}
+
+	return paths
+}
+
+// Outputs are the values recording the configuration the load balancer was built with, used to detect changes between reconciles.
+// The config must have its defaults filled in.
+func (cfg *TemplateConfig) Outputs() map[string]string {
+	sourceRanges := cfg.SourceRanges
+	if sourceRanges == nil {
+		sourceRanges = []string{}
+	}
+
+	outputs := map[string]string{
+		OutputKeyIngressRules:    IngressRulesOutput(cfg.Rules, cfg.Backend),
+		OutputKeyListeners:       OutputValue(cfg.Listeners),
+		OutputKeyScheme:          cfg.Scheme,
+		OutputKeyTargetType:      cfg.TargetType,
+		OutputKeyHealthCheck:     OutputValue(cfg.HealthCheck),
+		OutputKeyAttributes:      OutputValue(cfg.Attributes),
+		OutputKeyAddresses:       OutputValue(cfg.Addresses),
+		OutputKeyIPAddressType:   cfg.IPAddressType,
+		OutputKeySourceRanges:    OutputValue(sourceRanges),
+		OutputKeyDNS:             OutputValue(cfg.DNS),
+		OutputKeyEndpointService: OutputValue(cfg.EndpointService),
+		OutputKeyAPIGateway:      OutputValue(cfg.APIGateway),
+		OutputKeyMonitoring:      OutputValue(cfg.Monitoring),
+	}
+
+	// CloudFormation rejects empty outputs, stacks without the output have no TLS
+	if tls := TLSOutput(cfg.TLS); tls != "" {
+		outputs[OutputKeyTLS] = tls
+	}
+
+	return outputs
 }
 
 // BuildNLBTemplateFromIngressRule generates the cloudformation template according to the config provided
 func BuildNLBTemplateFromIngressRule(cfg *TemplateConfig) *cfn.Template {
 	template := cfn.NewTemplate()
+	cfg = cfg.WithDefaults()
 
-	targetGroup := buildAWSElasticLoadBalancingV2TargetGroup(*cfg.Network.Vpc.VpcId, cfg.Network.InstanceIDs, cfg.NodePort, []string{LoadBalancerResourceName})
+	targetHealthCheck := cfg.TargetHealthCheck()
+	targetGroup := buildAWSElasticLoadBalancingV2TargetGroup(*cfg.Network.Vpc.VpcId, cfg.TargetType, cfg.Network.InstanceIDs, cfg.NodePort, targetHealthCheck, []string{LoadBalancerResourceName})
 	template.Resources[TargetGroupResourceName] = targetGroup
+	targetGroup.TargetGroupAttributes = buildTargetGroupAttributes(cfg.Attributes.TargetGroup)
+
+	listeners := cfg.Listeners
+	if listeners.TCP {
+		listener := buildAWSElasticLoadBalancingV2Listener()
+		template.Resources[ListnerResourceName] = listener
+	}
+
+	if len(listeners.CertificateARNs) > 0 {
+		// A listener takes a single default certificate, the rest are served through SNI
+		tlsListener := buildAWSElasticLoadBalancingV2TLSListener(listeners.CertificateARNs[0], listeners.SSLPolicy, listeners.ALPNPolicy)
+		template.Resources[TLSListenerResourceName] = tlsListener
+
+		if len(listeners.CertificateARNs) > 1 {
+			listenerCertificate := buildAWSElasticLoadBalancingV2ListenerCertificate(listeners.CertificateARNs[1:])
+			template.Resources[ListenerCertificateResourceName] = listenerCertificate
+		}
+	}
+
+	vpcRanges := cfg.VpcRanges()
+	sourceRanges := cfg.SourceRanges
+	if len(sourceRanges) == 0 {
+		sourceRanges = vpcRanges
+	}
+
+	addSecurityGroupIngresses(template, SecurityGroupIngressResourceName, cfg.Network.SecurityGroupIDs, sourceRanges, cfg.NodePort)
 
-	listener := buildAWSElasticLoadBalancingV2Listener()
-	template.Resources[ListnerResourceName] = listener
+	// Health checks come from the NLB nodes inside the VPC, not from the source ranges
+	if healthCheckPort, err := strconv.Atoi(targetHealthCheck.Port); err == nil && healthCheckPort != cfg.NodePort {
+		addSecurityGroupIngresses(template, HealthCheckIngressResourceName, cfg.Network.SecurityGroupIDs, vpcRanges, healthCheckPort)
+	}
 
-	securityGroupIngresses := buildAWSEC2SecurityGroupIngresses(cfg.Network.SecurityGroupIDs, *cfg.Network.Vpc.CidrBlock, cfg.NodePort)
-	for i, sgI := range securityGroupIngresses {
-		template.Resources[fmt.Sprintf("%s%d", SecurityGroupIngressResourceName, i)] = sgI
+	if len(cfg.TLS) > 0 {
+		addTLSResources(template, cfg, targetHealthCheck, sourceRanges)
 	}
 
-	loadBalancer := buildAWSElasticLoadBalancingV2LoadBalancer(cfg.Network.SubnetIDs)
+	loadBalancer := buildAWSElasticLoadBalancingV2LoadBalancer(cfg.Scheme, cfg.IPAddressType, cfg.Network.SubnetIDs, cfg.Network.SubnetMappings)
+	loadBalancer.LoadBalancerAttributes = buildLoadBalancerAttributes(cfg.Attributes.LoadBalancer)
 	template.Resources[LoadBalancerResourceName] = loadBalancer
 
-	rulePaths, err := json.Marshal(cfg.Rule.IngressRuleValue.HTTP.Paths)
-	var rulePathsStr string
-	if err != nil {
-		rulePathsStr = ""
-	} else {
-		rulePathsStr = string(rulePaths)
+	// Records share the lifecycle of the stack, dualstack load balancers also get AAAA records
+	dns := cfg.DNS
+	if dns.HostedZoneID != "" {
+		for _, host := range dns.Hosts {
+			template.Resources[recordSetResourceName(host, "A")] = buildAWSRoute53RecordSet(dns.HostedZoneID, host, "A")
+			if cfg.IPAddressType == network.IPAddressTypeDualStack {
+				template.Resources[recordSetResourceName(host, "AAAA")] = buildAWSRoute53RecordSet(dns.HostedZoneID, host, "AAAA")
+			}
+		}
 	}
 
 	template.Outputs = map[string]interface{}{
-		OutputKeyNLBEndpoint:  Output{Value: cfn.GetAtt(LoadBalancerResourceName, "DNSName")},
-		OutputKeyIngressRules: Output{Value: rulePathsStr},
+		OutputKeyNLBEndpoint: Output{Value: cfn.GetAtt(LoadBalancerResourceName, "DNSName")},
+	}
+	for key, value := range cfg.Outputs() {
+		template.Outputs[key] = Output{Value: value}
+	}
+
+	endpointService, apiGateway, monitoring := cfg.EndpointService, cfg.APIGateway, cfg.Monitoring
+
+	addMonitoringResources(template, monitoring)
+
+	if apiGateway.Enabled {
+		addAPIGatewayResources(template, apiGateway, cfg.Paths())
+	}
+
+	if endpointService.Enabled {
+		template.Resources[EndpointServiceResourceName] = buildAWSEC2VPCEndpointService(endpointService.AcceptanceRequired)
+		if len(endpointService.AllowedPrincipals) > 0 {
+			template.Resources[EndpointServicePermissionsResourceName] = buildAWSEC2VPCEndpointServicePermissions(endpointService.AllowedPrincipals)
+		}
+
+		template.Outputs[OutputKeyEndpointServiceID] = Output{Value: cfn.Ref(EndpointServiceResourceName)}
+		template.Outputs[OutputKeyEndpointServiceName] = Output{Value: cfn.Sub(fmt.Sprintf("com.amazonaws.vpce.${%s}.${%s}", AWSRegion, EndpointServiceResourceName))}
 	}
 
 	return template
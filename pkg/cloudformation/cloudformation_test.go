@@ -4,11 +4,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	cfn "github.com/awslabs/goformation/v4/cloudformation"
+	"github.com/awslabs/goformation/v4/cloudformation/apigateway"
+	"github.com/awslabs/goformation/v4/cloudformation/elasticloadbalancingv2"
 	"github.com/danushkaf/aws-nlb-ingress-controller/pkg/network"
 	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
 	"k8s.io/apimachinery/pkg/util/intstr"
@@ -42,15 +47,591 @@ func getIngressRulesJsonStr() string {
 }
 
 func TestBuildApiGatewayTemplateFromIngressRule(t *testing.T) {
+	monitoringConfig := &MonitoringConfig{
+		Alarms:                 true,
+		Dashboard:              true,
+		AlarmActions:           []string{"arn:aws:sns:us-east-1:123456789012:alerts"},
+		UnhealthyHostThreshold: 1,
+		MinHealthyHosts:        2,
+		TCPResetThreshold:      100,
+		PeriodSeconds:          60,
+		EvaluationPeriods:      3,
+	}
+
 	tests := []struct {
 		name string
 		args *TemplateConfig
 		want *cfn.Template
 	}{
 		{
-			name: "generates template",
+			name: "generates template",
+			args: &TemplateConfig{
+				Rules: []extensionsv1beta1.IngressRule{{
+					IngressRuleValue: extensionsv1beta1.IngressRuleValue{
+						HTTP: &extensionsv1beta1.HTTPIngressRuleValue{
+							Paths: []extensionsv1beta1.HTTPIngressPath{
+								{
+									Path: "/api/v1/foobar",
+									Backend: extensionsv1beta1.IngressBackend{
+										ServiceName: "foobar-service",
+										ServicePort: intstr.FromInt(8080),
+									},
+								},
+							},
+						},
+					},
+				}},
+				Network: &network.Network{
+					Vpc: &ec2.Vpc{
+						VpcId:     aws.String("foo"),
+						CidrBlock: aws.String("10.0.0.0/24"),
+					},
+					InstanceIDs:      []string{"i-foo"},
+					SubnetIDs:        []string{"sn-foo"},
+					SecurityGroupIDs: []string{"sg-foo"},
+				},
+				NodePort: 30123,
+			},
+			want: &cfn.Template{
+				Resources: cfn.Resources{
+					"TargetGroup":           buildAWSElasticLoadBalancingV2TargetGroup("foo", "instance", []string{"i-foo"}, 30123, &DefaultHealthCheckConfig, []string{"LoadBalancer"}),
+					"Listener":              buildAWSElasticLoadBalancingV2Listener(),
+					"SecurityGroupIngress0": buildAWSEC2SecurityGroupIngresses([]string{"sg-foo"}, "10.0.0.0/24", 30123)[0],
+					"LoadBalancer":          buildAWSElasticLoadBalancingV2LoadBalancer("internal", "ipv4", []string{"sn-foo"}, nil),
+				},
+				Outputs: map[string]interface{}{
+					"NLBHostName":     Output{Value: cfn.GetAtt("LoadBalancer", "DNSName")},
+					"IngressRules":    Output{Value: getIngressRulesJsonStr()},
+					"Listeners":       Output{Value: `{"tcp":true}`},
+					"Scheme":          Output{Value: "internal"},
+					"TargetType":      Output{Value: "instance"},
+					"HealthCheck":     Output{Value: `{"protocol":"TCP","port":"traffic-port","intervalSeconds":30,"timeoutSeconds":10,"healthyThresholdCount":3,"unhealthyThresholdCount":3}`},
+					"Attributes":      Output{Value: `{}`},
+					"Addresses":       Output{Value: `{}`},
+					"IPAddressType":   Output{Value: "ipv4"},
+					"SourceRanges":    Output{Value: `[]`},
+					"DNS":             Output{Value: `{}`},
+					"EndpointService": Output{Value: `{"enabled":false}`},
+					"APIGateway":      Output{Value: `{"enabled":false}`},
+					"Monitoring":      Output{Value: `{"alarms":false}`},
+				},
+			},
+		},
+		{
+			name: "generates template with a TCP listener on 443 for the proxy to terminate TLS",
+			args: &TemplateConfig{
+				Backend: &extensionsv1beta1.IngressBackend{
+					ServiceName: "foobar-service",
+					ServicePort: intstr.FromInt(8080),
+				},
+				TLS: []extensionsv1beta1.IngressTLS{{Hosts: []string{"foo.example.com"}, SecretName: "foo-tls"}},
+				Network: &network.Network{
+					Vpc: &ec2.Vpc{
+						VpcId:     aws.String("foo"),
+						CidrBlock: aws.String("10.0.0.0/24"),
+					},
+					InstanceIDs:      []string{"i-foo"},
+					SubnetIDs:        []string{"sn-foo"},
+					SecurityGroupIDs: []string{"sg-foo"},
+				},
+				NodePort:    30123,
+				TLSNodePort: 30443,
+			},
+			want: &cfn.Template{
+				Resources: cfn.Resources{
+					"TargetGroup":              buildAWSElasticLoadBalancingV2TargetGroup("foo", "instance", []string{"i-foo"}, 30123, &DefaultHealthCheckConfig, []string{"LoadBalancer"}),
+					"Listener":                 buildAWSElasticLoadBalancingV2Listener(),
+					"SecurityGroupIngress0":    buildAWSEC2SecurityGroupIngresses([]string{"sg-foo"}, "10.0.0.0/24", 30123)[0],
+					"TLSTargetGroup":           buildAWSElasticLoadBalancingV2TargetGroup("foo", "instance", []string{"i-foo"}, 30443, &DefaultHealthCheckConfig, []string{"LoadBalancer"}),
+					"TLSPassthroughListener":   buildAWSElasticLoadBalancingV2TLSPassthroughListener(),
+					"TLSSecurityGroupIngress0": buildAWSEC2SecurityGroupIngresses([]string{"sg-foo"}, "10.0.0.0/24", 30443)[0],
+					"LoadBalancer":             buildAWSElasticLoadBalancingV2LoadBalancer("internal", "ipv4", []string{"sn-foo"}, nil),
+				},
+				Outputs: map[string]interface{}{
+					"NLBHostName":     Output{Value: cfn.GetAtt("LoadBalancer", "DNSName")},
+					"IngressRules":    Output{Value: `{"backend":{"serviceName":"foobar-service","servicePort":8080}}`},
+					"TLS":             Output{Value: `[{"hosts":["foo.example.com"],"secretName":"foo-tls"}]`},
+					"Listeners":       Output{Value: `{"tcp":true}`},
+					"Scheme":          Output{Value: "internal"},
+					"TargetType":      Output{Value: "instance"},
+					"HealthCheck":     Output{Value: `{"protocol":"TCP","port":"traffic-port","intervalSeconds":30,"timeoutSeconds":10,"healthyThresholdCount":3,"unhealthyThresholdCount":3}`},
+					"Attributes":      Output{Value: `{}`},
+					"Addresses":       Output{Value: `{}`},
+					"IPAddressType":   Output{Value: "ipv4"},
+					"SourceRanges":    Output{Value: `[]`},
+					"DNS":             Output{Value: `{}`},
+					"EndpointService": Output{Value: `{"enabled":false}`},
+					"APIGateway":      Output{Value: `{"enabled":false}`},
+					"Monitoring":      Output{Value: `{"alarms":false}`},
+				},
+			},
+		},
+		{
+			name: "generates template with TLS listener, SNI certificates and ip targets",
+			args: &TemplateConfig{
+				Rules: []extensionsv1beta1.IngressRule{{
+					IngressRuleValue: extensionsv1beta1.IngressRuleValue{
+						HTTP: &extensionsv1beta1.HTTPIngressRuleValue{
+							Paths: []extensionsv1beta1.HTTPIngressPath{
+								{
+									Path: "/api/v1/foobar",
+									Backend: extensionsv1beta1.IngressBackend{
+										ServiceName: "foobar-service",
+										ServicePort: intstr.FromInt(8080),
+									},
+								},
+							},
+						},
+					},
+				}},
+				Network: &network.Network{
+					Vpc: &ec2.Vpc{
+						VpcId:     aws.String("foo"),
+						CidrBlock: aws.String("10.0.0.0/24"),
+					},
+					InstanceIDs:      []string{"i-foo"},
+					SubnetIDs:        []string{"sn-foo"},
+					SecurityGroupIDs: []string{"sg-foo"},
+				},
+				NodePort: 30123,
+				Listeners: &ListenerConfig{
+					CertificateARNs: []string{"arn:foo", "arn:bar"},
+					SSLPolicy:       "ELBSecurityPolicy-2016-08",
+					ALPNPolicy:      "HTTP2Preferred",
+				},
+				Scheme:     "internet-facing",
+				TargetType: "ip",
+			},
+			want: &cfn.Template{
+				Resources: cfn.Resources{
+					"TargetGroup":           buildAWSElasticLoadBalancingV2TargetGroup("foo", "ip", []string{"i-foo"}, 30123, &DefaultHealthCheckConfig, []string{"LoadBalancer"}),
+					"TLSListener":           buildAWSElasticLoadBalancingV2TLSListener("arn:foo", "ELBSecurityPolicy-2016-08", "HTTP2Preferred"),
+					"ListenerCertificate":   buildAWSElasticLoadBalancingV2ListenerCertificate([]string{"arn:bar"}),
+					"SecurityGroupIngress0": buildAWSEC2SecurityGroupIngresses([]string{"sg-foo"}, "10.0.0.0/24", 30123)[0],
+					"LoadBalancer":          buildAWSElasticLoadBalancingV2LoadBalancer("internet-facing", "ipv4", []string{"sn-foo"}, nil),
+				},
+				Outputs: map[string]interface{}{
+					"NLBHostName":     Output{Value: cfn.GetAtt("LoadBalancer", "DNSName")},
+					"IngressRules":    Output{Value: getIngressRulesJsonStr()},
+					"Scheme":          Output{Value: "internet-facing"},
+					"TargetType":      Output{Value: "ip"},
+					"HealthCheck":     Output{Value: `{"protocol":"TCP","port":"traffic-port","intervalSeconds":30,"timeoutSeconds":10,"healthyThresholdCount":3,"unhealthyThresholdCount":3}`},
+					"Listeners":       Output{Value: `{"tcp":false,"certificateArns":["arn:foo","arn:bar"],"sslPolicy":"ELBSecurityPolicy-2016-08","alpnPolicy":"HTTP2Preferred"}`},
+					"Attributes":      Output{Value: `{}`},
+					"Addresses":       Output{Value: `{}`},
+					"IPAddressType":   Output{Value: "ipv4"},
+					"SourceRanges":    Output{Value: `[]`},
+					"DNS":             Output{Value: `{}`},
+					"EndpointService": Output{Value: `{"enabled":false}`},
+					"APIGateway":      Output{Value: `{"enabled":false}`},
+					"Monitoring":      Output{Value: `{"alarms":false}`},
+				},
+			},
+		},
+		{
+			name: "generates template with HTTP health check on the proxy healthz server",
+			args: &TemplateConfig{
+				Rules: []extensionsv1beta1.IngressRule{{
+					IngressRuleValue: extensionsv1beta1.IngressRuleValue{
+						HTTP: &extensionsv1beta1.HTTPIngressRuleValue{
+							Paths: []extensionsv1beta1.HTTPIngressPath{
+								{
+									Path: "/api/v1/foobar",
+									Backend: extensionsv1beta1.IngressBackend{
+										ServiceName: "foobar-service",
+										ServicePort: intstr.FromInt(8080),
+									},
+								},
+							},
+						},
+					},
+				}},
+				Network: &network.Network{
+					Vpc: &ec2.Vpc{
+						VpcId:     aws.String("foo"),
+						CidrBlock: aws.String("10.0.0.0/24"),
+					},
+					InstanceIDs:      []string{"i-foo"},
+					SubnetIDs:        []string{"sn-foo"},
+					SecurityGroupIDs: []string{"sg-foo"},
+				},
+				NodePort:            30123,
+				HealthCheckNodePort: 30124,
+				HealthCheck: &HealthCheckConfig{
+					Protocol:                "HTTP",
+					Path:                    "/healthz",
+					IntervalSeconds:         10,
+					TimeoutSeconds:          6,
+					HealthyThresholdCount:   2,
+					UnhealthyThresholdCount: 2,
+				},
+			},
+			want: &cfn.Template{
+				Resources: cfn.Resources{
+					"TargetGroup": buildAWSElasticLoadBalancingV2TargetGroup("foo", "instance", []string{"i-foo"}, 30123, &HealthCheckConfig{
+						Protocol:                "HTTP",
+						Path:                    "/healthz",
+						Port:                    "30124",
+						IntervalSeconds:         10,
+						TimeoutSeconds:          6,
+						HealthyThresholdCount:   2,
+						UnhealthyThresholdCount: 2,
+					}, []string{"LoadBalancer"}),
+					"Listener":                         buildAWSElasticLoadBalancingV2Listener(),
+					"SecurityGroupIngress0":            buildAWSEC2SecurityGroupIngresses([]string{"sg-foo"}, "10.0.0.0/24", 30123)[0],
+					"HealthCheckSecurityGroupIngress0": buildAWSEC2SecurityGroupIngresses([]string{"sg-foo"}, "10.0.0.0/24", 30124)[0],
+					"LoadBalancer":                     buildAWSElasticLoadBalancingV2LoadBalancer("internal", "ipv4", []string{"sn-foo"}, nil),
+				},
+				Outputs: map[string]interface{}{
+					"NLBHostName":     Output{Value: cfn.GetAtt("LoadBalancer", "DNSName")},
+					"IngressRules":    Output{Value: getIngressRulesJsonStr()},
+					"Listeners":       Output{Value: `{"tcp":true}`},
+					"Scheme":          Output{Value: "internal"},
+					"TargetType":      Output{Value: "instance"},
+					"HealthCheck":     Output{Value: `{"protocol":"HTTP","path":"/healthz","port":"","intervalSeconds":10,"timeoutSeconds":6,"healthyThresholdCount":2,"unhealthyThresholdCount":2}`},
+					"Attributes":      Output{Value: `{}`},
+					"Addresses":       Output{Value: `{}`},
+					"IPAddressType":   Output{Value: "ipv4"},
+					"SourceRanges":    Output{Value: `[]`},
+					"DNS":             Output{Value: `{}`},
+					"EndpointService": Output{Value: `{"enabled":false}`},
+					"APIGateway":      Output{Value: `{"enabled":false}`},
+					"Monitoring":      Output{Value: `{"alarms":false}`},
+				},
+			},
+		},
+		{
+			name: "generates template with load balancer and target group attributes",
+			args: &TemplateConfig{
+				Rules: []extensionsv1beta1.IngressRule{{
+					IngressRuleValue: extensionsv1beta1.IngressRuleValue{
+						HTTP: &extensionsv1beta1.HTTPIngressRuleValue{
+							Paths: []extensionsv1beta1.HTTPIngressPath{
+								{
+									Path: "/api/v1/foobar",
+									Backend: extensionsv1beta1.IngressBackend{
+										ServiceName: "foobar-service",
+										ServicePort: intstr.FromInt(8080),
+									},
+								},
+							},
+						},
+					},
+				}},
+				Network: &network.Network{
+					Vpc: &ec2.Vpc{
+						VpcId:     aws.String("foo"),
+						CidrBlock: aws.String("10.0.0.0/24"),
+					},
+					InstanceIDs:      []string{"i-foo"},
+					SubnetIDs:        []string{"sn-foo"},
+					SecurityGroupIDs: []string{"sg-foo"},
+				},
+				NodePort: 30123,
+				Attributes: &AttributesConfig{
+					LoadBalancer: map[string]string{"load_balancing.cross_zone.enabled": "true", "deletion_protection.enabled": "true"},
+					TargetGroup:  map[string]string{"deregistration_delay.timeout_seconds": "30"},
+				},
+			},
+			want: &cfn.Template{
+				Resources: cfn.Resources{
+					"TargetGroup": func() *elasticloadbalancingv2.TargetGroup {
+						tg := buildAWSElasticLoadBalancingV2TargetGroup("foo", "instance", []string{"i-foo"}, 30123, &DefaultHealthCheckConfig, []string{"LoadBalancer"})
+						tg.TargetGroupAttributes = []elasticloadbalancingv2.TargetGroup_TargetGroupAttribute{
+							{Key: "deregistration_delay.timeout_seconds", Value: "30"},
+						}
+						return tg
+					}(),
+					"Listener":              buildAWSElasticLoadBalancingV2Listener(),
+					"SecurityGroupIngress0": buildAWSEC2SecurityGroupIngresses([]string{"sg-foo"}, "10.0.0.0/24", 30123)[0],
+					"LoadBalancer": func() *LoadBalancer {
+						lb := buildAWSElasticLoadBalancingV2LoadBalancer("internal", "ipv4", []string{"sn-foo"}, nil)
+						lb.LoadBalancerAttributes = []elasticloadbalancingv2.LoadBalancer_LoadBalancerAttribute{
+							{Key: "deletion_protection.enabled", Value: "true"},
+							{Key: "load_balancing.cross_zone.enabled", Value: "true"},
+						}
+						return lb
+					}(),
+				},
+				Outputs: map[string]interface{}{
+					"NLBHostName":     Output{Value: cfn.GetAtt("LoadBalancer", "DNSName")},
+					"IngressRules":    Output{Value: getIngressRulesJsonStr()},
+					"Listeners":       Output{Value: `{"tcp":true}`},
+					"Scheme":          Output{Value: "internal"},
+					"TargetType":      Output{Value: "instance"},
+					"HealthCheck":     Output{Value: `{"protocol":"TCP","port":"traffic-port","intervalSeconds":30,"timeoutSeconds":10,"healthyThresholdCount":3,"unhealthyThresholdCount":3}`},
+					"Attributes":      Output{Value: `{"loadBalancer":{"deletion_protection.enabled":"true","load_balancing.cross_zone.enabled":"true"},"targetGroup":{"deregistration_delay.timeout_seconds":"30"}}`},
+					"Addresses":       Output{Value: `{}`},
+					"IPAddressType":   Output{Value: "ipv4"},
+					"SourceRanges":    Output{Value: `[]`},
+					"DNS":             Output{Value: `{}`},
+					"EndpointService": Output{Value: `{"enabled":false}`},
+					"APIGateway":      Output{Value: `{"enabled":false}`},
+					"Monitoring":      Output{Value: `{"alarms":false}`},
+				},
+			},
+		},
+		{
+			name: "generates template with elastic ip subnet mappings",
+			args: &TemplateConfig{
+				Rules: []extensionsv1beta1.IngressRule{{
+					IngressRuleValue: extensionsv1beta1.IngressRuleValue{
+						HTTP: &extensionsv1beta1.HTTPIngressRuleValue{
+							Paths: []extensionsv1beta1.HTTPIngressPath{
+								{
+									Path: "/api/v1/foobar",
+									Backend: extensionsv1beta1.IngressBackend{
+										ServiceName: "foobar-service",
+										ServicePort: intstr.FromInt(8080),
+									},
+								},
+							},
+						},
+					},
+				}},
+				Network: &network.Network{
+					Vpc: &ec2.Vpc{
+						VpcId:     aws.String("foo"),
+						CidrBlock: aws.String("10.0.0.0/24"),
+					},
+					InstanceIDs:      []string{"i-foo"},
+					SubnetIDs:        []string{"sn-foo"},
+					SubnetMappings:   []network.SubnetMapping{{SubnetID: "sn-foo", AllocationID: "eipalloc-foo"}},
+					SecurityGroupIDs: []string{"sg-foo"},
+				},
+				NodePort:  30123,
+				Scheme:    "internet-facing",
+				Addresses: &AddressConfig{AllocationIDs: []string{"eipalloc-foo"}},
+			},
+			want: &cfn.Template{
+				Resources: cfn.Resources{
+					"TargetGroup":           buildAWSElasticLoadBalancingV2TargetGroup("foo", "instance", []string{"i-foo"}, 30123, &DefaultHealthCheckConfig, []string{"LoadBalancer"}),
+					"Listener":              buildAWSElasticLoadBalancingV2Listener(),
+					"SecurityGroupIngress0": buildAWSEC2SecurityGroupIngresses([]string{"sg-foo"}, "10.0.0.0/24", 30123)[0],
+					"LoadBalancer":          buildAWSElasticLoadBalancingV2LoadBalancer("internet-facing", "ipv4", nil, []network.SubnetMapping{{SubnetID: "sn-foo", AllocationID: "eipalloc-foo"}}),
+				},
+				Outputs: map[string]interface{}{
+					"NLBHostName":     Output{Value: cfn.GetAtt("LoadBalancer", "DNSName")},
+					"IngressRules":    Output{Value: getIngressRulesJsonStr()},
+					"Listeners":       Output{Value: `{"tcp":true}`},
+					"Scheme":          Output{Value: "internet-facing"},
+					"TargetType":      Output{Value: "instance"},
+					"HealthCheck":     Output{Value: `{"protocol":"TCP","port":"traffic-port","intervalSeconds":30,"timeoutSeconds":10,"healthyThresholdCount":3,"unhealthyThresholdCount":3}`},
+					"Attributes":      Output{Value: `{}`},
+					"Addresses":       Output{Value: `{"allocationIds":["eipalloc-foo"]}`},
+					"IPAddressType":   Output{Value: "ipv4"},
+					"SourceRanges":    Output{Value: `[]`},
+					"DNS":             Output{Value: `{}`},
+					"EndpointService": Output{Value: `{"enabled":false}`},
+					"APIGateway":      Output{Value: `{"enabled":false}`},
+					"Monitoring":      Output{Value: `{"alarms":false}`},
+				},
+			},
+		},
+		{
+			name: "generates template for dualstack load balancer",
+			args: &TemplateConfig{
+				Rules: []extensionsv1beta1.IngressRule{{
+					IngressRuleValue: extensionsv1beta1.IngressRuleValue{
+						HTTP: &extensionsv1beta1.HTTPIngressRuleValue{
+							Paths: []extensionsv1beta1.HTTPIngressPath{
+								{
+									Path: "/api/v1/foobar",
+									Backend: extensionsv1beta1.IngressBackend{
+										ServiceName: "foobar-service",
+										ServicePort: intstr.FromInt(8080),
+									},
+								},
+							},
+						},
+					},
+				}},
+				Network: &network.Network{
+					Vpc: &ec2.Vpc{
+						VpcId:     aws.String("foo"),
+						CidrBlock: aws.String("10.0.0.0/24"),
+						Ipv6CidrBlockAssociationSet: []*ec2.VpcIpv6CidrBlockAssociation{
+							{
+								Ipv6CidrBlock:      aws.String("2600:1f14::/56"),
+								Ipv6CidrBlockState: &ec2.VpcCidrBlockState{State: aws.String("associated")},
+							},
+						},
+					},
+					InstanceIDs:      []string{"i-foo"},
+					SubnetIDs:        []string{"sn-foo"},
+					SecurityGroupIDs: []string{"sg-foo"},
+				},
+				NodePort:      30123,
+				IPAddressType: "dualstack",
+			},
+			want: &cfn.Template{
+				Resources: cfn.Resources{
+					"TargetGroup":               buildAWSElasticLoadBalancingV2TargetGroup("foo", "instance", []string{"i-foo"}, 30123, &DefaultHealthCheckConfig, []string{"LoadBalancer"}),
+					"Listener":                  buildAWSElasticLoadBalancingV2Listener(),
+					"SecurityGroupIngress0":     buildAWSEC2SecurityGroupIngresses([]string{"sg-foo"}, "10.0.0.0/24", 30123)[0],
+					"SecurityGroupIngressIPv60": buildAWSEC2SecurityGroupIPv6Ingresses([]string{"sg-foo"}, "2600:1f14::/56", 30123)[0],
+					"LoadBalancer":              buildAWSElasticLoadBalancingV2LoadBalancer("internal", "dualstack", []string{"sn-foo"}, nil),
+				},
+				Outputs: map[string]interface{}{
+					"NLBHostName":     Output{Value: cfn.GetAtt("LoadBalancer", "DNSName")},
+					"IngressRules":    Output{Value: getIngressRulesJsonStr()},
+					"Listeners":       Output{Value: `{"tcp":true}`},
+					"Scheme":          Output{Value: "internal"},
+					"TargetType":      Output{Value: "instance"},
+					"HealthCheck":     Output{Value: `{"protocol":"TCP","port":"traffic-port","intervalSeconds":30,"timeoutSeconds":10,"healthyThresholdCount":3,"unhealthyThresholdCount":3}`},
+					"Attributes":      Output{Value: `{}`},
+					"Addresses":       Output{Value: `{}`},
+					"IPAddressType":   Output{Value: "dualstack"},
+					"SourceRanges":    Output{Value: `[]`},
+					"DNS":             Output{Value: `{}`},
+					"EndpointService": Output{Value: `{"enabled":false}`},
+					"APIGateway":      Output{Value: `{"enabled":false}`},
+					"Monitoring":      Output{Value: `{"alarms":false}`},
+				},
+			},
+		},
+		{
+			name: "generates template with rules for every vpc cidr block",
+			args: &TemplateConfig{
+				Rules: []extensionsv1beta1.IngressRule{{
+					IngressRuleValue: extensionsv1beta1.IngressRuleValue{
+						HTTP: &extensionsv1beta1.HTTPIngressRuleValue{
+							Paths: []extensionsv1beta1.HTTPIngressPath{
+								{
+									Path: "/api/v1/foobar",
+									Backend: extensionsv1beta1.IngressBackend{
+										ServiceName: "foobar-service",
+										ServicePort: intstr.FromInt(8080),
+									},
+								},
+							},
+						},
+					},
+				}},
+				Network: &network.Network{
+					Vpc: &ec2.Vpc{
+						VpcId:     aws.String("foo"),
+						CidrBlock: aws.String("10.0.0.0/24"),
+						CidrBlockAssociationSet: []*ec2.VpcCidrBlockAssociation{
+							{CidrBlock: aws.String("10.0.0.0/24"), CidrBlockState: &ec2.VpcCidrBlockState{State: aws.String("associated")}},
+							{CidrBlock: aws.String("100.64.0.0/16"), CidrBlockState: &ec2.VpcCidrBlockState{State: aws.String("associated")}},
+						},
+					},
+					InstanceIDs:      []string{"i-foo"},
+					SubnetIDs:        []string{"sn-foo"},
+					SecurityGroupIDs: []string{"sg-foo"},
+				},
+				NodePort: 30123,
+			},
+			want: &cfn.Template{
+				Resources: cfn.Resources{
+					"TargetGroup":           buildAWSElasticLoadBalancingV2TargetGroup("foo", "instance", []string{"i-foo"}, 30123, &DefaultHealthCheckConfig, []string{"LoadBalancer"}),
+					"Listener":              buildAWSElasticLoadBalancingV2Listener(),
+					"SecurityGroupIngress0": buildAWSEC2SecurityGroupIngresses([]string{"sg-foo"}, "10.0.0.0/24", 30123)[0],
+					"SecurityGroupIngress1": buildAWSEC2SecurityGroupIngresses([]string{"sg-foo"}, "100.64.0.0/16", 30123)[0],
+					"LoadBalancer":          buildAWSElasticLoadBalancingV2LoadBalancer("internal", "ipv4", []string{"sn-foo"}, nil),
+				},
+				Outputs: map[string]interface{}{
+					"NLBHostName":     Output{Value: cfn.GetAtt("LoadBalancer", "DNSName")},
+					"IngressRules":    Output{Value: getIngressRulesJsonStr()},
+					"Listeners":       Output{Value: `{"tcp":true}`},
+					"Scheme":          Output{Value: "internal"},
+					"TargetType":      Output{Value: "instance"},
+					"HealthCheck":     Output{Value: `{"protocol":"TCP","port":"traffic-port","intervalSeconds":30,"timeoutSeconds":10,"healthyThresholdCount":3,"unhealthyThresholdCount":3}`},
+					"Attributes":      Output{Value: `{}`},
+					"Addresses":       Output{Value: `{}`},
+					"IPAddressType":   Output{Value: "ipv4"},
+					"SourceRanges":    Output{Value: `[]`},
+					"DNS":             Output{Value: `{}`},
+					"EndpointService": Output{Value: `{"enabled":false}`},
+					"APIGateway":      Output{Value: `{"enabled":false}`},
+					"Monitoring":      Output{Value: `{"alarms":false}`},
+				},
+			},
+		},
+		{
+			name: "generates template with source ranges",
+			args: &TemplateConfig{
+				Rules: []extensionsv1beta1.IngressRule{{
+					IngressRuleValue: extensionsv1beta1.IngressRuleValue{
+						HTTP: &extensionsv1beta1.HTTPIngressRuleValue{
+							Paths: []extensionsv1beta1.HTTPIngressPath{
+								{
+									Path: "/api/v1/foobar",
+									Backend: extensionsv1beta1.IngressBackend{
+										ServiceName: "foobar-service",
+										ServicePort: intstr.FromInt(8080),
+									},
+								},
+							},
+						},
+					},
+				}},
+				Network: &network.Network{
+					Vpc: &ec2.Vpc{
+						VpcId:     aws.String("foo"),
+						CidrBlock: aws.String("10.0.0.0/24"),
+					},
+					InstanceIDs:      []string{"i-foo"},
+					SubnetIDs:        []string{"sn-foo"},
+					SecurityGroupIDs: []string{"sg-foo", "sg-bar"},
+				},
+				NodePort:            30123,
+				HealthCheckNodePort: 30124,
+				HealthCheck: &HealthCheckConfig{
+					Protocol:                "HTTP",
+					Path:                    "/healthz",
+					IntervalSeconds:         30,
+					TimeoutSeconds:          6,
+					HealthyThresholdCount:   3,
+					UnhealthyThresholdCount: 3,
+				},
+				Scheme:       "internet-facing",
+				SourceRanges: []string{"203.0.113.0/24", "2001:db8::/32"},
+			},
+			want: &cfn.Template{
+				Resources: cfn.Resources{
+					"TargetGroup": buildAWSElasticLoadBalancingV2TargetGroup("foo", "instance", []string{"i-foo"}, 30123, &HealthCheckConfig{
+						Protocol:                "HTTP",
+						Path:                    "/healthz",
+						Port:                    "30124",
+						IntervalSeconds:         30,
+						TimeoutSeconds:          6,
+						HealthyThresholdCount:   3,
+						UnhealthyThresholdCount: 3,
+					}, []string{"LoadBalancer"}),
+					"Listener":                         buildAWSElasticLoadBalancingV2Listener(),
+					"SecurityGroupIngress0":            buildAWSEC2SecurityGroupIngresses([]string{"sg-foo"}, "203.0.113.0/24", 30123)[0],
+					"SecurityGroupIngress1":            buildAWSEC2SecurityGroupIngresses([]string{"sg-bar"}, "203.0.113.0/24", 30123)[0],
+					"SecurityGroupIngressIPv60":        buildAWSEC2SecurityGroupIPv6Ingresses([]string{"sg-foo"}, "2001:db8::/32", 30123)[0],
+					"SecurityGroupIngressIPv61":        buildAWSEC2SecurityGroupIPv6Ingresses([]string{"sg-bar"}, "2001:db8::/32", 30123)[0],
+					"HealthCheckSecurityGroupIngress0": buildAWSEC2SecurityGroupIngresses([]string{"sg-foo"}, "10.0.0.0/24", 30124)[0],
+					"HealthCheckSecurityGroupIngress1": buildAWSEC2SecurityGroupIngresses([]string{"sg-bar"}, "10.0.0.0/24", 30124)[0],
+					"LoadBalancer":                     buildAWSElasticLoadBalancingV2LoadBalancer("internet-facing", "ipv4", []string{"sn-foo"}, nil),
+				},
+				Outputs: map[string]interface{}{
+					"NLBHostName":     Output{Value: cfn.GetAtt("LoadBalancer", "DNSName")},
+					"IngressRules":    Output{Value: getIngressRulesJsonStr()},
+					"Listeners":       Output{Value: `{"tcp":true}`},
+					"Scheme":          Output{Value: "internet-facing"},
+					"TargetType":      Output{Value: "instance"},
+					"HealthCheck":     Output{Value: `{"protocol":"HTTP","path":"/healthz","port":"","intervalSeconds":30,"timeoutSeconds":6,"healthyThresholdCount":3,"unhealthyThresholdCount":3}`},
+					"Attributes":      Output{Value: `{}`},
+					"Addresses":       Output{Value: `{}`},
+					"IPAddressType":   Output{Value: "ipv4"},
+					"SourceRanges":    Output{Value: `["203.0.113.0/24","2001:db8::/32"]`},
+					"DNS":             Output{Value: `{}`},
+					"EndpointService": Output{Value: `{"enabled":false}`},
+					"APIGateway":      Output{Value: `{"enabled":false}`},
+					"Monitoring":      Output{Value: `{"alarms":false}`},
+				},
+			},
+		},
+		{
+			name: "generates template with alias records for the hosts",
 			args: &TemplateConfig{
-				Rule: extensionsv1beta1.IngressRule{
+				Rules: []extensionsv1beta1.IngressRule{{
+					Host: "foo.example.com",
 					IngressRuleValue: extensionsv1beta1.IngressRuleValue{
 						HTTP: &extensionsv1beta1.HTTPIngressRuleValue{
 							Paths: []extensionsv1beta1.HTTPIngressPath{
@@ -64,7 +645,70 @@ func TestBuildApiGatewayTemplateFromIngressRule(t *testing.T) {
 							},
 						},
 					},
+				}},
+				Network: &network.Network{
+					Vpc: &ec2.Vpc{
+						VpcId:     aws.String("foo"),
+						CidrBlock: aws.String("10.0.0.0/24"),
+					},
+					InstanceIDs:      []string{"i-foo"},
+					SubnetIDs:        []string{"sn-foo"},
+					SecurityGroupIDs: []string{"sg-foo"},
 				},
+				NodePort:      30123,
+				IPAddressType: "dualstack",
+				DNS: &DNSConfig{
+					HostedZoneID: "Z123",
+					Hosts:        []string{"*.example.com", "foo.example.com"},
+				},
+			},
+			want: &cfn.Template{
+				Resources: cfn.Resources{
+					"TargetGroup":           buildAWSElasticLoadBalancingV2TargetGroup("foo", "instance", []string{"i-foo"}, 30123, &DefaultHealthCheckConfig, []string{"LoadBalancer"}),
+					"Listener":              buildAWSElasticLoadBalancingV2Listener(),
+					"SecurityGroupIngress0": buildAWSEC2SecurityGroupIngresses([]string{"sg-foo"}, "10.0.0.0/24", 30123)[0],
+					"LoadBalancer":          buildAWSElasticLoadBalancingV2LoadBalancer("internal", "dualstack", []string{"sn-foo"}, nil),
+					recordSetResourceName("*.example.com", "A"):      buildAWSRoute53RecordSet("Z123", "*.example.com", "A"),
+					recordSetResourceName("*.example.com", "AAAA"):   buildAWSRoute53RecordSet("Z123", "*.example.com", "AAAA"),
+					recordSetResourceName("foo.example.com", "A"):    buildAWSRoute53RecordSet("Z123", "foo.example.com", "A"),
+					recordSetResourceName("foo.example.com", "AAAA"): buildAWSRoute53RecordSet("Z123", "foo.example.com", "AAAA"),
+				},
+				Outputs: map[string]interface{}{
+					"NLBHostName":     Output{Value: cfn.GetAtt("LoadBalancer", "DNSName")},
+					"IngressRules":    Output{Value: getIngressRulesJsonStr()},
+					"Listeners":       Output{Value: `{"tcp":true}`},
+					"Scheme":          Output{Value: "internal"},
+					"TargetType":      Output{Value: "instance"},
+					"HealthCheck":     Output{Value: `{"protocol":"TCP","port":"traffic-port","intervalSeconds":30,"timeoutSeconds":10,"healthyThresholdCount":3,"unhealthyThresholdCount":3}`},
+					"Attributes":      Output{Value: `{}`},
+					"Addresses":       Output{Value: `{}`},
+					"IPAddressType":   Output{Value: "dualstack"},
+					"SourceRanges":    Output{Value: `[]`},
+					"DNS":             Output{Value: `{"hostedZoneId":"Z123","hosts":["*.example.com","foo.example.com"]}`},
+					"EndpointService": Output{Value: `{"enabled":false}`},
+					"APIGateway":      Output{Value: `{"enabled":false}`},
+					"Monitoring":      Output{Value: `{"alarms":false}`},
+				},
+			},
+		},
+		{
+			name: "generates template with vpc endpoint service",
+			args: &TemplateConfig{
+				Rules: []extensionsv1beta1.IngressRule{{
+					IngressRuleValue: extensionsv1beta1.IngressRuleValue{
+						HTTP: &extensionsv1beta1.HTTPIngressRuleValue{
+							Paths: []extensionsv1beta1.HTTPIngressPath{
+								{
+									Path: "/api/v1/foobar",
+									Backend: extensionsv1beta1.IngressBackend{
+										ServiceName: "foobar-service",
+										ServicePort: intstr.FromInt(8080),
+									},
+								},
+							},
+						},
+					},
+				}},
 				Network: &network.Network{
 					Vpc: &ec2.Vpc{
 						VpcId:     aws.String("foo"),
@@ -75,17 +719,102 @@ func TestBuildApiGatewayTemplateFromIngressRule(t *testing.T) {
 					SecurityGroupIDs: []string{"sg-foo"},
 				},
 				NodePort: 30123,
+				EndpointService: &EndpointServiceConfig{
+					Enabled:           true,
+					AllowedPrincipals: []string{"arn:aws:iam::123456789012:root"},
+				},
+			},
+			want: &cfn.Template{
+				Resources: cfn.Resources{
+					"TargetGroup":                   buildAWSElasticLoadBalancingV2TargetGroup("foo", "instance", []string{"i-foo"}, 30123, &DefaultHealthCheckConfig, []string{"LoadBalancer"}),
+					"Listener":                      buildAWSElasticLoadBalancingV2Listener(),
+					"SecurityGroupIngress0":         buildAWSEC2SecurityGroupIngresses([]string{"sg-foo"}, "10.0.0.0/24", 30123)[0],
+					"LoadBalancer":                  buildAWSElasticLoadBalancingV2LoadBalancer("internal", "ipv4", []string{"sn-foo"}, nil),
+					"VPCEndpointService":            buildAWSEC2VPCEndpointService(false),
+					"VPCEndpointServicePermissions": buildAWSEC2VPCEndpointServicePermissions([]string{"arn:aws:iam::123456789012:root"}),
+				},
+				Outputs: map[string]interface{}{
+					"NLBHostName":         Output{Value: cfn.GetAtt("LoadBalancer", "DNSName")},
+					"IngressRules":        Output{Value: getIngressRulesJsonStr()},
+					"Listeners":           Output{Value: `{"tcp":true}`},
+					"Scheme":              Output{Value: "internal"},
+					"TargetType":          Output{Value: "instance"},
+					"HealthCheck":         Output{Value: `{"protocol":"TCP","port":"traffic-port","intervalSeconds":30,"timeoutSeconds":10,"healthyThresholdCount":3,"unhealthyThresholdCount":3}`},
+					"Attributes":          Output{Value: `{}`},
+					"Addresses":           Output{Value: `{}`},
+					"IPAddressType":       Output{Value: "ipv4"},
+					"SourceRanges":        Output{Value: `[]`},
+					"DNS":                 Output{Value: `{}`},
+					"EndpointService":     Output{Value: `{"enabled":true,"allowedPrincipals":["arn:aws:iam::123456789012:root"]}`},
+					"APIGateway":          Output{Value: `{"enabled":false}`},
+					"Monitoring":          Output{Value: `{"alarms":false}`},
+					"EndpointServiceId":   Output{Value: cfn.Ref("VPCEndpointService")},
+					"EndpointServiceName": Output{Value: cfn.Sub("com.amazonaws.vpce.${AWS::Region}.${VPCEndpointService}")},
+				},
+			},
+		},
+		{
+			name: "generates template with cloudwatch alarms and dashboard",
+			args: &TemplateConfig{
+				Rules: []extensionsv1beta1.IngressRule{{
+					IngressRuleValue: extensionsv1beta1.IngressRuleValue{
+						HTTP: &extensionsv1beta1.HTTPIngressRuleValue{
+							Paths: []extensionsv1beta1.HTTPIngressPath{
+								{
+									Path: "/api/v1/foobar",
+									Backend: extensionsv1beta1.IngressBackend{
+										ServiceName: "foobar-service",
+										ServicePort: intstr.FromInt(8080),
+									},
+								},
+							},
+						},
+					},
+				}},
+				Network: &network.Network{
+					Vpc: &ec2.Vpc{
+						VpcId:     aws.String("foo"),
+						CidrBlock: aws.String("10.0.0.0/24"),
+					},
+					InstanceIDs:      []string{"i-foo"},
+					SubnetIDs:        []string{"sn-foo"},
+					SecurityGroupIDs: []string{"sg-foo"},
+				},
+				NodePort:   30123,
+				Monitoring: monitoringConfig,
 			},
 			want: &cfn.Template{
 				Resources: cfn.Resources{
-					"TargetGroup":           buildAWSElasticLoadBalancingV2TargetGroup("foo", []string{"i-foo"}, 30123, []string{"LoadBalancer"}),
+					"TargetGroup":           buildAWSElasticLoadBalancingV2TargetGroup("foo", "instance", []string{"i-foo"}, 30123, &DefaultHealthCheckConfig, []string{"LoadBalancer"}),
 					"Listener":              buildAWSElasticLoadBalancingV2Listener(),
 					"SecurityGroupIngress0": buildAWSEC2SecurityGroupIngresses([]string{"sg-foo"}, "10.0.0.0/24", 30123)[0],
-					"LoadBalancer":          buildAWSElasticLoadBalancingV2LoadBalancer([]string{"sn-foo"}),
+					"LoadBalancer":          buildAWSElasticLoadBalancingV2LoadBalancer("internal", "ipv4", []string{"sn-foo"}, nil),
+					"UnhealthyHostsAlarm": buildAWSCloudWatchAlarm(monitoringConfig, "NLB targets are unhealthy",
+						"UnHealthyHostCount", "Maximum", "GreaterThanOrEqualToThreshold", 1, targetGroupDimension(), loadBalancerDimension()),
+					"HealthyHostsAlarm": buildAWSCloudWatchAlarm(monitoringConfig, "NLB has too few healthy targets",
+						"HealthyHostCount", "Minimum", "LessThanThreshold", 2, targetGroupDimension(), loadBalancerDimension()),
+					"TargetResetsAlarm": buildAWSCloudWatchAlarm(monitoringConfig, "NLB targets are resetting TCP connections",
+						"TCP_Target_Reset_Count", "Sum", "GreaterThanThreshold", 100, loadBalancerDimension()),
+					"ELBResetsAlarm": buildAWSCloudWatchAlarm(monitoringConfig, "NLB is resetting TCP connections",
+						"TCP_ELB_Reset_Count", "Sum", "GreaterThanThreshold", 100, loadBalancerDimension()),
+					"Dashboard": buildAWSCloudWatchDashboard(),
 				},
 				Outputs: map[string]interface{}{
-					"NLBHostName":  Output{Value: cfn.GetAtt("LoadBalancer", "DNSName")},
-					"IngressRules": Output{Value: getIngressRulesJsonStr()},
+					"NLBHostName":     Output{Value: cfn.GetAtt("LoadBalancer", "DNSName")},
+					"IngressRules":    Output{Value: getIngressRulesJsonStr()},
+					"Listeners":       Output{Value: `{"tcp":true}`},
+					"Scheme":          Output{Value: "internal"},
+					"TargetType":      Output{Value: "instance"},
+					"HealthCheck":     Output{Value: `{"protocol":"TCP","port":"traffic-port","intervalSeconds":30,"timeoutSeconds":10,"healthyThresholdCount":3,"unhealthyThresholdCount":3}`},
+					"Attributes":      Output{Value: `{}`},
+					"Addresses":       Output{Value: `{}`},
+					"IPAddressType":   Output{Value: "ipv4"},
+					"SourceRanges":    Output{Value: `[]`},
+					"DNS":             Output{Value: `{}`},
+					"EndpointService": Output{Value: `{"enabled":false}`},
+					"APIGateway":      Output{Value: `{"enabled":false}`},
+					"Monitoring":      Output{Value: `{"alarms":true,"dashboard":true,"alarmActions":["arn:aws:sns:us-east-1:123456789012:alerts"],"unhealthyHostThreshold":1,"minHealthyHosts":2,"tcpResetThreshold":100,"periodSeconds":60,"evaluationPeriods":3}`},
+					"DashboardName":   Output{Value: cfn.Ref("Dashboard")},
 				},
 			},
 		},
@@ -94,11 +823,17 @@ func TestBuildApiGatewayTemplateFromIngressRule(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			got := BuildNLBTemplateFromIngressRule(tt.args)
+			if len(got.Resources) != len(tt.want.Resources) {
+				t.Errorf("Got %d resources, want %d", len(got.Resources), len(tt.want.Resources))
+			}
 			for k, resource := range got.Resources {
 				if !reflect.DeepEqual(resource, tt.want.Resources[k]) {
 					t.Errorf("Got Resources.%s = %v, want %v", k, got.Resources, tt.want.Resources)
 				}
 			}
+			if len(got.Outputs) != len(tt.want.Outputs) {
+				t.Errorf("Got %d outputs, want %d", len(got.Outputs), len(tt.want.Outputs))
+			}
 			for k, resource := range got.Outputs {
 				if !reflect.DeepEqual(resource, tt.want.Outputs[k]) {
 					t.Errorf("Got Outputs.%s = %v, want %v", k, got.Outputs, tt.want.Outputs)
@@ -107,3 +842,460 @@ func TestBuildApiGatewayTemplateFromIngressRule(t *testing.T) {
 		})
 	}
 }
+
+func TestListenerMarshalJSON(t *testing.T) {
+	b, err := json.Marshal(buildAWSElasticLoadBalancingV2TLSListener("arn:foo", "", "HTTP2Optional"))
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+
+	got := struct {
+		Type       string
+		Properties map[string]interface{}
+	}{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+
+	if got.Type != "AWS::ElasticLoadBalancingV2::Listener" {
+		t.Errorf("Got Type = %s, want AWS::ElasticLoadBalancingV2::Listener", got.Type)
+	}
+	if !reflect.DeepEqual(got.Properties["AlpnPolicy"], []interface{}{"HTTP2Optional"}) {
+		t.Errorf("Got Properties.AlpnPolicy = %v, want [HTTP2Optional]", got.Properties["AlpnPolicy"])
+	}
+	if got.Properties["Port"] != float64(443) || got.Properties["Protocol"] != "TLS" {
+		t.Errorf("Got Properties = %v, want TLS listener on 443", got.Properties)
+	}
+}
+
+func TestLoadBalancerMarshalJSON(t *testing.T) {
+	b, err := json.Marshal(buildAWSElasticLoadBalancingV2LoadBalancer("internal", "ipv4", []string{"sn-foo"}, []network.SubnetMapping{
+		{SubnetID: "sn-foo", PrivateIPv4Address: "10.0.0.10"},
+	}))
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+
+	got := struct {
+		Type       string
+		Properties map[string]interface{}
+	}{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+
+	if got.Type != "AWS::ElasticLoadBalancingV2::LoadBalancer" {
+		t.Errorf("Got Type = %s, want AWS::ElasticLoadBalancingV2::LoadBalancer", got.Type)
+	}
+	want := []interface{}{map[string]interface{}{"SubnetId": "sn-foo", "PrivateIPv4Address": "10.0.0.10"}}
+	if !reflect.DeepEqual(got.Properties["SubnetMappings"], want) {
+		t.Errorf("Got Properties.SubnetMappings = %v, want %v", got.Properties["SubnetMappings"], want)
+	}
+	if _, ok := got.Properties["Subnets"]; ok {
+		t.Errorf("Got Properties.Subnets = %v, want no Subnets alongside SubnetMappings", got.Properties["Subnets"])
+	}
+}
+
+func TestRecordSetResourceName(t *testing.T) {
+	names := map[string]bool{}
+	for _, host := range []string{"foo.example.com", "foo-example.com", "fooexample.com", "*.example.com"} {
+		name := recordSetResourceName(host, "A")
+		if !regexp.MustCompile("^[A-Za-z0-9]+$").MatchString(name) {
+			t.Errorf("recordSetResourceName(%q) = %s, want an alphanumeric logical ID", host, name)
+		}
+		if names[name] {
+			t.Errorf("recordSetResourceName(%q) = %s, want a unique logical ID", host, name)
+		}
+		names[name] = true
+	}
+}
+
+func TestVPCEndpointServiceMarshalJSON(t *testing.T) {
+	b, err := json.Marshal(buildAWSEC2VPCEndpointService(false))
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+
+	got := struct {
+		Properties map[string]interface{}
+	}{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+
+	if got.Properties["AcceptanceRequired"] != false {
+		t.Errorf("Got Properties.AcceptanceRequired = %v, want false", got.Properties["AcceptanceRequired"])
+	}
+}
+
+func TestHealthCheckConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(c *HealthCheckConfig)
+		wantErr bool
+	}{
+		{name: "default is valid", modify: func(c *HealthCheckConfig) {}},
+		{name: "http with path", modify: func(c *HealthCheckConfig) { c.Protocol, c.TimeoutSeconds, c.Path = "HTTP", 6, "/healthz" }},
+		{name: "https with explicit port", modify: func(c *HealthCheckConfig) { c.Protocol, c.Port = "HTTPS", "8443" }},
+		{name: "unknown protocol", modify: func(c *HealthCheckConfig) { c.Protocol = "UDP" }, wantErr: true},
+		{name: "interval must be 10 or 30", modify: func(c *HealthCheckConfig) { c.IntervalSeconds = 20 }, wantErr: true},
+		{name: "tcp timeout is fixed", modify: func(c *HealthCheckConfig) { c.TimeoutSeconds = 6 }, wantErr: true},
+		{name: "thresholds must match", modify: func(c *HealthCheckConfig) { c.UnhealthyThresholdCount = 2 }, wantErr: true},
+		{name: "threshold out of range", modify: func(c *HealthCheckConfig) { c.HealthyThresholdCount, c.UnhealthyThresholdCount = 11, 11 }, wantErr: true},
+		{name: "path with tcp", modify: func(c *HealthCheckConfig) { c.Path = "/healthz" }, wantErr: true},
+		{name: "relative path", modify: func(c *HealthCheckConfig) { c.Protocol, c.TimeoutSeconds, c.Path = "HTTP", 6, "healthz" }, wantErr: true},
+		{name: "invalid port", modify: func(c *HealthCheckConfig) { c.Port = "http" }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := DefaultHealthCheckConfig
+			tt.modify(&c)
+			if err := c.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("HealthCheckConfig.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAttributesConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  AttributesConfig
+		wantErr bool
+	}{
+		{name: "empty is valid", config: AttributesConfig{}},
+		{
+			name: "known attributes",
+			config: AttributesConfig{
+				LoadBalancer: map[string]string{"access_logs.s3.enabled": "true", "access_logs.s3.bucket": "logs"},
+				TargetGroup:  map[string]string{"stickiness.enabled": "true", "stickiness.type": "source_ip", "deregistration_delay.timeout_seconds": "0"},
+			},
+		},
+		{name: "unknown load balancer attribute", config: AttributesConfig{LoadBalancer: map[string]string{"idle_timeout.timeout_seconds": "60"}}, wantErr: true},
+		{name: "unknown target group attribute", config: AttributesConfig{TargetGroup: map[string]string{"slow_start.duration_seconds": "30"}}, wantErr: true},
+		{name: "invalid boolean", config: AttributesConfig{LoadBalancer: map[string]string{"deletion_protection.enabled": "yes"}}, wantErr: true},
+		{name: "deregistration delay out of range", config: AttributesConfig{TargetGroup: map[string]string{"deregistration_delay.timeout_seconds": "4000"}}, wantErr: true},
+		{name: "invalid stickiness type", config: AttributesConfig{TargetGroup: map[string]string{"stickiness.type": "lb_cookie"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("AttributesConfig.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAddAPIGatewayResources(t *testing.T) {
+	config := &APIGatewayConfig{
+		Enabled:      true,
+		StageName:    "prod",
+		EndpointType: "REGIONAL",
+		Resources: []APIResource{
+			{
+				Path:             "/api/v1/foobar",
+				CachingEnabled:   true,
+				Methods:          []string{"GET"},
+				ProxyQueryParams: []Param{{Param: "page", Required: false}},
+			},
+		},
+		UsagePlans: []UsagePlan{
+			{
+				PlanName:    "gold",
+				APIKeys:     []APIKey{{Name: "customer-a", CustomerID: "a"}},
+				QuotaLimit:  1000,
+				QuotaPeriod: "DAY",
+				MethodThrottlingParameters: []MethodThrottlingParametersObject{
+					{Path: "/api/v1/foobar/GET", BurstLimit: 10, RateLimit: 5},
+				},
+			},
+		},
+	}
+
+	template := cfn.NewTemplate()
+	template.Outputs = map[string]interface{}{}
+	addAPIGatewayResources(template, config, []string{"/api/v1/foobar", "/api/v2/", "/"})
+
+	// RestAPI, VPCLink, Deployment, Stage, 1 usage plan, 1 key and its usage plan key,
+	// resources for /api, /api/v1, /api/v1/foobar, /api/v2 and 3 proxy resources,
+	// 2 GET methods on /api/v1/foobar and 2 ANY methods on /api/v2 and /
+	if len(template.Resources) != 4+3+7+6 {
+		t.Errorf("Got %d resources, want %d", len(template.Resources), 4+3+7+6)
+	}
+
+	for _, path := range []string{"/api", "/api/v1", "/api/v1/foobar", "/api/v2", "/{proxy+}", "/api/v2/{proxy+}"} {
+		if _, ok := template.Resources[apiResourceName(path)]; !ok {
+			t.Errorf("Got no resource for %s", path)
+		}
+	}
+
+	proxyResource := template.Resources[apiResourceName("/api/v1/foobar/{proxy+}")].(*apigateway.Resource)
+	if proxyResource.PathPart != "{proxy+}" || proxyResource.ParentId != cfn.Ref(apiResourceName("/api/v1/foobar")) {
+		t.Errorf("Got proxy resource %v, want {proxy+} below /api/v1/foobar", proxyResource)
+	}
+
+	method := template.Resources[resourceName("MethodGET", "/api/v1/foobar/{proxy+}", "/api/v1/foobar/{proxy+}")].(*apigateway.Method)
+	if !method.ApiKeyRequired {
+		t.Errorf("Got ApiKeyRequired = false, want true when usage plans hand out keys")
+	}
+	if method.Integration.Uri != cfn.Sub("http://${LoadBalancer.DNSName}/api/v1/foobar/{proxy}") {
+		t.Errorf("Got Integration.Uri = %s, want the proxy path on the NLB", method.Integration.Uri)
+	}
+	wantParameters := map[string]string{
+		"integration.request.path.proxy":       "method.request.path.proxy",
+		"integration.request.querystring.page": "method.request.querystring.page",
+	}
+	if !reflect.DeepEqual(method.Integration.RequestParameters, wantParameters) {
+		t.Errorf("Got Integration.RequestParameters = %v, want %v", method.Integration.RequestParameters, wantParameters)
+	}
+	if !reflect.DeepEqual(method.Integration.CacheKeyParameters, []string{"method.request.path.proxy", "method.request.querystring.page"}) {
+		t.Errorf("Got Integration.CacheKeyParameters = %v, want the proxy and query parameters", method.Integration.CacheKeyParameters)
+	}
+
+	stage := template.Resources[StageResourceName].(*apigateway.Stage)
+	if !stage.CacheClusterEnabled || len(stage.MethodSettings) != 2 {
+		t.Errorf("Got Stage = %v, want a cache cluster with settings for both GET methods", stage)
+	}
+
+	usagePlan := template.Resources[resourceName("UsagePlan", "gold", "gold")].(*apigateway.UsagePlan)
+	if usagePlan.Quota == nil || usagePlan.Quota.Limit != 1000 || usagePlan.ApiStages[0].Throttle["/api/v1/foobar/GET"].BurstLimit != 10 {
+		t.Errorf("Got UsagePlan = %v, want quota and method throttling", usagePlan)
+	}
+
+	if _, ok := template.Outputs[OutputKeyAPIGatewayURL]; !ok {
+		t.Errorf("Got no %s output", OutputKeyAPIGatewayURL)
+	}
+
+	// Changes to the API need a new deployment
+	changed := *config
+	changed.StageName = "test"
+	other := cfn.NewTemplate()
+	other.Outputs = map[string]interface{}{}
+	addAPIGatewayResources(other, &changed, []string{"/api/v1/foobar", "/api/v2/", "/"})
+	for name := range template.Resources {
+		if strings.HasPrefix(name, DeploymentResourceName) {
+			if _, ok := other.Resources[name]; ok {
+				t.Errorf("Got the same deployment %s after the API changed", name)
+			}
+		}
+	}
+}
+
+func TestAddAPIGatewayResources_Caching(t *testing.T) {
+	tests := []struct {
+		name      string
+		resources []APIResource
+		paths     []string
+		want      []apigateway.Stage_MethodSetting
+	}{
+		{
+			name:      "nested path",
+			resources: []APIResource{{Path: "/api/v1", CachingEnabled: true, Methods: []string{"GET"}}},
+			paths:     []string{"/api/v1"},
+			want: []apigateway.Stage_MethodSetting{
+				{CachingEnabled: true, HttpMethod: "GET", ResourcePath: "/~1api~1v1"},
+				{CachingEnabled: true, HttpMethod: "GET", ResourcePath: "/~1api~1v1~1{proxy+}"},
+			},
+		},
+		{
+			name:      "root path with any method",
+			resources: []APIResource{{Path: "/", CachingEnabled: true, Methods: []string{"ANY"}}},
+			paths:     []string{"/"},
+			want: []apigateway.Stage_MethodSetting{
+				{CachingEnabled: true, HttpMethod: "*", ResourcePath: "/"},
+				{CachingEnabled: true, HttpMethod: "*", ResourcePath: "/~1{proxy+}"},
+			},
+		},
+		{
+			name:      "caching disabled",
+			resources: []APIResource{{Path: "/api", Methods: []string{"GET"}}},
+			paths:     []string{"/api"},
+			want:      []apigateway.Stage_MethodSetting{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &APIGatewayConfig{Enabled: true, StageName: "prod", EndpointType: "REGIONAL", Resources: tt.resources}
+			template := cfn.NewTemplate()
+			template.Outputs = map[string]interface{}{}
+			addAPIGatewayResources(template, config, tt.paths)
+
+			stage := template.Resources[StageResourceName].(*apigateway.Stage)
+			if !reflect.DeepEqual(stage.MethodSettings, tt.want) {
+				t.Errorf("Got MethodSettings = %v, want %v", stage.MethodSettings, tt.want)
+			}
+			if stage.CacheClusterEnabled != (len(tt.want) > 0) {
+				t.Errorf("Got CacheClusterEnabled = %v, want %v", stage.CacheClusterEnabled, len(tt.want) > 0)
+			}
+		})
+	}
+}
+
+func TestAPIGatewayConfig_Validate(t *testing.T) {
+	valid := func() APIGatewayConfig {
+		return APIGatewayConfig{Enabled: true, StageName: DefaultAPIGatewayStage, EndpointType: DefaultAPIGatewayEndpoint}
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(c *APIGatewayConfig)
+		wantErr bool
+	}{
+		{name: "default is valid", modify: func(c *APIGatewayConfig) {}},
+		{name: "disabled is not validated", modify: func(c *APIGatewayConfig) { c.Enabled, c.StageName = false, "" }},
+		{name: "invalid stage name", modify: func(c *APIGatewayConfig) { c.StageName = "pro-d" }, wantErr: true},
+		{name: "invalid endpoint type", modify: func(c *APIGatewayConfig) { c.EndpointType = "GLOBAL" }, wantErr: true},
+		{name: "relative resource path", modify: func(c *APIGatewayConfig) { c.Resources = []APIResource{{Path: "api"}} }, wantErr: true},
+		{name: "invalid method", modify: func(c *APIGatewayConfig) { c.Resources = []APIResource{{Path: "/api", Methods: []string{"get"}}} }, wantErr: true},
+		{name: "usage plan without name", modify: func(c *APIGatewayConfig) { c.UsagePlans = []UsagePlan{{}} }, wantErr: true},
+		{name: "duplicate usage plan", modify: func(c *APIGatewayConfig) { c.UsagePlans = []UsagePlan{{PlanName: "a"}, {PlanName: "a"}} }, wantErr: true},
+		{name: "quota without period", modify: func(c *APIGatewayConfig) { c.UsagePlans = []UsagePlan{{PlanName: "a", QuotaLimit: 10}} }, wantErr: true},
+		{name: "api key without name", modify: func(c *APIGatewayConfig) { c.UsagePlans = []UsagePlan{{PlanName: "a", APIKeys: []APIKey{{}}}} }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid()
+			tt.modify(&c)
+			if err := c.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("APIGatewayConfig.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMonitoringConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  MonitoringConfig
+		wantErr bool
+	}{
+		{"disabled alarms are not checked", MonitoringConfig{Dashboard: true}, false},
+		{"defaults", MonitoringConfig{Alarms: true, UnhealthyHostThreshold: 1, MinHealthyHosts: 1, TCPResetThreshold: 100, PeriodSeconds: 60, EvaluationPeriods: 3}, false},
+		{"high resolution period", MonitoringConfig{Alarms: true, UnhealthyHostThreshold: 1, MinHealthyHosts: 1, TCPResetThreshold: 100, PeriodSeconds: 10, EvaluationPeriods: 3}, false},
+		{"invalid period", MonitoringConfig{Alarms: true, UnhealthyHostThreshold: 1, MinHealthyHosts: 1, TCPResetThreshold: 100, PeriodSeconds: 90, EvaluationPeriods: 3}, true},
+		{"no healthy hosts", MonitoringConfig{Alarms: true, UnhealthyHostThreshold: 1, MinHealthyHosts: 0, TCPResetThreshold: 100, PeriodSeconds: 60, EvaluationPeriods: 3}, true},
+		{"no evaluation periods", MonitoringConfig{Alarms: true, UnhealthyHostThreshold: 1, MinHealthyHosts: 1, TCPResetThreshold: 100, PeriodSeconds: 60}, true},
+		{"alarm action is not an arn", MonitoringConfig{Alarms: true, UnhealthyHostThreshold: 1, MinHealthyHosts: 1, TCPResetThreshold: 100, PeriodSeconds: 60, EvaluationPeriods: 3, AlarmActions: []string{"alerts"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("MonitoringConfig.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBuildAWSCloudWatchDashboard(t *testing.T) {
+	template := cfn.NewTemplate()
+	template.Resources[DashboardResourceName] = buildAWSCloudWatchDashboard()
+	b, err := template.JSON()
+	if err != nil {
+		t.Fatalf("template.JSON() error = %v", err)
+	}
+
+	var rendered struct {
+		Resources map[string]struct {
+			Properties struct {
+				DashboardBody map[string]string
+			}
+		}
+	}
+	if err := json.Unmarshal(b, &rendered); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+
+	// The body has to be valid JSON once Fn::Sub has replaced the variables
+	body := regexp.MustCompile(`\$\{[^}]+\}`).ReplaceAllString(rendered.Resources[DashboardResourceName].Properties.DashboardBody["Fn::Sub"], "x")
+	var widgets struct {
+		Widgets []interface{} `json:"widgets"`
+	}
+	if err := json.Unmarshal([]byte(body), &widgets); err != nil {
+		t.Fatalf("dashboard body is not valid JSON: %v\n%s", err, body)
+	}
+	if len(widgets.Widgets) != 4 {
+		t.Errorf("Got %d widgets, want 4", len(widgets.Widgets))
+	}
+}
+
+func TestPlannedChanges(t *testing.T) {
+	change := func(logicalID string, replacement string) *cloudformation.Change {
+		return &cloudformation.Change{
+			Type: aws.String(cloudformation.ChangeTypeResource),
+			ResourceChange: &cloudformation.ResourceChange{
+				LogicalResourceId: aws.String(logicalID),
+				ResourceType:      aws.String("AWS::ElasticLoadBalancingV2::LoadBalancer"),
+				Action:            aws.String(cloudformation.ChangeActionModify),
+				Replacement:       aws.String(replacement),
+			},
+		}
+	}
+
+	changes := PlannedChanges(&cloudformation.DescribeChangeSetOutput{
+		Changes: []*cloudformation.Change{change("A", "False"), change("B", "Conditional"), change("C", "True")},
+	})
+	want := []PlannedChange{
+		{LogicalID: "A", ResourceType: "AWS::ElasticLoadBalancingV2::LoadBalancer", Action: "Modify", Replacement: false},
+		{LogicalID: "B", ResourceType: "AWS::ElasticLoadBalancingV2::LoadBalancer", Action: "Modify", Replacement: true},
+		{LogicalID: "C", ResourceType: "AWS::ElasticLoadBalancingV2::LoadBalancer", Action: "Modify", Replacement: true},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("PlannedChanges() = %v, want %v", changes, want)
+	}
+	if !HasReplacement(changes) || HasReplacement(changes[:1]) {
+		t.Errorf("HasReplacement() only has to be true for replaced resources")
+	}
+
+	if name := ChangeSetName([]byte("template")); !regexp.MustCompile(`^nlb-ingress-[0-9a-f]{8}$`).MatchString(name) || name == ChangeSetName([]byte("other")) {
+		t.Errorf("ChangeSetName() = %s, want a name derived from the template", name)
+	}
+}
+
+func TestIngressRulesOutput(t *testing.T) {
+	rule := extensionsv1beta1.IngressRule{
+		IngressRuleValue: extensionsv1beta1.IngressRuleValue{
+			HTTP: &extensionsv1beta1.HTTPIngressRuleValue{
+				Paths: []extensionsv1beta1.HTTPIngressPath{
+					{
+						Path: "/api/v1/foobar",
+						Backend: extensionsv1beta1.IngressBackend{
+							ServiceName: "foobar-service",
+							ServicePort: intstr.FromInt(8080),
+						},
+					},
+				},
+			},
+		},
+	}
+	backend := &extensionsv1beta1.IngressBackend{ServiceName: "default", ServicePort: intstr.FromInt(80)}
+
+	tests := []struct {
+		name    string
+		rules   []extensionsv1beta1.IngressRule
+		backend *extensionsv1beta1.IngressBackend
+		want    string
+	}{
+		{
+			name:  "single rule keeps the paths only value",
+			rules: []extensionsv1beta1.IngressRule{rule},
+			want:  getIngressRulesJsonStr(),
+		},
+		{
+			name:  "all rules",
+			rules: []extensionsv1beta1.IngressRule{rule, {Host: "foo.example.com"}},
+			want:  `{"rules":[{"http":{"paths":[{"path":"/api/v1/foobar","backend":{"serviceName":"foobar-service","servicePort":8080}}]}},{"host":"foo.example.com"}]}`,
+		},
+		{
+			name:    "default backend",
+			backend: backend,
+			want:    `{"backend":{"serviceName":"default","servicePort":80}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IngressRulesOutput(tt.rules, tt.backend); got != tt.want {
+				t.Errorf("IngressRulesOutput() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package cloudformation
+
+import (
+	"strings"
+
+	cfn "github.com/awslabs/goformation/v4/cloudformation"
+	"github.com/awslabs/goformation/v4/cloudformation/route53"
+)
+
+// RecordSetResourceName prefixes the logical IDs of the alias records
+const RecordSetResourceName = "RecordSet"
+
+// DNSConfig describes the Route53 alias records pointing the ingress hosts at the NLB
+type DNSConfig struct {
+	HostedZoneID string   `json:"hostedZoneId,omitempty"`
+	Hosts        []string `json:"hosts,omitempty"`
+}
+
+// recordSetResourceName derives the logical ID of the record for host, so records keep their ID when hosts are added or removed
+func recordSetResourceName(host string, recordType string) string {
+	return resourceName(RecordSetResourceName+recordType, strings.Replace(host, "*", "wildcard", 1), host)
+}
+
+func buildAWSRoute53RecordSet(hostedZoneID string, host string, recordType string) *route53.RecordSet {
+	return &route53.RecordSet{
+		HostedZoneId: hostedZoneID,
+		Name:         host,
+		Type:         recordType,
+		AliasTarget: &route53.RecordSet_AliasTarget{
+			DNSName:      cfn.GetAtt(LoadBalancerResourceName, "DNSName"),
+			HostedZoneId: cfn.GetAtt(LoadBalancerResourceName, "CanonicalHostedZoneID"),
+		},
+	}
+}
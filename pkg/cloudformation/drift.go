@@ -0,0 +1,42 @@
+package cloudformation
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/cloudformation/cloudformationiface"
+)
+
+// DriftedResources describes the resources of the stack that were modified or deleted outside of CloudFormation
+func DriftedResources(cfnSvc cloudformationiface.CloudFormationAPI, stackName string) ([]string, error) {
+	drifted := []string{}
+	err := cfnSvc.DescribeStackResourceDriftsPages(&cloudformation.DescribeStackResourceDriftsInput{
+		StackName: aws.String(stackName),
+		StackResourceDriftStatusFilters: aws.StringSlice([]string{
+			cloudformation.StackResourceDriftStatusModified,
+			cloudformation.StackResourceDriftStatusDeleted,
+		}),
+	}, func(page *cloudformation.DescribeStackResourceDriftsOutput, lastPage bool) bool {
+		for _, drift := range page.StackResourceDrifts {
+			description := fmt.Sprintf("%s (%s) %s", aws.StringValue(drift.LogicalResourceId), aws.StringValue(drift.ResourceType), aws.StringValue(drift.StackResourceDriftStatus))
+
+			paths := []string{}
+			for _, difference := range drift.PropertyDifferences {
+				paths = append(paths, aws.StringValue(difference.PropertyPath))
+			}
+			if len(paths) > 0 {
+				description = fmt.Sprintf("%s: %s", description, strings.Join(paths, ", "))
+			}
+
+			drifted = append(drifted, description)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return drifted, nil
+}
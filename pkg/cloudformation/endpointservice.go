@@ -0,0 +1,38 @@
+package cloudformation
+
+import (
+	cfn "github.com/awslabs/goformation/v4/cloudformation"
+	"github.com/awslabs/goformation/v4/cloudformation/ec2"
+)
+
+// Resource names and output keys of the VPC endpoint service exposing the NLB over PrivateLink
+const (
+	EndpointServiceResourceName            = "VPCEndpointService"
+	EndpointServicePermissionsResourceName = "VPCEndpointServicePermissions"
+	OutputKeyEndpointService               = "EndpointService"
+	OutputKeyEndpointServiceID             = "EndpointServiceId"
+	OutputKeyEndpointServiceName           = "EndpointServiceName"
+)
+
+// EndpointServiceConfig describes the VPC endpoint service in front of the NLB
+type EndpointServiceConfig struct {
+	Enabled            bool     `json:"enabled"`
+	AcceptanceRequired bool     `json:"acceptanceRequired,omitempty"`
+	AllowedPrincipals  []string `json:"allowedPrincipals,omitempty"`
+}
+
+func buildAWSEC2VPCEndpointService(acceptanceRequired bool) *VPCEndpointService {
+	return &VPCEndpointService{
+		VPCEndpointService: ec2.VPCEndpointService{
+			AcceptanceRequired:      acceptanceRequired,
+			NetworkLoadBalancerArns: []string{cfn.Ref(LoadBalancerResourceName)},
+		},
+	}
+}
+
+func buildAWSEC2VPCEndpointServicePermissions(allowedPrincipals []string) *ec2.VPCEndpointServicePermissions {
+	return &ec2.VPCEndpointServicePermissions{
+		AllowedPrincipals: allowedPrincipals,
+		ServiceId:         cfn.Ref(EndpointServiceResourceName),
+	}
+}
@@ -0,0 +1,57 @@
+package cloudformation
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/cloudformation/cloudformationiface"
+)
+
+// MaxStackEvents bounds the events returned for a stack that was not seen before
+const MaxStackEvents = 50
+
+// StackEventsSince returns the events of the stack newer than lastEventID, oldest first
+func StackEventsSince(cfnSvc cloudformationiface.CloudFormationAPI, stackName string, lastEventID string) ([]*cloudformation.StackEvent, error) {
+	events := []*cloudformation.StackEvent{}
+	in := &cloudformation.DescribeStackEventsInput{
+		StackName: aws.String(stackName),
+	}
+
+	// Events are returned newest first
+	err := cfnSvc.DescribeStackEventsPages(in, func(page *cloudformation.DescribeStackEventsOutput, lastPage bool) bool {
+		for _, event := range page.StackEvents {
+			if aws.StringValue(event.EventId) == lastEventID || len(events) == MaxStackEvents {
+				return false
+			}
+			events = append(events, event)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+
+	return events, nil
+}
+
+// IsFailedEvent tests if the event reports a resource that failed
+func IsFailedEvent(event *cloudformation.StackEvent) bool {
+	return strings.HasSuffix(aws.StringValue(event.ResourceStatus), "_FAILED")
+}
+
+// EventReason converts the resource status of the event to a Kubernetes event reason, UPDATE_FAILED becomes UpdateFailed
+func EventReason(event *cloudformation.StackEvent) string {
+	words := strings.Split(strings.ToLower(aws.StringValue(event.ResourceStatus)), "_")
+	for i, word := range words {
+		if word != "" {
+			words[i] = strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+
+	return strings.Join(words, "")
+}
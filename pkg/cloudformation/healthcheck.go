@@ -0,0 +1,84 @@
+package cloudformation
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Health check protocols and ports supported by NLB target groups
+const (
+	HealthCheckProtocolTCP   = "TCP"
+	HealthCheckProtocolHTTP  = "HTTP"
+	HealthCheckProtocolHTTPS = "HTTPS"
+	HealthCheckPortTraffic   = "traffic-port"
+)
+
+// HealthCheckTimeouts holds the timeout NLB uses for each health check protocol, it can not be changed
+var HealthCheckTimeouts = map[string]int{
+	HealthCheckProtocolTCP:   10,
+	HealthCheckProtocolHTTP:  6,
+	HealthCheckProtocolHTTPS: 10,
+}
+
+// HealthCheckConfig describes the target group health check.
+// An empty Port targets the reverse proxy healthz server.
+type HealthCheckConfig struct {
+	Protocol                string `json:"protocol"`
+	Path                    string `json:"path,omitempty"`
+	Port                    string `json:"port"`
+	IntervalSeconds         int    `json:"intervalSeconds"`
+	TimeoutSeconds          int    `json:"timeoutSeconds"`
+	HealthyThresholdCount   int    `json:"healthyThresholdCount"`
+	UnhealthyThresholdCount int    `json:"unhealthyThresholdCount"`
+}
+
+// DefaultHealthCheckConfig is a TCP health check on the port traffic is sent to
+var DefaultHealthCheckConfig = HealthCheckConfig{
+	Protocol:                HealthCheckProtocolTCP,
+	Port:                    HealthCheckPortTraffic,
+	IntervalSeconds:         30,
+	TimeoutSeconds:          10,
+	HealthyThresholdCount:   3,
+	UnhealthyThresholdCount: 3,
+}
+
+// Validate checks the health check against the combinations NLB accepts
+func (c *HealthCheckConfig) Validate() error {
+	timeout, ok := HealthCheckTimeouts[c.Protocol]
+	if !ok {
+		return fmt.Errorf("health check protocol must be one of TCP, HTTP or HTTPS, got %q", c.Protocol)
+	}
+
+	if c.IntervalSeconds != 10 && c.IntervalSeconds != 30 {
+		return fmt.Errorf("health check interval must be 10 or 30 seconds, got %d", c.IntervalSeconds)
+	}
+
+	if c.TimeoutSeconds != timeout {
+		return fmt.Errorf("%s health checks have a fixed timeout of %d seconds, got %d", c.Protocol, timeout, c.TimeoutSeconds)
+	}
+
+	if c.HealthyThresholdCount < 2 || c.HealthyThresholdCount > 10 {
+		return fmt.Errorf("healthy threshold count must be between 2 and 10, got %d", c.HealthyThresholdCount)
+	}
+
+	if c.UnhealthyThresholdCount != c.HealthyThresholdCount {
+		return fmt.Errorf("unhealthy threshold count must equal the healthy threshold count %d, got %d", c.HealthyThresholdCount, c.UnhealthyThresholdCount)
+	}
+
+	if c.Path != "" && c.Protocol == HealthCheckProtocolTCP {
+		return fmt.Errorf("health check path is only supported for HTTP and HTTPS health checks")
+	}
+
+	if c.Path != "" && !strings.HasPrefix(c.Path, "/") {
+		return fmt.Errorf("health check path must start with /, got %q", c.Path)
+	}
+
+	if c.Port != "" && c.Port != HealthCheckPortTraffic {
+		if p, err := strconv.Atoi(c.Port); err != nil || p < 1 || p > 65535 {
+			return fmt.Errorf("health check port must be %s or a port number, got %q", HealthCheckPortTraffic, c.Port)
+		}
+	}
+
+	return nil
+}
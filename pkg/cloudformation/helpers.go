@@ -122,20 +122,52 @@ func DescribeStack(cfnSvc cloudformationiface.CloudFormationAPI, stackName strin
 }
 
 func GetResourceID(cfnSvc cloudformationiface.CloudFormationAPI, stackName string, logicalID string) (string, error) {
+	resourceIDs, err := GetResourceIDs(cfnSvc, stackName)
+	if err != nil {
+		return "", err
+	}
+
+	if resourceID, ok := resourceIDs[logicalID]; ok {
+		return resourceID, nil
+	}
+
+	return "", fmt.Errorf("resource %s not found", logicalID)
+}
+
+// GetResourceIDs returns the physical IDs of the resources of the stack by logical ID
+func GetResourceIDs(cfnSvc cloudformationiface.CloudFormationAPI, stackName string) (map[string]string, error) {
 	resources, err := cfnSvc.ListStackResources(&cloudformation.ListStackResourcesInput{
 		StackName: aws.String(stackName),
 	})
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
+	resourceIDs := map[string]string{}
 	for _, resourceSummary := range resources.StackResourceSummaries {
-		if *resourceSummary.LogicalResourceId == logicalID {
-			return *resourceSummary.PhysicalResourceId, nil
+		resourceIDs[aws.StringValue(resourceSummary.LogicalResourceId)] = aws.StringValue(resourceSummary.PhysicalResourceId)
+	}
+
+	return resourceIDs, nil
+}
+
+// FailedResources lists the resources that failed to delete with their reasons
+func FailedResources(cfnSvc cloudformationiface.CloudFormationAPI, stackName string) ([]string, error) {
+	out, err := cfnSvc.DescribeStackResources(&cloudformation.DescribeStackResourcesInput{
+		StackName: aws.String(stackName),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	failed := []string{}
+	for _, resource := range out.StackResources {
+		if aws.StringValue(resource.ResourceStatus) == cloudformation.ResourceStatusDeleteFailed {
+			failed = append(failed, fmt.Sprintf("%s: %s", aws.StringValue(resource.LogicalResourceId), aws.StringValue(resource.ResourceStatusReason)))
 		}
 	}
 
-	return "", fmt.Errorf("resource %s not found", logicalID)
+	return failed, nil
 }
 
 func StackOutputMap(stack *cloudformation.Stack) map[string]string {
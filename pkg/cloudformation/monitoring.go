@@ -0,0 +1,150 @@
+package cloudformation
+
+import (
+	"fmt"
+	"strings"
+
+	cfn "github.com/awslabs/goformation/v4/cloudformation"
+	"github.com/awslabs/goformation/v4/cloudformation/cloudwatch"
+)
+
+// Resource names and outputs of the CloudWatch alarms and dashboard
+const (
+	UnhealthyHostsAlarmResourceName = "UnhealthyHostsAlarm"
+	HealthyHostsAlarmResourceName   = "HealthyHostsAlarm"
+	TargetResetsAlarmResourceName   = "TargetResetsAlarm"
+	ELBResetsAlarmResourceName      = "ELBResetsAlarm"
+	DashboardResourceName           = "Dashboard"
+	OutputKeyMonitoring             = "Monitoring"
+	OutputKeyDashboardName          = "DashboardName"
+	NetworkELBNamespace             = "AWS/NetworkELB"
+)
+
+// MonitoringConfig describes the CloudWatch alarms and dashboard created for the NLB
+type MonitoringConfig struct {
+	Alarms                 bool     `json:"alarms"`
+	Dashboard              bool     `json:"dashboard,omitempty"`
+	AlarmActions           []string `json:"alarmActions,omitempty"`
+	UnhealthyHostThreshold int      `json:"unhealthyHostThreshold,omitempty"`
+	MinHealthyHosts        int      `json:"minHealthyHosts,omitempty"`
+	TCPResetThreshold      int      `json:"tcpResetThreshold,omitempty"`
+	PeriodSeconds          int      `json:"periodSeconds,omitempty"`
+	EvaluationPeriods      int      `json:"evaluationPeriods,omitempty"`
+}
+
+// DefaultMonitoringConfig alarms on any unhealthy target, no healthy targets or a burst of TCP resets for 3 minutes
+var DefaultMonitoringConfig = MonitoringConfig{
+	UnhealthyHostThreshold: 1,
+	MinHealthyHosts:        1,
+	TCPResetThreshold:      100,
+	PeriodSeconds:          60,
+	EvaluationPeriods:      3,
+}
+
+// Validate checks the thresholds and alarm actions CloudWatch accepts
+func (c *MonitoringConfig) Validate() error {
+	if !c.Alarms {
+		return nil
+	}
+
+	if c.UnhealthyHostThreshold < 1 {
+		return fmt.Errorf("unhealthy host threshold must be at least 1, got %d", c.UnhealthyHostThreshold)
+	}
+
+	if c.MinHealthyHosts < 1 {
+		return fmt.Errorf("minimum healthy hosts must be at least 1, got %d", c.MinHealthyHosts)
+	}
+
+	if c.TCPResetThreshold < 1 {
+		return fmt.Errorf("tcp reset threshold must be at least 1, got %d", c.TCPResetThreshold)
+	}
+
+	if c.PeriodSeconds != 10 && c.PeriodSeconds != 30 && (c.PeriodSeconds < 60 || c.PeriodSeconds%60 != 0) {
+		return fmt.Errorf("alarm period must be 10, 30 or a multiple of 60 seconds, got %d", c.PeriodSeconds)
+	}
+
+	if c.EvaluationPeriods < 1 {
+		return fmt.Errorf("alarm evaluation periods must be at least 1, got %d", c.EvaluationPeriods)
+	}
+
+	for _, action := range c.AlarmActions {
+		if !strings.HasPrefix(action, "arn:") {
+			return fmt.Errorf("alarm actions must be ARNs, got %q", action)
+		}
+	}
+
+	return nil
+}
+
+func loadBalancerDimension() cloudwatch.Alarm_Dimension {
+	return cloudwatch.Alarm_Dimension{Name: "LoadBalancer", Value: cfn.GetAtt(LoadBalancerResourceName, "LoadBalancerFullName")}
+}
+
+func targetGroupDimension() cloudwatch.Alarm_Dimension {
+	return cloudwatch.Alarm_Dimension{Name: "TargetGroup", Value: cfn.GetAtt(TargetGroupResourceName, "TargetGroupFullName")}
+}
+
+func buildAWSCloudWatchAlarm(config *MonitoringConfig, description string, metricName string, statistic string, comparison string, threshold int, dimensions ...cloudwatch.Alarm_Dimension) *cloudwatch.Alarm {
+	return &cloudwatch.Alarm{
+		AlarmDescription:   description,
+		Namespace:          NetworkELBNamespace,
+		MetricName:         metricName,
+		Dimensions:         dimensions,
+		Statistic:          statistic,
+		ComparisonOperator: comparison,
+		Threshold:          float64(threshold),
+		Period:             config.PeriodSeconds,
+		EvaluationPeriods:  config.EvaluationPeriods,
+		AlarmActions:       config.AlarmActions,
+		OKActions:          config.AlarmActions,
+		TreatMissingData:   "notBreaching",
+	}
+}
+
+// dashboardMetric is a metric line of a dashboard widget, the dimension values are resolved by Fn::Sub
+func dashboardMetric(metricName string, targetGroup bool) string {
+	dimensions := fmt.Sprintf(`"LoadBalancer","${%s.LoadBalancerFullName}"`, LoadBalancerResourceName)
+	if targetGroup {
+		dimensions = fmt.Sprintf(`"TargetGroup","${%s.TargetGroupFullName}",`, TargetGroupResourceName) + dimensions
+	}
+
+	return fmt.Sprintf(`["%s","%s",%s]`, NetworkELBNamespace, metricName, dimensions)
+}
+
+func dashboardWidget(x int, y int, title string, stat string, metrics ...string) string {
+	return fmt.Sprintf(`{"type":"metric","x":%d,"y":%d,"width":12,"height":6,"properties":{"title":"%s","region":"${%s}","stat":"%s","period":60,"metrics":[%s]}}`,
+		x, y, title, AWSRegion, stat, strings.Join(metrics, ","))
+}
+
+func buildAWSCloudWatchDashboard() *cloudwatch.Dashboard {
+	widgets := []string{
+		dashboardWidget(0, 0, "Target health", "Maximum", dashboardMetric("HealthyHostCount", true), dashboardMetric("UnHealthyHostCount", true)),
+		dashboardWidget(12, 0, "Flows", "Sum", dashboardMetric("ActiveFlowCount", false), dashboardMetric("NewFlowCount", false)),
+		dashboardWidget(0, 6, "TCP resets", "Sum", dashboardMetric("TCP_Client_Reset_Count", false), dashboardMetric("TCP_ELB_Reset_Count", false), dashboardMetric("TCP_Target_Reset_Count", false)),
+		dashboardWidget(12, 6, "Processed bytes", "Sum", dashboardMetric("ProcessedBytes", false)),
+	}
+
+	return &cloudwatch.Dashboard{
+		DashboardName: cfn.Ref(AWSStackName),
+		DashboardBody: escapedSub(fmt.Sprintf(`{"widgets":[%s]}`, strings.Join(widgets, ","))),
+	}
+}
+
+// addMonitoringResources adds the alarms and dashboard enabled in config to template
+func addMonitoringResources(template *cfn.Template, config *MonitoringConfig) {
+	if config.Alarms {
+		template.Resources[UnhealthyHostsAlarmResourceName] = buildAWSCloudWatchAlarm(config, "NLB targets are unhealthy",
+			"UnHealthyHostCount", "Maximum", "GreaterThanOrEqualToThreshold", config.UnhealthyHostThreshold, targetGroupDimension(), loadBalancerDimension())
+		template.Resources[HealthyHostsAlarmResourceName] = buildAWSCloudWatchAlarm(config, "NLB has too few healthy targets",
+			"HealthyHostCount", "Minimum", "LessThanThreshold", config.MinHealthyHosts, targetGroupDimension(), loadBalancerDimension())
+		template.Resources[TargetResetsAlarmResourceName] = buildAWSCloudWatchAlarm(config, "NLB targets are resetting TCP connections",
+			"TCP_Target_Reset_Count", "Sum", "GreaterThanThreshold", config.TCPResetThreshold, loadBalancerDimension())
+		template.Resources[ELBResetsAlarmResourceName] = buildAWSCloudWatchAlarm(config, "NLB is resetting TCP connections",
+			"TCP_ELB_Reset_Count", "Sum", "GreaterThanThreshold", config.TCPResetThreshold, loadBalancerDimension())
+	}
+
+	if config.Dashboard {
+		template.Resources[DashboardResourceName] = buildAWSCloudWatchDashboard()
+		template.Outputs[OutputKeyDashboardName] = Output{Value: cfn.Ref(DashboardResourceName)}
+	}
+}
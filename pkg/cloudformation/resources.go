@@ -0,0 +1,122 @@
+package cloudformation
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strings"
+
+	cfn "github.com/awslabs/goformation/v4/cloudformation"
+	"github.com/awslabs/goformation/v4/cloudformation/ec2"
+	"github.com/awslabs/goformation/v4/cloudformation/elasticloadbalancingv2"
+)
+
+// Listener is an elasticloadbalancingv2.Listener with the properties missing from the vendored goformation spec
+type Listener struct {
+	elasticloadbalancingv2.Listener
+	AlpnPolicy []string
+}
+
+// MarshalJSON renders the goformation listener and adds the extra properties when they are set
+func (r Listener) MarshalJSON() ([]byte, error) {
+	extra := map[string]interface{}{}
+	if len(r.AlpnPolicy) > 0 {
+		extra["AlpnPolicy"] = r.AlpnPolicy
+	}
+
+	return marshalWithProperties(r.Listener, extra)
+}
+
+// LoadBalancerSubnetMapping is an elasticloadbalancingv2.LoadBalancer_SubnetMapping with PrivateIPv4Address,
+// which is missing from the vendored goformation spec
+type LoadBalancerSubnetMapping struct {
+	AllocationId       string `json:"AllocationId,omitempty"`
+	PrivateIPv4Address string `json:"PrivateIPv4Address,omitempty"`
+	SubnetId           string `json:"SubnetId"`
+}
+
+// LoadBalancer is an elasticloadbalancingv2.LoadBalancer with the properties missing from the vendored goformation spec
+type LoadBalancer struct {
+	elasticloadbalancingv2.LoadBalancer
+	SubnetMappings []LoadBalancerSubnetMapping
+}
+
+// MarshalJSON renders the goformation load balancer and adds the extra properties when they are set
+func (r LoadBalancer) MarshalJSON() ([]byte, error) {
+	extra := map[string]interface{}{}
+	if len(r.SubnetMappings) > 0 {
+		extra["SubnetMappings"] = r.SubnetMappings
+	}
+
+	return marshalWithProperties(r.LoadBalancer, extra)
+}
+
+// VPCEndpointService is an ec2.VPCEndpointService that always renders AcceptanceRequired, goformation omits it when false
+type VPCEndpointService struct {
+	ec2.VPCEndpointService
+}
+
+// MarshalJSON renders the goformation endpoint service with an explicit AcceptanceRequired
+func (r VPCEndpointService) MarshalJSON() ([]byte, error) {
+	return marshalWithProperties(r.VPCEndpointService, map[string]interface{}{
+		"AcceptanceRequired": r.AcceptanceRequired,
+	})
+}
+
+// marshalWithProperties marshals a goformation resource and merges extra into its Properties
+func marshalWithProperties(resource interface{}, extra map[string]interface{}) ([]byte, error) {
+	b, err := json.Marshal(resource)
+	if err != nil || len(extra) == 0 {
+		return b, err
+	}
+
+	raw := map[string]json.RawMessage{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, err
+	}
+
+	properties := map[string]json.RawMessage{}
+	if len(raw["Properties"]) > 0 {
+		if err := json.Unmarshal(raw["Properties"], &properties); err != nil {
+			return nil, err
+		}
+	}
+
+	for key, value := range extra {
+		v, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		properties[key] = v
+	}
+
+	if raw["Properties"], err = json.Marshal(properties); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(raw)
+}
+
+var nonAlphanumeric = regexp.MustCompile("[^A-Za-z0-9]")
+
+// resourceName derives a stable logical ID from a readable name and the key it stands for.
+// The hash of key keeps names that only differ in punctuation apart.
+func resourceName(prefix string, name string, key string) string {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+
+	// Logical IDs are limited to 255 characters
+	name = nonAlphanumeric.ReplaceAllString(name, "")
+	if len(name) > 200 {
+		name = name[:200]
+	}
+
+	return fmt.Sprintf("%s%s%08x", prefix, name, h.Sum32())
+}
+
+// escapedSub is cfn.Sub for values with quotes or backslashes, which cfn.Sub does not escape
+func escapedSub(value string) string {
+	b, _ := json.Marshal(value)
+	return cfn.Sub(strings.Trim(string(b), `"`))
+}
@@ -0,0 +1,51 @@
+package cloudformation
+
+import (
+	cfn "github.com/awslabs/goformation/v4/cloudformation"
+	"github.com/awslabs/goformation/v4/cloudformation/elasticloadbalancingv2"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+)
+
+// Resource names and output key of the TCP listener passing TLS through to the reverse proxy, which terminates it
+// with the certificates of the ingress secrets
+const (
+	TLSTargetGroupResourceName          = "TLSTargetGroup"
+	TLSPassthroughListenerResourceName  = "TLSPassthroughListener"
+	TLSSecurityGroupIngressResourceName = "TLSSecurityGroupIngress"
+	OutputKeyTLS                        = "TLS"
+)
+
+// TLSOutput serializes the tls section of an ingress for the TLS output, empty without one
+// so stacks created before TLS termination in the proxy are not updated for it
+func TLSOutput(tls []extensionsv1beta1.IngressTLS) string {
+	if len(tls) == 0 {
+		return ""
+	}
+
+	return OutputValue(tls)
+}
+
+func buildAWSElasticLoadBalancingV2TLSPassthroughListener() *elasticloadbalancingv2.Listener {
+	return &elasticloadbalancingv2.Listener{
+		LoadBalancerArn: cfn.Ref(LoadBalancerResourceName),
+		Protocol:        "TCP",
+		Port:            443,
+		DefaultActions: []elasticloadbalancingv2.Listener_Action{
+			elasticloadbalancingv2.Listener_Action{
+				TargetGroupArn: cfn.Ref(TLSTargetGroupResourceName),
+				Type:           "forward",
+			},
+		},
+	}
+}
+
+// addTLSResources sends port 443 to the TLS port of the proxy through its own target group, with the health check of the
+// HTTP target group
+func addTLSResources(template *cfn.Template, cfg *TemplateConfig, healthCheck *HealthCheckConfig, sourceRanges []string) {
+	targetGroup := buildAWSElasticLoadBalancingV2TargetGroup(*cfg.Network.Vpc.VpcId, cfg.TargetType, cfg.Network.InstanceIDs, cfg.TLSNodePort, healthCheck, []string{LoadBalancerResourceName})
+	targetGroup.TargetGroupAttributes = buildTargetGroupAttributes(cfg.Attributes.TargetGroup)
+	template.Resources[TLSTargetGroupResourceName] = targetGroup
+	template.Resources[TLSPassthroughListenerResourceName] = buildAWSElasticLoadBalancingV2TLSPassthroughListener()
+
+	addSecurityGroupIngresses(template, TLSSecurityGroupIngressResourceName, cfg.Network.SecurityGroupIDs, sourceRanges, cfg.TLSNodePort)
+}
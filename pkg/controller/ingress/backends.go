@@ -0,0 +1,168 @@
+package ingress
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// ProxyAnnotationConfigChecksum records the checksum of the nginx.conf on the proxy pods, a new checksum rolls the pods
+// so nginx loads the new config
+const ProxyAnnotationConfigChecksum = "nlb.ingress.kubernetes.io/config-checksum"
+
+// findServicePort returns the port of the service a backend refers to, by name or by number
+func findServicePort(service *corev1.Service, port intstr.IntOrString) *corev1.ServicePort {
+	for i := range service.Spec.Ports {
+		servicePort := &service.Spec.Ports[i]
+		if port.Type == intstr.String && servicePort.Name == port.StrVal {
+			return servicePort
+		}
+
+		if port.Type == intstr.Int && servicePort.Port == port.IntVal {
+			return servicePort
+		}
+	}
+
+	return nil
+}
+
+// resolveBackends looks up the services of the backends of the ingress and resolves their ports to numbers.
+// Backends whose service or port does not exist are reported as events and left without an upstream.
+func (r *ReconcileIngress) resolveBackends(instance *extensionsv1beta1.Ingress) (backendUpstreams, error) {
+	upstreams := backendUpstreams{}
+	services := map[string]*corev1.Service{}
+	seen := map[string]bool{}
+	for _, backend := range getBackends(instance) {
+		key := backendKey(&backend)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		service, ok := services[backend.ServiceName]
+		if !ok {
+			service = &corev1.Service{}
+			err := r.Get(context.TODO(), k8stypes.NamespacedName{Name: backend.ServiceName, Namespace: instance.Namespace}, service)
+			if errors.IsNotFound(err) {
+				service = nil
+			} else if err != nil {
+				r.log.Error("unable to fetch backend service", zap.String("service", backend.ServiceName), zap.Error(err))
+				return nil, err
+			}
+			services[backend.ServiceName] = service
+		}
+
+		if service == nil {
+			r.recorder.Event(instance, corev1.EventTypeWarning, "BackendServiceNotFound", fmt.Sprintf("service %s of backend %s not found", backend.ServiceName, key))
+			continue
+		}
+
+		// ExternalName services have no ports to look up
+		if service.Spec.Type == corev1.ServiceTypeExternalName && backend.ServicePort.Type == intstr.Int {
+			upstreams[key] = fmt.Sprintf("%s:%d", backend.ServiceName, backend.ServicePort.IntVal)
+			continue
+		}
+
+		port := findServicePort(service, backend.ServicePort)
+		if port == nil {
+			r.recorder.Event(instance, corev1.EventTypeWarning, "BackendPortNotFound", fmt.Sprintf("service %s has no port %s", backend.ServiceName, backend.ServicePort.String()))
+			continue
+		}
+
+		upstreams[key] = fmt.Sprintf("%s:%d", backend.ServiceName, port.Port)
+	}
+
+	return upstreams, nil
+}
+
+// syncProxyConfig renders the nginx.conf with the current backend services. When it changed without a change to the ingress,
+// like a service port that was renamed or renumbered, it updates the config and rolls the proxy pods.
+func (r *ReconcileIngress) syncProxyConfig(instance *extensionsv1beta1.Ingress) error {
+	name := k8stypes.NamespacedName{Name: createReverseProxyResourceName(instance.Name), Namespace: instance.Namespace}
+
+	// The proxy is created with the load balancer
+	configMap := &corev1.ConfigMap{}
+	if err := r.Get(context.TODO(), name, configMap); errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		r.log.Error("unable to fetch proxy config", zap.Error(err))
+		return err
+	}
+
+	upstreams, err := r.resolveBackends(instance)
+	if err != nil {
+		return err
+	}
+
+	config := buildNginxConfig(instance, upstreams)
+	if configMap.Data["nginx.conf"] == config {
+		return nil
+	}
+
+	r.log.Info("backend services changed, updating proxy config", zap.String("name", configMap.Name))
+	if configMap.Data == nil {
+		configMap.Data = map[string]string{}
+	}
+	configMap.Data["nginx.conf"] = config
+	if err := r.Update(context.TODO(), configMap); err != nil {
+		return err
+	}
+
+	deploy := &appsv1.Deployment{}
+	if err := r.Get(context.TODO(), name, deploy); err != nil {
+		r.log.Error("unable to fetch proxy deployment", zap.Error(err))
+		return err
+	}
+
+	checksum := sha256.Sum256([]byte(config))
+	if deploy.Spec.Template.ObjectMeta.Annotations == nil {
+		deploy.Spec.Template.ObjectMeta.Annotations = map[string]string{}
+	}
+	deploy.Spec.Template.ObjectMeta.Annotations[ProxyAnnotationConfigChecksum] = hex.EncodeToString(checksum[:])
+	return r.Update(context.TODO(), deploy)
+}
+
+// usesService tests if a backend of the ingress is on the service
+func usesService(instance *extensionsv1beta1.Ingress, serviceName string) bool {
+	for _, backend := range getBackends(instance) {
+		if backend.ServiceName == serviceName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// mapServiceToIngresses returns a handler enqueuing the nlb ingresses of the namespace of a service with a backend on it
+func mapServiceToIngresses(c client.Client) handler.ToRequestsFunc {
+	return func(o handler.MapObject) []reconcile.Request {
+		ingresses := &extensionsv1beta1.IngressList{}
+		if err := c.List(context.TODO(), ingresses, client.InNamespace(o.Meta.GetNamespace())); err != nil {
+			return nil
+		}
+
+		requests := []reconcile.Request{}
+		for i := range ingresses.Items {
+			ingress := &ingresses.Items[i]
+			if ingress.Annotations[IngressClassAnnotation] == "nlb" && usesService(ingress, o.Meta.GetName()) {
+				requests = append(requests, reconcile.Request{
+					NamespacedName: k8stypes.NamespacedName{Name: ingress.Name, Namespace: ingress.Namespace},
+				})
+			}
+		}
+
+		return requests
+	}
+}
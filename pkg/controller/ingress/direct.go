@@ -0,0 +1,959 @@
+package ingress
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+	cfn "github.com/danushkaf/aws-nlb-ingress-controller/pkg/cloudformation"
+	"go.uber.org/zap"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+)
+
+// Tags the direct provisioner puts on the load balancers and target groups it owns
+const (
+	directTagManagedBy      = "managedBy"
+	directTagIngress        = "nlb.ingress.kubernetes.io/ingress"
+	directTagSecurityGroups = "nlb.ingress.kubernetes.io/security-groups"
+	directManagedBy         = "aws-nlb-ingress-controller"
+
+	// Load balancer and target group names are limited to 32 characters, the ingress name is shortened to fit
+	// a hash of the namespaced name and the target group port
+	directNameLength = 17
+)
+
+// directProvisioner manages the load balancer, target group, listeners and security group rules through the ELBv2 and EC2 APIs.
+// It owns the resources tagged with the namespaced name of the ingress and records the applied configuration on the ingress,
+// in place of the stack outputs.
+type directProvisioner struct {
+	elbv2Svc elbv2iface.ELBV2API
+	ec2Svc   ec2iface.EC2API
+	log      *zap.Logger
+}
+
+// provisionsDirectly tests if the load balancers are managed through the ELBv2 and EC2 APIs, which delete without waiting
+func (r *ReconcileIngress) provisionsDirectly() bool {
+	_, ok := r.provisioner.(*directProvisioner)
+	return ok
+}
+
+// directOwner is the value of the ownership tag, the namespaced name of the ingress
+func directOwner(instance *extensionsv1beta1.Ingress) string {
+	return fmt.Sprintf("%s/%s", instance.Namespace, instance.Name)
+}
+
+// directLoadBalancerName derives the load balancer name from the ingress, the hash keeps ingresses with a common prefix apart
+func directLoadBalancerName(instance *extensionsv1beta1.Ingress) string {
+	h := fnv.New32a()
+	h.Write([]byte(directOwner(instance)))
+
+	name := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '-'
+	}, instance.Name)
+	if len(name) > directNameLength {
+		name = name[:directNameLength]
+	}
+
+	return fmt.Sprintf("%s-%08x", strings.Trim(name, "-"), h.Sum32())
+}
+
+// directTargetGroupName derives the target group name from the ingress. The port is part of the name since
+// a target group can not change its port, a new NodePort gets a new target group.
+func directTargetGroupName(instance *extensionsv1beta1.Ingress, port int) string {
+	return fmt.Sprintf("%s-%d", directLoadBalancerName(instance), port)
+}
+
+// directTargetGroupOwned tests if the target group name was derived from the ingress
+func directTargetGroupOwned(instance *extensionsv1beta1.Ingress, name string) bool {
+	return strings.HasPrefix(name, directLoadBalancerName(instance)+"-")
+}
+
+// directRuleDescription marks the security group rules owned by the ingress, rules can not be tagged
+func directRuleDescription(instance *extensionsv1beta1.Ingress) string {
+	return fmt.Sprintf("%s %s", directManagedBy, directOwner(instance))
+}
+
+func directTags(instance *extensionsv1beta1.Ingress) []*elbv2.Tag {
+	return []*elbv2.Tag{
+		{Key: aws.String(directTagManagedBy), Value: aws.String(directManagedBy)},
+		{Key: aws.String(directTagIngress), Value: aws.String(directOwner(instance))},
+	}
+}
+
+// validateDirectConfig rejects the features only the CloudFormation provisioner supports
+func validateDirectConfig(cfg *cfn.TemplateConfig) error {
+	unsupported := ""
+	switch {
+	case cfg.Listeners.ALPNPolicy != "":
+		unsupported = IngressAnnotationALPNPolicy
+	case cfg.DNS.HostedZoneID != "":
+		unsupported = IngressAnnotationHostedZoneID
+	case cfg.EndpointService.Enabled:
+		unsupported = IngressAnnotationEndpointService
+	case cfg.APIGateway.Enabled:
+		unsupported = IngressAnnotationAPIGateway
+	case cfg.Monitoring.Alarms:
+		unsupported = IngressAnnotationCloudWatchAlarms
+	case cfg.Monitoring.Dashboard:
+		unsupported = IngressAnnotationCloudWatchDashboard
+	case len(cfg.TLS) > 0:
+		unsupported = "spec.tls"
+	}
+
+	if unsupported != "" {
+		return fmt.Errorf("%s needs the %s provisioner", unsupported, ProvisionerCloudFormation)
+	}
+
+	return nil
+}
+
+// getAppliedConfig returns the outputs recorded on the ingress by the last apply
+func getAppliedConfig(instance *extensionsv1beta1.Ingress) map[string]string {
+	outputs := map[string]string{}
+	if value, ok := instance.ObjectMeta.Annotations[IngressAnnotationAppliedConfig]; ok {
+		json.Unmarshal([]byte(value), &outputs)
+	}
+
+	return outputs
+}
+
+func isAWSErrorCode(err error, code string) bool {
+	aErr, ok := err.(awserr.Error)
+	return ok && aErr.Code() == code
+}
+
+// describe reports the load balancer as a stack. Target groups left behind by the load balancer are reported as a delete in progress
+// while the ingress is deleted, otherwise as a failed delete, so the reconciler deletes them again.
+func (p *directProvisioner) describe(instance *extensionsv1beta1.Ingress) (*cloudformation.Stack, error) {
+	lb, err := p.loadBalancer(instance)
+	if err != nil {
+		return nil, err
+	}
+
+	if lb == nil {
+		targetGroups, err := p.targetGroups(instance)
+		if err != nil || len(targetGroups) == 0 {
+			return nil, err
+		}
+
+		if !instance.ObjectMeta.DeletionTimestamp.IsZero() {
+			return &cloudformation.Stack{
+				StackName:         aws.String(instance.Name),
+				StackStatus:       aws.String(cloudformation.StackStatusDeleteInProgress),
+				StackStatusReason: aws.String(fmt.Sprintf("deleting %d target groups left by the load balancer", len(targetGroups))),
+			}, nil
+		}
+
+		return &cloudformation.Stack{
+			StackName:         aws.String(instance.Name),
+			StackStatus:       aws.String(cloudformation.StackStatusDeleteFailed),
+			StackStatusReason: aws.String(fmt.Sprintf("%d target groups left without a load balancer", len(targetGroups))),
+		}, nil
+	}
+
+	status := cloudformation.StackStatusCreateComplete
+	switch aws.StringValue(lb.State.Code) {
+	case elbv2.LoadBalancerStateEnumProvisioning:
+		status = cloudformation.StackStatusCreateInProgress
+	case elbv2.LoadBalancerStateEnumFailed:
+		status = cloudformation.StackStatusCreateFailed
+	}
+
+	// The scheme and address type are read back from the load balancer, everything else is what was applied
+	outputs := getAppliedConfig(instance)
+	outputs[cfn.OutputKeyNLBEndpoint] = aws.StringValue(lb.DNSName)
+	outputs[cfn.OutputKeyScheme] = aws.StringValue(lb.Scheme)
+	outputs[cfn.OutputKeyIPAddressType] = aws.StringValue(lb.IpAddressType)
+
+	stack := &cloudformation.Stack{
+		StackName:         aws.String(instance.Name),
+		StackStatus:       aws.String(status),
+		StackStatusReason: lb.State.Reason,
+	}
+	for _, key := range getSortedKeys(outputs) {
+		stack.Outputs = append(stack.Outputs, &cloudformation.Output{OutputKey: aws.String(key), OutputValue: aws.String(outputs[key])})
+	}
+
+	return stack, nil
+}
+
+func (p *directProvisioner) create(instance *extensionsv1beta1.Ingress, cfg *cfn.TemplateConfig) error {
+	return p.apply(instance, cfg)
+}
+
+// update applies changes right away, there is no approval step
+func (p *directProvisioner) update(instance *extensionsv1beta1.Ingress, cfg *cfn.TemplateConfig) (updateStatus, error) {
+	return updateApplied, p.apply(instance, cfg)
+}
+
+// apply creates or updates every resource of the load balancer to match cfg
+func (p *directProvisioner) apply(instance *extensionsv1beta1.Ingress, cfg *cfn.TemplateConfig) error {
+	cfg = cfg.WithDefaults()
+	if err := validateDirectConfig(cfg); err != nil {
+		return err
+	}
+
+	targetGroup, err := p.applyTargetGroup(instance, cfg)
+	if err != nil {
+		return err
+	}
+
+	lb, err := p.applyLoadBalancer(instance, cfg)
+	if err != nil {
+		return err
+	}
+
+	if err := p.applyListeners(lb, targetGroup, cfg.Listeners); err != nil {
+		return err
+	}
+
+	// Target groups of earlier NodePorts are no longer used by the listeners
+	if err := p.deleteTargetGroups(instance, aws.StringValue(targetGroup.TargetGroupArn)); err != nil {
+		return err
+	}
+
+	if err := p.applySecurityGroupRules(instance, lb, cfg); err != nil {
+		return err
+	}
+
+	if instance.ObjectMeta.Annotations == nil {
+		instance.ObjectMeta.Annotations = map[string]string{}
+	}
+	instance.ObjectMeta.Annotations[IngressAnnotationAppliedConfig] = cfn.OutputValue(cfg.Outputs())
+	return nil
+}
+
+// delete deletes the load balancer without waiting for it to go, the reconciler calls it again until it has. The target groups
+// can not be deleted while the load balancer uses them, so they carry its security groups and are deleted with their rules on a later pass.
+func (p *directProvisioner) delete(instance *extensionsv1beta1.Ingress) error {
+	lb, err := p.loadBalancer(instance)
+	if err != nil {
+		return err
+	}
+
+	targetGroups, err := p.targetGroups(instance)
+	if err != nil {
+		return err
+	}
+
+	if lb != nil {
+		tags, err := p.checkOwner(instance, lb.LoadBalancerArn)
+		if err != nil {
+			return err
+		}
+
+		if len(targetGroups) > 0 && tags[directTagSecurityGroups] != "" {
+			arns := []*string{}
+			for _, targetGroup := range targetGroups {
+				arns = append(arns, targetGroup.TargetGroupArn)
+			}
+
+			if _, err := p.elbv2Svc.AddTags(&elbv2.AddTagsInput{
+				ResourceArns: arns,
+				Tags:         []*elbv2.Tag{{Key: aws.String(directTagSecurityGroups), Value: aws.String(tags[directTagSecurityGroups])}},
+			}); err != nil {
+				return err
+			}
+		}
+
+		p.log.Info("deleting load balancer", zap.String("loadBalancer", aws.StringValue(lb.LoadBalancerName)))
+		_, err = p.elbv2Svc.DeleteLoadBalancer(&elbv2.DeleteLoadBalancerInput{
+			LoadBalancerArn: lb.LoadBalancerArn,
+		})
+		return err
+	}
+
+	for _, targetGroup := range targetGroups {
+		if err := p.revokeSecurityGroupRules(instance, targetGroup.TargetGroupArn, nil); err != nil {
+			return err
+		}
+
+		p.log.Info("deleting target group", zap.String("targetGroup", aws.StringValue(targetGroup.TargetGroupName)))
+		_, err := p.elbv2Svc.DeleteTargetGroup(&elbv2.DeleteTargetGroupInput{
+			TargetGroupArn: targetGroup.TargetGroupArn,
+		})
+		if isAWSErrorCode(err, elbv2.ErrCodeResourceInUseException) {
+			p.log.Info("target group still in use by the deleted load balancer", zap.String("targetGroup", aws.StringValue(targetGroup.TargetGroupName)))
+			continue
+		} else if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// targetGroupARNs returns the target group the listeners forward to, the direct provisioner has no TLS target group
+func (p *directProvisioner) targetGroupARNs(instance *extensionsv1beta1.Ingress) (string, string, error) {
+	lb, err := p.loadBalancer(instance)
+	if err != nil {
+		return "", "", err
+	}
+
+	if lb == nil {
+		return "", "", fmt.Errorf("load balancer %s does not exist", directLoadBalancerName(instance))
+	}
+
+	out, err := p.elbv2Svc.DescribeListeners(&elbv2.DescribeListenersInput{
+		LoadBalancerArn: lb.LoadBalancerArn,
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	for _, listener := range out.Listeners {
+		for _, action := range listener.DefaultActions {
+			if action.TargetGroupArn != nil {
+				return aws.StringValue(action.TargetGroupArn), "", nil
+			}
+		}
+	}
+
+	return "", "", fmt.Errorf("load balancer %s has no listener forwarding to a target group", directLoadBalancerName(instance))
+}
+
+func (p *directProvisioner) subnetIDs(instance *extensionsv1beta1.Ingress) ([]string, error) {
+	lb, err := p.loadBalancer(instance)
+	if err != nil || lb == nil {
+		return nil, err
+	}
+
+	return loadBalancerSubnetIDs([]*elbv2.LoadBalancer{lb}), nil
+}
+
+// checkOwner makes sure the resource is tagged with the ingress, so a name collision never touches someone else's load balancer
+func (p *directProvisioner) checkOwner(instance *extensionsv1beta1.Ingress, arn *string) (map[string]string, error) {
+	out, err := p.elbv2Svc.DescribeTags(&elbv2.DescribeTagsInput{
+		ResourceArns: []*string{arn},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tags := map[string]string{}
+	for _, description := range out.TagDescriptions {
+		for _, tag := range description.Tags {
+			tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+		}
+	}
+
+	if tags[directTagIngress] != directOwner(instance) {
+		return nil, fmt.Errorf("%s is not owned by ingress %s", aws.StringValue(arn), directOwner(instance))
+	}
+
+	return tags, nil
+}
+
+// loadBalancer returns the load balancer of the ingress, nil when it does not exist
+func (p *directProvisioner) loadBalancer(instance *extensionsv1beta1.Ingress) (*elbv2.LoadBalancer, error) {
+	out, err := p.elbv2Svc.DescribeLoadBalancers(&elbv2.DescribeLoadBalancersInput{
+		Names: aws.StringSlice([]string{directLoadBalancerName(instance)}),
+	})
+	if isAWSErrorCode(err, elbv2.ErrCodeLoadBalancerNotFoundException) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if len(out.LoadBalancers) == 0 {
+		return nil, nil
+	}
+
+	lb := out.LoadBalancers[0]
+	if _, err := p.checkOwner(instance, lb.LoadBalancerArn); err != nil {
+		return nil, err
+	}
+
+	return lb, nil
+}
+
+// targetGroups returns the target groups of the ingress
+func (p *directProvisioner) targetGroups(instance *extensionsv1beta1.Ingress) ([]*elbv2.TargetGroup, error) {
+	targetGroups := []*elbv2.TargetGroup{}
+	err := p.elbv2Svc.DescribeTargetGroupsPages(&elbv2.DescribeTargetGroupsInput{}, func(page *elbv2.DescribeTargetGroupsOutput, lastPage bool) bool {
+		for _, targetGroup := range page.TargetGroups {
+			if directTargetGroupOwned(instance, aws.StringValue(targetGroup.TargetGroupName)) {
+				targetGroups = append(targetGroups, targetGroup)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	owned := []*elbv2.TargetGroup{}
+	for _, targetGroup := range targetGroups {
+		if _, err := p.checkOwner(instance, targetGroup.TargetGroupArn); err == nil {
+			owned = append(owned, targetGroup)
+		}
+	}
+
+	return owned, nil
+}
+
+// deleteTargetGroups deletes the target groups of the ingress except keepARN
+func (p *directProvisioner) deleteTargetGroups(instance *extensionsv1beta1.Ingress, keepARN string) error {
+	targetGroups, err := p.targetGroups(instance)
+	if err != nil {
+		return err
+	}
+
+	for _, targetGroup := range targetGroups {
+		if aws.StringValue(targetGroup.TargetGroupArn) == keepARN {
+			continue
+		}
+
+		p.log.Info("deleting target group", zap.String("targetGroup", aws.StringValue(targetGroup.TargetGroupName)))
+		if _, err := p.elbv2Svc.DeleteTargetGroup(&elbv2.DeleteTargetGroupInput{
+			TargetGroupArn: targetGroup.TargetGroupArn,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *directProvisioner) applyTargetGroup(instance *extensionsv1beta1.Ingress, cfg *cfn.TemplateConfig) (*elbv2.TargetGroup, error) {
+	name := directTargetGroupName(instance, cfg.NodePort)
+	healthCheck := cfg.TargetHealthCheck()
+
+	var targetGroup *elbv2.TargetGroup
+	out, err := p.elbv2Svc.DescribeTargetGroups(&elbv2.DescribeTargetGroupsInput{
+		Names: aws.StringSlice([]string{name}),
+	})
+	if err != nil && !isAWSErrorCode(err, elbv2.ErrCodeTargetGroupNotFoundException) {
+		return nil, err
+	} else if err == nil && len(out.TargetGroups) > 0 {
+		targetGroup = out.TargetGroups[0]
+	}
+
+	if targetGroup == nil {
+		p.log.Info("creating target group", zap.String("targetGroup", name))
+		created, err := p.elbv2Svc.CreateTargetGroup(&elbv2.CreateTargetGroupInput{
+			Name:                       aws.String(name),
+			Protocol:                   aws.String(elbv2.ProtocolEnumTcp),
+			Port:                       aws.Int64(int64(cfg.NodePort)),
+			VpcId:                      cfg.Network.Vpc.VpcId,
+			TargetType:                 aws.String(cfg.TargetType),
+			HealthCheckProtocol:        aws.String(healthCheck.Protocol),
+			HealthCheckPort:            aws.String(healthCheck.Port),
+			HealthCheckPath:            optionalString(healthCheck.Path),
+			HealthCheckIntervalSeconds: aws.Int64(int64(healthCheck.IntervalSeconds)),
+			HealthCheckTimeoutSeconds:  aws.Int64(int64(healthCheck.TimeoutSeconds)),
+			HealthyThresholdCount:      aws.Int64(int64(healthCheck.HealthyThresholdCount)),
+			UnhealthyThresholdCount:    aws.Int64(int64(healthCheck.UnhealthyThresholdCount)),
+		})
+		if err != nil {
+			return nil, err
+		}
+		targetGroup = created.TargetGroups[0]
+
+		// Target groups can not be tagged on creation with this version of the API
+		if _, err := p.elbv2Svc.AddTags(&elbv2.AddTagsInput{
+			ResourceArns: []*string{targetGroup.TargetGroupArn},
+			Tags:         directTags(instance),
+		}); err != nil {
+			return nil, err
+		}
+	} else {
+		if _, err := p.checkOwner(instance, targetGroup.TargetGroupArn); err != nil {
+			return nil, err
+		}
+
+		if _, err := p.elbv2Svc.ModifyTargetGroup(&elbv2.ModifyTargetGroupInput{
+			TargetGroupArn:             targetGroup.TargetGroupArn,
+			HealthCheckProtocol:        aws.String(healthCheck.Protocol),
+			HealthCheckPort:            aws.String(healthCheck.Port),
+			HealthCheckPath:            optionalString(healthCheck.Path),
+			HealthCheckIntervalSeconds: aws.Int64(int64(healthCheck.IntervalSeconds)),
+			HealthyThresholdCount:      aws.Int64(int64(healthCheck.HealthyThresholdCount)),
+			UnhealthyThresholdCount:    aws.Int64(int64(healthCheck.UnhealthyThresholdCount)),
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(cfg.Attributes.TargetGroup) > 0 {
+		attributes := []*elbv2.TargetGroupAttribute{}
+		for _, key := range getSortedKeys(cfg.Attributes.TargetGroup) {
+			attributes = append(attributes, &elbv2.TargetGroupAttribute{Key: aws.String(key), Value: aws.String(cfg.Attributes.TargetGroup[key])})
+		}
+
+		if _, err := p.elbv2Svc.ModifyTargetGroupAttributes(&elbv2.ModifyTargetGroupAttributesInput{
+			TargetGroupArn: targetGroup.TargetGroupArn,
+			Attributes:     attributes,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	// ip targets are the proxy pods, the reconciler registers them
+	if cfg.TargetType == cfn.TargetTypeInstance {
+		if err := p.applyInstanceTargets(targetGroup, cfg.Network.InstanceIDs); err != nil {
+			return nil, err
+		}
+	}
+
+	return targetGroup, nil
+}
+
+// applyInstanceTargets registers the worker nodes with the target group and deregisters the ones that are gone
+func (p *directProvisioner) applyInstanceTargets(targetGroup *elbv2.TargetGroup, instanceIDs []string) error {
+	health, err := p.elbv2Svc.DescribeTargetHealth(&elbv2.DescribeTargetHealthInput{
+		TargetGroupArn: targetGroup.TargetGroupArn,
+	})
+	if err != nil {
+		return err
+	}
+
+	desired := map[string]bool{}
+	for _, id := range instanceIDs {
+		desired[id] = true
+	}
+
+	current := map[string]bool{}
+	deregister := []*elbv2.TargetDescription{}
+	for _, description := range health.TargetHealthDescriptions {
+		id := aws.StringValue(description.Target.Id)
+		current[id] = true
+		if !desired[id] {
+			deregister = append(deregister, description.Target)
+		}
+	}
+
+	register := []*elbv2.TargetDescription{}
+	for _, id := range getListFromMap(desired) {
+		if !current[id] {
+			register = append(register, &elbv2.TargetDescription{Id: aws.String(id)})
+		}
+	}
+
+	if len(register) > 0 {
+		if _, err := p.elbv2Svc.RegisterTargets(&elbv2.RegisterTargetsInput{
+			TargetGroupArn: targetGroup.TargetGroupArn,
+			Targets:        register,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if len(deregister) > 0 {
+		if _, err := p.elbv2Svc.DeregisterTargets(&elbv2.DeregisterTargetsInput{
+			TargetGroupArn: targetGroup.TargetGroupArn,
+			Targets:        deregister,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applyLoadBalancer creates the load balancer or updates its subnets, address type and attributes.
+// The scheme and addresses can not change, the reconciler replaces the load balancer for those.
+func (p *directProvisioner) applyLoadBalancer(instance *extensionsv1beta1.Ingress, cfg *cfn.TemplateConfig) (*elbv2.LoadBalancer, error) {
+	lb, err := p.loadBalancer(instance)
+	if err != nil {
+		return nil, err
+	}
+
+	if lb == nil {
+		input := &elbv2.CreateLoadBalancerInput{
+			Name:          aws.String(directLoadBalancerName(instance)),
+			Type:          aws.String(elbv2.LoadBalancerTypeEnumNetwork),
+			Scheme:        aws.String(cfg.Scheme),
+			IpAddressType: aws.String(cfg.IPAddressType),
+			Tags:          directTags(instance),
+		}
+
+		// Subnets and SubnetMappings are mutually exclusive
+		if len(cfg.Network.SubnetMappings) == 0 {
+			input.Subnets = aws.StringSlice(cfg.Network.SubnetIDs)
+		}
+		for _, mapping := range cfg.Network.SubnetMappings {
+			input.SubnetMappings = append(input.SubnetMappings, &elbv2.SubnetMapping{
+				SubnetId:           aws.String(mapping.SubnetID),
+				AllocationId:       optionalString(mapping.AllocationID),
+				PrivateIPv4Address: optionalString(mapping.PrivateIPv4Address),
+			})
+		}
+
+		p.log.Info("creating load balancer", zap.String("loadBalancer", directLoadBalancerName(instance)))
+		out, err := p.elbv2Svc.CreateLoadBalancer(input)
+		if err != nil {
+			return nil, err
+		}
+		lb = out.LoadBalancers[0]
+	} else {
+		if aws.StringValue(lb.IpAddressType) != cfg.IPAddressType {
+			if _, err := p.elbv2Svc.SetIpAddressType(&elbv2.SetIpAddressTypeInput{
+				LoadBalancerArn: lb.LoadBalancerArn,
+				IpAddressType:   aws.String(cfg.IPAddressType),
+			}); err != nil {
+				return nil, err
+			}
+		}
+
+		current := []string{}
+		for _, zone := range lb.AvailabilityZones {
+			current = append(current, aws.StringValue(zone.SubnetId))
+		}
+		sort.Strings(current)
+
+		desired := append([]string{}, cfg.Network.SubnetIDs...)
+		sort.Strings(desired)
+
+		if len(cfg.Network.SubnetMappings) == 0 && strings.Join(current, ",") != strings.Join(desired, ",") {
+			if _, err := p.elbv2Svc.SetSubnets(&elbv2.SetSubnetsInput{
+				LoadBalancerArn: lb.LoadBalancerArn,
+				Subnets:         aws.StringSlice(desired),
+			}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(cfg.Attributes.LoadBalancer) > 0 {
+		attributes := []*elbv2.LoadBalancerAttribute{}
+		for _, key := range getSortedKeys(cfg.Attributes.LoadBalancer) {
+			attributes = append(attributes, &elbv2.LoadBalancerAttribute{Key: aws.String(key), Value: aws.String(cfg.Attributes.LoadBalancer[key])})
+		}
+
+		if _, err := p.elbv2Svc.ModifyLoadBalancerAttributes(&elbv2.ModifyLoadBalancerAttributesInput{
+			LoadBalancerArn: lb.LoadBalancerArn,
+			Attributes:      attributes,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	return lb, nil
+}
+
+// applyListeners creates, updates and deletes the TCP listener on port 80 and the TLS listener on port 443
+func (p *directProvisioner) applyListeners(lb *elbv2.LoadBalancer, targetGroup *elbv2.TargetGroup, listeners *cfn.ListenerConfig) error {
+	out, err := p.elbv2Svc.DescribeListeners(&elbv2.DescribeListenersInput{
+		LoadBalancerArn: lb.LoadBalancerArn,
+	})
+	if err != nil {
+		return err
+	}
+
+	current := map[int64]*elbv2.Listener{}
+	for _, listener := range out.Listeners {
+		current[aws.Int64Value(listener.Port)] = listener
+	}
+
+	actions := []*elbv2.Action{
+		{Type: aws.String(elbv2.ActionTypeEnumForward), TargetGroupArn: targetGroup.TargetGroupArn},
+	}
+
+	desired := map[int64]*elbv2.CreateListenerInput{}
+	if listeners.TCP {
+		desired[80] = &elbv2.CreateListenerInput{
+			LoadBalancerArn: lb.LoadBalancerArn,
+			Protocol:        aws.String(elbv2.ProtocolEnumTcp),
+			Port:            aws.Int64(80),
+			DefaultActions:  actions,
+		}
+	}
+
+	if len(listeners.CertificateARNs) > 0 {
+		desired[443] = &elbv2.CreateListenerInput{
+			LoadBalancerArn: lb.LoadBalancerArn,
+			Protocol:        aws.String(elbv2.ProtocolEnumTls),
+			Port:            aws.Int64(443),
+			SslPolicy:       optionalString(listeners.SSLPolicy),
+			Certificates:    []*elbv2.Certificate{{CertificateArn: aws.String(listeners.CertificateARNs[0])}},
+			DefaultActions:  actions,
+		}
+	}
+
+	for port, listener := range current {
+		if desired[port] == nil {
+			if _, err := p.elbv2Svc.DeleteListener(&elbv2.DeleteListenerInput{
+				ListenerArn: listener.ListenerArn,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, port := range []int64{80, 443} {
+		input := desired[port]
+		if input == nil {
+			continue
+		}
+
+		var listenerARN *string
+		if listener := current[port]; listener == nil {
+			created, err := p.elbv2Svc.CreateListener(input)
+			if err != nil {
+				return err
+			}
+			listenerARN = created.Listeners[0].ListenerArn
+		} else {
+			listenerARN = listener.ListenerArn
+			if _, err := p.elbv2Svc.ModifyListener(&elbv2.ModifyListenerInput{
+				ListenerArn:    listenerARN,
+				Protocol:       input.Protocol,
+				Port:           input.Port,
+				SslPolicy:      input.SslPolicy,
+				Certificates:   input.Certificates,
+				DefaultActions: input.DefaultActions,
+			}); err != nil {
+				return err
+			}
+		}
+
+		if port == 443 {
+			if err := p.applyListenerCertificates(listenerARN, listeners.CertificateARNs[1:]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// applyListenerCertificates keeps the certificates served through SNI in sync with certificateARNs
+func (p *directProvisioner) applyListenerCertificates(listenerARN *string, certificateARNs []string) error {
+	out, err := p.elbv2Svc.DescribeListenerCertificates(&elbv2.DescribeListenerCertificatesInput{
+		ListenerArn: listenerARN,
+	})
+	if err != nil {
+		return err
+	}
+
+	desired := map[string]bool{}
+	for _, certificateARN := range certificateARNs {
+		desired[certificateARN] = true
+	}
+
+	current := map[string]bool{}
+	remove := []*elbv2.Certificate{}
+	for _, certificate := range out.Certificates {
+		if aws.BoolValue(certificate.IsDefault) {
+			continue
+		}
+
+		current[aws.StringValue(certificate.CertificateArn)] = true
+		if !desired[aws.StringValue(certificate.CertificateArn)] {
+			remove = append(remove, &elbv2.Certificate{CertificateArn: certificate.CertificateArn})
+		}
+	}
+
+	add := []*elbv2.Certificate{}
+	for _, certificateARN := range certificateARNs {
+		if !current[certificateARN] {
+			add = append(add, &elbv2.Certificate{CertificateArn: aws.String(certificateARN)})
+		}
+	}
+
+	if len(add) > 0 {
+		if _, err := p.elbv2Svc.AddListenerCertificates(&elbv2.AddListenerCertificatesInput{
+			ListenerArn:  listenerARN,
+			Certificates: add,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if len(remove) > 0 {
+		if _, err := p.elbv2Svc.RemoveListenerCertificates(&elbv2.RemoveListenerCertificatesInput{
+			ListenerArn:  listenerARN,
+			Certificates: remove,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// securityGroupRule is an ingress rule for a single port from a single CIDR
+type securityGroupRule struct {
+	GroupID string
+	CIDR    string
+	Port    int64
+}
+
+// desiredSecurityGroupRules are the rules the template would create, traffic from the source ranges and health checks from the vpc
+func desiredSecurityGroupRules(cfg *cfn.TemplateConfig) map[securityGroupRule]bool {
+	vpcRanges := cfg.VpcRanges()
+	sourceRanges := cfg.SourceRanges
+	if len(sourceRanges) == 0 {
+		sourceRanges = vpcRanges
+	}
+
+	rules := map[securityGroupRule]bool{}
+	for _, groupID := range cfg.Network.SecurityGroupIDs {
+		for _, cidr := range sourceRanges {
+			rules[securityGroupRule{GroupID: groupID, CIDR: cidr, Port: int64(cfg.NodePort)}] = true
+		}
+
+		if healthCheckPort, err := strconv.Atoi(cfg.TargetHealthCheck().Port); err == nil && healthCheckPort != cfg.NodePort {
+			for _, cidr := range vpcRanges {
+				rules[securityGroupRule{GroupID: groupID, CIDR: cidr, Port: int64(healthCheckPort)}] = true
+			}
+		}
+	}
+
+	return rules
+}
+
+// applySecurityGroupRules authorizes the missing rules and revokes the stale ones. The security groups are recorded
+// in a tag on the load balancer, so rules on groups the nodes no longer use are revoked as well.
+func (p *directProvisioner) applySecurityGroupRules(instance *extensionsv1beta1.Ingress, lb *elbv2.LoadBalancer, cfg *cfn.TemplateConfig) error {
+	desired := desiredSecurityGroupRules(cfg)
+	if err := p.revokeSecurityGroupRules(instance, lb.LoadBalancerArn, desired); err != nil {
+		return err
+	}
+
+	current, err := p.securityGroupRules(instance, cfg.Network.SecurityGroupIDs)
+	if err != nil {
+		return err
+	}
+
+	description := aws.String(directRuleDescription(instance))
+	for rule := range desired {
+		if current[rule] {
+			continue
+		}
+
+		permission := &ec2.IpPermission{
+			IpProtocol: aws.String("tcp"),
+			FromPort:   aws.Int64(rule.Port),
+			ToPort:     aws.Int64(rule.Port),
+		}
+		if strings.Contains(rule.CIDR, ":") {
+			permission.Ipv6Ranges = []*ec2.Ipv6Range{{CidrIpv6: aws.String(rule.CIDR), Description: description}}
+		} else {
+			permission.IpRanges = []*ec2.IpRange{{CidrIp: aws.String(rule.CIDR), Description: description}}
+		}
+
+		if _, err := p.ec2Svc.AuthorizeSecurityGroupIngress(&ec2.AuthorizeSecurityGroupIngressInput{
+			GroupId:       aws.String(rule.GroupID),
+			IpPermissions: []*ec2.IpPermission{permission},
+		}); err != nil {
+			return err
+		}
+	}
+
+	groups := append([]string{}, cfg.Network.SecurityGroupIDs...)
+	sort.Strings(groups)
+	_, err = p.elbv2Svc.AddTags(&elbv2.AddTagsInput{
+		ResourceArns: []*string{lb.LoadBalancerArn},
+		Tags:         []*elbv2.Tag{{Key: aws.String(directTagSecurityGroups), Value: aws.String(strings.Join(groups, ","))}},
+	})
+
+	return err
+}
+
+// revokeSecurityGroupRules revokes the rules of the ingress on the security groups recorded on the load balancer or target group arn,
+// except the desired ones
+func (p *directProvisioner) revokeSecurityGroupRules(instance *extensionsv1beta1.Ingress, arn *string, desired map[securityGroupRule]bool) error {
+	tags, err := p.checkOwner(instance, arn)
+	if err != nil {
+		return err
+	}
+
+	groups := []string{}
+	for _, groupID := range strings.Split(tags[directTagSecurityGroups], ",") {
+		if groupID != "" {
+			groups = append(groups, groupID)
+		}
+	}
+
+	if len(groups) == 0 {
+		return nil
+	}
+
+	current, err := p.securityGroupRules(instance, groups)
+	if err != nil {
+		return err
+	}
+
+	for rule := range current {
+		if desired[rule] {
+			continue
+		}
+
+		permission := &ec2.IpPermission{
+			IpProtocol: aws.String("tcp"),
+			FromPort:   aws.Int64(rule.Port),
+			ToPort:     aws.Int64(rule.Port),
+		}
+		if strings.Contains(rule.CIDR, ":") {
+			permission.Ipv6Ranges = []*ec2.Ipv6Range{{CidrIpv6: aws.String(rule.CIDR)}}
+		} else {
+			permission.IpRanges = []*ec2.IpRange{{CidrIp: aws.String(rule.CIDR)}}
+		}
+
+		if _, err := p.ec2Svc.RevokeSecurityGroupIngress(&ec2.RevokeSecurityGroupIngressInput{
+			GroupId:       aws.String(rule.GroupID),
+			IpPermissions: []*ec2.IpPermission{permission},
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// securityGroupRules returns the rules of the ingress on the security groups, recognized by their description
+func (p *directProvisioner) securityGroupRules(instance *extensionsv1beta1.Ingress, groupIDs []string) (map[securityGroupRule]bool, error) {
+	rules := map[securityGroupRule]bool{}
+	if len(groupIDs) == 0 {
+		return rules, nil
+	}
+
+	out, err := p.ec2Svc.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{
+		GroupIds: aws.StringSlice(groupIDs),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	description := directRuleDescription(instance)
+	for _, group := range out.SecurityGroups {
+		for _, permission := range group.IpPermissions {
+			for _, ipRange := range permission.IpRanges {
+				if aws.StringValue(ipRange.Description) == description {
+					rules[securityGroupRule{GroupID: aws.StringValue(group.GroupId), CIDR: aws.StringValue(ipRange.CidrIp), Port: aws.Int64Value(permission.FromPort)}] = true
+				}
+			}
+			for _, ipv6Range := range permission.Ipv6Ranges {
+				if aws.StringValue(ipv6Range.Description) == description {
+					rules[securityGroupRule{GroupID: aws.StringValue(group.GroupId), CIDR: aws.StringValue(ipv6Range.CidrIpv6), Port: aws.Int64Value(permission.FromPort)}] = true
+				}
+			}
+		}
+	}
+
+	return rules, nil
+}
+
+// optionalString returns nil for empty values, which the AWS APIs reject
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+
+	return aws.String(value)
+}
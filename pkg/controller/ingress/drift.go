@@ -0,0 +1,113 @@
+package ingress
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	cfn "github.com/danushkaf/aws-nlb-ingress-controller/pkg/cloudformation"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Drift policies for stacks changed outside of CloudFormation
+const (
+	DriftPolicyNone      = "none"
+	DriftPolicyReport    = "report"
+	DriftPolicyRemediate = "remediate"
+
+	// DriftStatusNotRemediated records drift a stack update could not put back, the stack is replaced instead
+	DriftStatusNotRemediated = "NOT_REMEDIATED"
+
+	DefaultDriftCheckInterval = time.Hour
+	minDriftCheckInterval     = 5 * time.Minute
+	driftDetectionPoll        = 10 * time.Second
+)
+
+// shouldRemediateDrift tests if drift was detected on the stack and the ingress asks for it to be remediated
+func shouldRemediateDrift(ingress *extensionsv1beta1.Ingress) bool {
+	policy, _, err := getDriftPolicy(ingress)
+	return err == nil && policy == DriftPolicyRemediate && ingress.ObjectMeta.Annotations[IngressAnnotationDriftStatus] == cloudformation.StackDriftStatusDrifted
+}
+
+// shouldReplaceDrift tests if the stack update left the drift in place and the stack has to be replaced to remediate it
+func shouldReplaceDrift(ingress *extensionsv1beta1.Ingress) bool {
+	policy, _, err := getDriftPolicy(ingress)
+	return err == nil && policy == DriftPolicyRemediate && ingress.ObjectMeta.Annotations[IngressAnnotationDriftStatus] == DriftStatusNotRemediated
+}
+
+// checkDrift runs drift detection on the stack every drift check interval and reports drifted resources as events.
+// It returns when to check again and whether the annotations changed. Remediation happens through the stack update,
+// see shouldRemediateDrift, or the replacement of the stack when the update has no changes, see shouldReplaceDrift.
+// An invalid policy is reported as an event and skips the check, so the rest of the reconcile carries on.
+func (r *ReconcileIngress) checkDrift(instance *extensionsv1beta1.Ingress) (reconcile.Result, bool, error) {
+	stackName := instance.ObjectMeta.Name
+	annotations := instance.ObjectMeta.Annotations
+
+	policy, interval, err := getDriftPolicy(instance)
+	if err != nil {
+		r.log.Error("invalid drift policy", zap.Error(err))
+		r.recorder.Event(instance, corev1.EventTypeWarning, "InvalidDriftPolicy", err.Error())
+		return reconcile.Result{}, false, nil
+	}
+
+	if policy == DriftPolicyNone {
+		return reconcile.Result{}, false, nil
+	}
+
+	if detectionID := annotations[IngressAnnotationDriftDetectionID]; detectionID != "" {
+		out, err := r.cfnSvc.DescribeStackDriftDetectionStatus(&cloudformation.DescribeStackDriftDetectionStatusInput{
+			StackDriftDetectionId: aws.String(detectionID),
+		})
+		if err != nil {
+			r.log.Error("unable to describe drift detection", zap.String("stackName", stackName), zap.Error(err))
+			return reconcile.Result{}, false, err
+		}
+
+		if aws.StringValue(out.DetectionStatus) == cloudformation.StackDriftDetectionStatusDetectionInProgress {
+			return reconcile.Result{RequeueAfter: driftDetectionPoll}, false, nil
+		}
+
+		delete(annotations, IngressAnnotationDriftDetectionID)
+		if aws.StringValue(out.DetectionStatus) == cloudformation.StackDriftDetectionStatusDetectionFailed {
+			r.recorder.Event(instance, corev1.EventTypeWarning, "DriftDetectionFailed", aws.StringValue(out.DetectionStatusReason))
+		}
+
+		annotations[IngressAnnotationDriftStatus] = aws.StringValue(out.StackDriftStatus)
+		if aws.StringValue(out.StackDriftStatus) == cloudformation.StackDriftStatusDrifted {
+			drifted, err := cfn.DriftedResources(r.cfnSvc, stackName)
+			if err != nil {
+				r.log.Error("unable to describe drifted resources", zap.String("stackName", stackName), zap.Error(err))
+				return reconcile.Result{}, false, err
+			}
+
+			r.log.Info("stack drifted", zap.String("stackName", stackName), zap.Strings("resources", drifted))
+			for _, resource := range drifted {
+				r.recorder.Event(instance, corev1.EventTypeWarning, "Drifted", resource)
+			}
+		}
+
+		return reconcile.Result{RequeueAfter: interval}, true, nil
+	}
+
+	last, _ := time.Parse(time.RFC3339, annotations[IngressAnnotationLastDriftCheck])
+	if wait := time.Until(last.Add(interval)); wait > 0 {
+		return reconcile.Result{RequeueAfter: wait}, false, nil
+	}
+
+	r.log.Info("detecting stack drift", zap.String("stackName", stackName))
+	out, err := r.cfnSvc.DetectStackDrift(&cloudformation.DetectStackDriftInput{
+		StackName: aws.String(stackName),
+	})
+	if err != nil {
+		r.log.Error("unable to detect stack drift", zap.String("stackName", stackName), zap.Error(err))
+		return reconcile.Result{}, false, err
+	}
+
+	annotations[IngressAnnotationDriftDetectionID] = aws.StringValue(out.StackDriftDetectionId)
+	annotations[IngressAnnotationLastDriftCheck] = time.Now().UTC().Format(time.RFC3339)
+
+	return reconcile.Result{RequeueAfter: driftDetectionPoll}, true, nil
+}
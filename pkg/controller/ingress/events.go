@@ -0,0 +1,44 @@
+package ingress
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	cfn "github.com/danushkaf/aws-nlb-ingress-controller/pkg/cloudformation"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+)
+
+// recordStackEvents emits the stack events since the last reconcile as events on the ingress,
+// returning true when the annotations tracking them changed
+func (r *ReconcileIngress) recordStackEvents(instance *extensionsv1beta1.Ingress) bool {
+	lastEventID := instance.ObjectMeta.Annotations[IngressAnnotationLastStackEvent]
+	events, err := cfn.StackEventsSince(r.cfnSvc, instance.ObjectMeta.Name, lastEventID)
+	if err != nil {
+		r.log.Error("unable to describe stack events", zap.String("stackName", instance.ObjectMeta.Name), zap.Error(err))
+		return false
+	}
+
+	if len(events) == 0 {
+		return false
+	}
+
+	for _, event := range events {
+		message := fmt.Sprintf("%s (%s) %s", aws.StringValue(event.LogicalResourceId), aws.StringValue(event.ResourceType), aws.StringValue(event.ResourceStatus))
+		if reason := aws.StringValue(event.ResourceStatusReason); reason != "" {
+			message = fmt.Sprintf("%s: %s", message, reason)
+		}
+
+		eventType := corev1.EventTypeNormal
+		if cfn.IsFailedEvent(event) {
+			eventType = corev1.EventTypeWarning
+			instance.ObjectMeta.Annotations[IngressAnnotationLastFailureReason] = fmt.Sprintf("%s: %s", aws.StringValue(event.LogicalResourceId), aws.StringValue(event.ResourceStatusReason))
+		}
+
+		r.recorder.Event(instance, eventType, cfn.EventReason(event), message)
+	}
+
+	instance.ObjectMeta.Annotations[IngressAnnotationLastStackEvent] = aws.StringValue(events[len(events)-1].EventId)
+	return true
+}
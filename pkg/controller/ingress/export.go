@@ -0,0 +1,189 @@
+package ingress
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	cfn "github.com/danushkaf/aws-nlb-ingress-controller/pkg/cloudformation"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// Keys of the exported ConfigMap and names of the exported files
+const (
+	ExportTemplateKey   = "template.yaml"
+	ExportParametersKey = "parameters.json"
+
+	// ExportLabelStackName labels the exported ConfigMaps with the name of the stack the pipeline creates
+	ExportLabelStackName = "nlb.ingress.kubernetes.io/stack-name"
+
+	exportResourceSuffix = "-nlb-stack"
+)
+
+// exportParameters are the arguments the pipeline creates the stack with
+type exportParameters struct {
+	StackName    string            `json:"stackName"`
+	Capabilities []string          `json:"capabilities"`
+	Tags         map[string]string `json:"tags"`
+}
+
+// exportProvisioner writes the stack templates for a pipeline to apply, to a ConfigMap next to the ingress or to a directory.
+// It never changes stacks, it only reads the stack the pipeline creates under the expected name.
+type exportProvisioner struct {
+	r *ReconcileIngress
+	// dir is the directory to write the templates to, ConfigMaps are used when it is empty
+	dir string
+}
+
+func createExportResourceName(name string) string {
+	return name + exportResourceSuffix
+}
+
+// describe returns the stack the pipeline created. Until it exists there is no stack, so the reconciler keeps exporting
+// the template and edits to the ingress reach the pipeline before it creates the stack.
+func (p *exportProvisioner) describe(instance *extensionsv1beta1.Ingress) (*cloudformation.Stack, error) {
+	stack, err := cfn.DescribeStack(p.r.cfnSvc, instance.ObjectMeta.Name)
+	if err != nil && cfn.IsDoesNotExist(err, instance.ObjectMeta.Name) {
+		return nil, nil
+	}
+
+	return stack, err
+}
+
+func (p *exportProvisioner) create(instance *extensionsv1beta1.Ingress, cfg *cfn.TemplateConfig) error {
+	return p.export(instance, cfg)
+}
+
+// update exports the template, the change waits for the pipeline to apply it
+func (p *exportProvisioner) update(instance *extensionsv1beta1.Ingress, cfg *cfn.TemplateConfig) (updateStatus, error) {
+	return updateWaiting, p.export(instance, cfg)
+}
+
+// delete removes the exported template, the pipeline deletes the stack
+func (p *exportProvisioner) delete(instance *extensionsv1beta1.Ingress) error {
+	p.r.log.Info("removing exported template", zap.String("stackName", instance.ObjectMeta.Name))
+	if p.dir != "" {
+		return os.RemoveAll(filepath.Join(p.dir, instance.ObjectMeta.Name))
+	}
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      createExportResourceName(instance.ObjectMeta.Name),
+			Namespace: instance.ObjectMeta.Namespace,
+		},
+	}
+	if err := p.r.Delete(context.TODO(), configMap); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (p *exportProvisioner) targetGroupARNs(instance *extensionsv1beta1.Ingress) (string, string, error) {
+	return getStackTargetGroupARNs(p.r.cfnSvc, instance.ObjectMeta.Name)
+}
+
+func (p *exportProvisioner) subnetIDs(instance *extensionsv1beta1.Ingress) ([]string, error) {
+	return getStackSubnetIDs(p.r.cfnSvc, p.r.elbv2Svc, instance.ObjectMeta.Name)
+}
+
+// export writes the template and the stack parameters, leaving them untouched when they did not change
+func (p *exportProvisioner) export(instance *extensionsv1beta1.Ingress, cfg *cfn.TemplateConfig) error {
+	template, err := cfn.BuildNLBTemplateFromIngressRule(cfg).YAML()
+	if err != nil {
+		return err
+	}
+
+	parameters, err := json.MarshalIndent(exportParameters{
+		StackName:    instance.ObjectMeta.Name,
+		Capabilities: stackCapabilities,
+		Tags:         stackTags,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	files := map[string][]byte{
+		ExportTemplateKey:   template,
+		ExportParametersKey: parameters,
+	}
+
+	if p.dir != "" {
+		return p.exportFiles(instance, files)
+	}
+
+	return p.exportConfigMap(instance, files)
+}
+
+func (p *exportProvisioner) exportFiles(instance *extensionsv1beta1.Ingress, files map[string][]byte) error {
+	dir := filepath.Join(p.dir, instance.ObjectMeta.Name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	for _, name := range []string{ExportParametersKey, ExportTemplateKey} {
+		path := filepath.Join(dir, name)
+		if current, err := ioutil.ReadFile(path); err == nil && bytes.Equal(current, files[name]) {
+			continue
+		}
+
+		p.r.log.Info("exporting template", zap.String("stackName", instance.ObjectMeta.Name), zap.String("path", path))
+		if err := ioutil.WriteFile(path, files[name], 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *exportProvisioner) exportConfigMap(instance *extensionsv1beta1.Ingress, files map[string][]byte) error {
+	data := map[string]string{}
+	for name, content := range files {
+		data[name] = string(content)
+	}
+
+	configMap := &corev1.ConfigMap{}
+	err := p.r.Get(context.TODO(), k8stypes.NamespacedName{Name: createExportResourceName(instance.ObjectMeta.Name), Namespace: instance.ObjectMeta.Namespace}, configMap)
+	if errors.IsNotFound(err) {
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      createExportResourceName(instance.ObjectMeta.Name),
+				Namespace: instance.ObjectMeta.Namespace,
+				Labels:    map[string]string{ExportLabelStackName: instance.ObjectMeta.Name},
+			},
+			Data: data,
+		}
+		if err := controllerutil.SetControllerReference(instance, configMap, p.r.scheme); err != nil {
+			return err
+		}
+
+		p.r.log.Info("exporting template", zap.String("stackName", instance.ObjectMeta.Name), zap.String("configMap", configMap.Name))
+		return p.r.Create(context.TODO(), configMap)
+	} else if err != nil {
+		return err
+	}
+
+	if configMap.Data[ExportTemplateKey] == data[ExportTemplateKey] && configMap.Data[ExportParametersKey] == data[ExportParametersKey] {
+		return nil
+	}
+
+	p.r.log.Info("exporting template", zap.String("stackName", instance.ObjectMeta.Name), zap.String("configMap", configMap.Name))
+	configMap.Data = data
+	return p.r.Update(context.TODO(), configMap)
+}
+
+// exportsStacks tests if the stacks are applied by a pipeline from exported templates
+func (r *ReconcileIngress) exportsStacks() bool {
+	_, ok := r.provisioner.(*exportProvisioner)
+	return ok
+}
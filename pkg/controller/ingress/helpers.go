@@ -3,12 +3,19 @@ package ingress
 import (
 	"encoding/json"
 	"fmt"
+	"net"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/service/cloudformation"
 	cfn "github.com/danushkaf/aws-nlb-ingress-controller/pkg/cloudformation"
+	"github.com/danushkaf/aws-nlb-ingress-controller/pkg/network"
+	"go.uber.org/zap"
 	"k8s.io/apimachinery/pkg/labels"
 
+	corev1 "k8s.io/api/core/v1"
 	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
 )
 
@@ -40,6 +47,37 @@ func getNginxServicePort(ingress *extensionsv1beta1.Ingress) int {
 	return p
 }
 
+// parseServiceAddress splits a service address of the form name:port, the port must be a number for nginx to proxy to it
+func parseServiceAddress(address string) (string, int, error) {
+	parts := strings.Split(address, ":")
+	if len(parts) != 2 || parts[0] == "" {
+		return "", 0, fmt.Errorf("service %q must be of the form name:port", address)
+	}
+
+	port, err := strconv.Atoi(parts[1])
+	if err != nil || port < 1 || port > 65535 {
+		return "", 0, fmt.Errorf("service %q must have a port number", address)
+	}
+
+	return parts[0], port, nil
+}
+
+// getCustomErrorService returns the upstream of the service in the namespace of the ingress that serves the requests
+// no path matches, empty when it is not set
+func getCustomErrorService(ingress *extensionsv1beta1.Ingress) (string, error) {
+	address := strings.TrimSpace(ingress.ObjectMeta.Annotations[IngressAnnotationCustomErrorService])
+	if address == "" {
+		return "", nil
+	}
+
+	name, port, err := parseServiceAddress(address)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s: %s", IngressAnnotationCustomErrorService, err)
+	}
+
+	return fmt.Sprintf("%s:%d", name, port), nil
+}
+
 func getNginxReplicas(ingress *extensionsv1beta1.Ingress) int {
 	replicas := ingress.ObjectMeta.Annotations[IngressAnnotationNginxReplicas]
 	r, err := strconv.Atoi(replicas)
@@ -50,23 +88,680 @@ func getNginxReplicas(ingress *extensionsv1beta1.Ingress) int {
 	return r
 }
 
-func createReverseProxyResourceName(name string) string {
-	return fmt.Sprintf("%s-reverse-proxy", name)
+// getAnnotationList splits a comma separated annotation value, dropping empty entries
+func getAnnotationList(ingress *extensionsv1beta1.Ingress, annotation string) []string {
+	values := []string{}
+	for _, value := range strings.Split(ingress.ObjectMeta.Annotations[annotation], ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return values
 }
 
-func shouldUpdate(stack *cloudformation.Stack, instance *extensionsv1beta1.Ingress, r *ReconcileIngress) bool {
-	rulePaths, err := json.Marshal(instance.Spec.Rules[0].HTTP.Paths)
-	var rulePathsStr string
+func getListenerConfig(ingress *extensionsv1beta1.Ingress) *cfn.ListenerConfig {
+	certificateARNs := getAnnotationList(ingress, IngressAnnotationCertificateARN)
+	if len(certificateARNs) == 0 {
+		config := cfn.DefaultListenerConfig
+		return &config
+	}
+
+	// The TCP listener can only be turned off when there is a TLS listener to take its place
+	tcp, err := strconv.ParseBool(ingress.ObjectMeta.Annotations[IngressAnnotationTCPListener])
+	if err != nil {
+		tcp = cfn.DefaultListenerConfig.TCP
+	}
+
+	return &cfn.ListenerConfig{
+		TCP:             tcp,
+		CertificateARNs: certificateARNs,
+		SSLPolicy:       ingress.ObjectMeta.Annotations[IngressAnnotationSSLPolicy],
+		ALPNPolicy:      ingress.ObjectMeta.Annotations[IngressAnnotationALPNPolicy],
+	}
+}
+
+func getScheme(ingress *extensionsv1beta1.Ingress) string {
+	if ingress.ObjectMeta.Annotations[IngressAnnotationScheme] == network.SchemeInternetFacing {
+		return network.SchemeInternetFacing
+	}
+
+	return network.SchemeInternal
+}
+
+func getIPAddressType(ingress *extensionsv1beta1.Ingress) string {
+	if ingress.ObjectMeta.Annotations[IngressAnnotationIPAddressType] == network.IPAddressTypeDualStack {
+		return network.IPAddressTypeDualStack
+	}
+
+	return network.IPAddressTypeIPv4
+}
+
+// getSourceRanges returns the CIDRs allowed to reach the targets, empty means all VPC CIDR blocks
+func getSourceRanges(ingress *extensionsv1beta1.Ingress) ([]string, error) {
+	sourceRanges := getAnnotationList(ingress, IngressAnnotationSourceRanges)
+	for _, sourceRange := range sourceRanges {
+		if _, _, err := net.ParseCIDR(sourceRange); err != nil {
+			return nil, fmt.Errorf("%s must be a list of CIDRs, got %q", IngressAnnotationSourceRanges, sourceRange)
+		}
+	}
+
+	return sourceRanges, nil
+}
+
+// getDNSConfig returns the alias records to manage, the hosts of all rules when a hosted zone is set
+func getDNSConfig(ingress *extensionsv1beta1.Ingress) *cfn.DNSConfig {
+	hostedZoneID := strings.TrimSpace(ingress.ObjectMeta.Annotations[IngressAnnotationHostedZoneID])
+	if hostedZoneID == "" {
+		return &cfn.DNSConfig{}
+	}
+
+	hosts := map[string]bool{}
+	for _, rule := range ingress.Spec.Rules {
+		if rule.Host != "" {
+			hosts[rule.Host] = true
+		}
+	}
+
+	return &cfn.DNSConfig{
+		HostedZoneID: hostedZoneID,
+		Hosts:        getListFromMap(hosts),
+	}
+}
+
+func getEndpointServiceConfig(ingress *extensionsv1beta1.Ingress) *cfn.EndpointServiceConfig {
+	enabled, err := strconv.ParseBool(ingress.ObjectMeta.Annotations[IngressAnnotationEndpointService])
+	if err != nil || !enabled {
+		return &cfn.EndpointServiceConfig{}
+	}
+
+	// Connections have to be accepted unless explicitly turned off
+	acceptanceRequired, err := strconv.ParseBool(ingress.ObjectMeta.Annotations[IngressAnnotationEndpointServiceAcceptanceRequired])
+	if err != nil {
+		acceptanceRequired = true
+	}
+
+	config := &cfn.EndpointServiceConfig{
+		Enabled:            true,
+		AcceptanceRequired: acceptanceRequired,
+		AllowedPrincipals:  getAnnotationList(ingress, IngressAnnotationEndpointServiceAllowedPrincipals),
+	}
+
+	if len(config.AllowedPrincipals) == 0 {
+		config.AllowedPrincipals = nil
+	}
+
+	return config
+}
+
+// outputAnnotations maps the annotations the controller sets on the ingress to the stack outputs they come from
+var outputAnnotations = map[string]string{
+	IngressAnnotationEndpointServiceID:   cfn.OutputKeyEndpointServiceID,
+	IngressAnnotationEndpointServiceName: cfn.OutputKeyEndpointServiceName,
+	IngressAnnotationAPIGatewayURL:       cfn.OutputKeyAPIGatewayURL,
+}
+
+// setOutputAnnotations copies the stack outputs onto the ingress annotations, returning true when they changed
+func setOutputAnnotations(ingress *extensionsv1beta1.Ingress, outputs map[string]string) bool {
+	changed := false
+	for annotation, key := range outputAnnotations {
+		value, ok := ingress.ObjectMeta.Annotations[annotation]
+		if outputs[key] == "" && ok {
+			delete(ingress.ObjectMeta.Annotations, annotation)
+			changed = true
+		} else if outputs[key] != "" && value != outputs[key] {
+			if ingress.ObjectMeta.Annotations == nil {
+				ingress.ObjectMeta.Annotations = map[string]string{}
+			}
+			ingress.ObjectMeta.Annotations[annotation] = outputs[key]
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+func getMonitoringConfig(ingress *extensionsv1beta1.Ingress) (*cfn.MonitoringConfig, error) {
+	alarms, _ := strconv.ParseBool(ingress.ObjectMeta.Annotations[IngressAnnotationCloudWatchAlarms])
+	dashboard, _ := strconv.ParseBool(ingress.ObjectMeta.Annotations[IngressAnnotationCloudWatchDashboard])
+	if !alarms {
+		return &cfn.MonitoringConfig{Dashboard: dashboard}, nil
+	}
+
+	config := cfn.DefaultMonitoringConfig
+	config.Alarms = true
+	config.Dashboard = dashboard
+	if actions := getAnnotationList(ingress, IngressAnnotationCloudWatchAlarmActions); len(actions) > 0 {
+		config.AlarmActions = actions
+	}
+
+	var err error
+	if config.UnhealthyHostThreshold, err = getAnnotationInt(ingress, IngressAnnotationCloudWatchUnhealthyHostThreshold, config.UnhealthyHostThreshold); err != nil {
+		return nil, err
+	}
+
+	if config.MinHealthyHosts, err = getAnnotationInt(ingress, IngressAnnotationCloudWatchMinHealthyHosts, config.MinHealthyHosts); err != nil {
+		return nil, err
+	}
+
+	if config.TCPResetThreshold, err = getAnnotationInt(ingress, IngressAnnotationCloudWatchTCPResetThreshold, config.TCPResetThreshold); err != nil {
+		return nil, err
+	}
+
+	if config.PeriodSeconds, err = getAnnotationInt(ingress, IngressAnnotationCloudWatchAlarmPeriod, config.PeriodSeconds); err != nil {
+		return nil, err
+	}
+
+	if config.EvaluationPeriods, err = getAnnotationInt(ingress, IngressAnnotationCloudWatchEvaluationPeriods, config.EvaluationPeriods); err != nil {
+		return nil, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+func getRecoveryPolicy(ingress *extensionsv1beta1.Ingress) (*recoveryPolicy, error) {
+	policy := ingress.ObjectMeta.Annotations[IngressAnnotationRecoveryPolicy]
+	if policy != "" && policy != RecoveryPolicyAuto && policy != RecoveryPolicyNone {
+		return nil, fmt.Errorf("%s must be %s or %s, got %q", IngressAnnotationRecoveryPolicy, RecoveryPolicyAuto, RecoveryPolicyNone, policy)
+	}
+
+	maxRetries, err := getAnnotationInt(ingress, IngressAnnotationRecoveryMaxRetries, DefaultRecoveryMaxRetries)
+	if err != nil {
+		return nil, err
+	}
+
+	backoffSeconds, err := getAnnotationInt(ingress, IngressAnnotationRecoveryBackoffSeconds, DefaultRecoveryBackoffSeconds)
+	if err != nil {
+		return nil, err
+	}
+
+	if maxRetries < 0 || backoffSeconds < 1 {
+		return nil, fmt.Errorf("%s must not be negative and %s must be at least 1", IngressAnnotationRecoveryMaxRetries, IngressAnnotationRecoveryBackoffSeconds)
+	}
+
+	return &recoveryPolicy{
+		Enabled:    policy != RecoveryPolicyNone,
+		MaxRetries: maxRetries,
+		Backoff:    time.Duration(backoffSeconds) * time.Second,
+	}, nil
+}
+
+func getDriftPolicy(ingress *extensionsv1beta1.Ingress) (string, time.Duration, error) {
+	policy := ingress.ObjectMeta.Annotations[IngressAnnotationDriftPolicy]
+	if policy == "" {
+		policy = DriftPolicyNone
+	}
+
+	if policy != DriftPolicyNone && policy != DriftPolicyReport && policy != DriftPolicyRemediate {
+		return "", 0, fmt.Errorf("%s must be %s, %s or %s, got %q", IngressAnnotationDriftPolicy, DriftPolicyNone, DriftPolicyReport, DriftPolicyRemediate, policy)
+	}
+
+	interval := DefaultDriftCheckInterval
+	if value, ok := ingress.ObjectMeta.Annotations[IngressAnnotationDriftCheckInterval]; ok {
+		var err error
+		if interval, err = time.ParseDuration(value); err != nil || interval < minDriftCheckInterval {
+			return "", 0, fmt.Errorf("%s must be a duration of at least %s, got %q", IngressAnnotationDriftCheckInterval, minDriftCheckInterval, value)
+		}
+	}
+
+	return policy, interval, nil
+}
+
+func getAPIGatewayConfig(ingress *extensionsv1beta1.Ingress) (*cfn.APIGatewayConfig, error) {
+	annotations := ingress.ObjectMeta.Annotations
+	enabled, err := strconv.ParseBool(annotations[IngressAnnotationAPIGateway])
+	if err != nil || !enabled {
+		return &cfn.APIGatewayConfig{}, nil
+	}
+
+	config := &cfn.APIGatewayConfig{
+		Enabled:      true,
+		StageName:    cfn.DefaultAPIGatewayStage,
+		EndpointType: cfn.DefaultAPIGatewayEndpoint,
+	}
+
+	if stageName, ok := annotations[IngressAnnotationAPIGatewayStageName]; ok {
+		config.StageName = stageName
+	}
+
+	if endpointType, ok := annotations[IngressAnnotationAPIGatewayEndpointType]; ok {
+		config.EndpointType = strings.ToUpper(endpointType)
+	}
+
+	if resources, ok := annotations[IngressAnnotationAPIGatewayResources]; ok {
+		if err := json.Unmarshal([]byte(resources), &config.Resources); err != nil {
+			return nil, fmt.Errorf("%s must be a JSON list of resources: %s", IngressAnnotationAPIGatewayResources, err)
+		}
+	}
+
+	if usagePlans, ok := annotations[IngressAnnotationAPIGatewayUsagePlans]; ok {
+		if err := json.Unmarshal([]byte(usagePlans), &config.UsagePlans); err != nil {
+			return nil, fmt.Errorf("%s must be a JSON list of usage plans: %s", IngressAnnotationAPIGatewayUsagePlans, err)
+		}
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+func getAddressConfig(ingress *extensionsv1beta1.Ingress) (*cfn.AddressConfig, error) {
+	config := &cfn.AddressConfig{
+		AllocationIDs:        getAnnotationList(ingress, IngressAnnotationEIPAllocations),
+		PrivateIPv4Addresses: getAnnotationList(ingress, IngressAnnotationPrivateIPv4),
+	}
+
+	if len(config.AllocationIDs) == 0 {
+		config.AllocationIDs = nil
+	}
+	if len(config.PrivateIPv4Addresses) == 0 {
+		config.PrivateIPv4Addresses = nil
+	}
+
+	// Elastic IPs only attach to internet-facing NLBs, private addresses only to internal ones
+	scheme := getScheme(ingress)
+	if config.AllocationIDs != nil && scheme != network.SchemeInternetFacing {
+		return nil, fmt.Errorf("%s requires %s to be %s", IngressAnnotationEIPAllocations, IngressAnnotationScheme, network.SchemeInternetFacing)
+	}
+	if config.PrivateIPv4Addresses != nil && scheme != network.SchemeInternal {
+		return nil, fmt.Errorf("%s requires %s to be %s", IngressAnnotationPrivateIPv4, IngressAnnotationScheme, network.SchemeInternal)
+	}
+
+	return config, nil
+}
+
+// getAnnotationInt parses an integer annotation, returning def when it is not set
+func getAnnotationInt(ingress *extensionsv1beta1.Ingress, annotation string, def int) (int, error) {
+	value, ok := ingress.ObjectMeta.Annotations[annotation]
+	if !ok {
+		return def, nil
+	}
+
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be an integer, got %q", annotation, value)
+	}
+
+	return i, nil
+}
+
+func getHealthCheckConfig(ingress *extensionsv1beta1.Ingress) (*cfn.HealthCheckConfig, error) {
+	config := cfn.DefaultHealthCheckConfig
+	annotations := ingress.ObjectMeta.Annotations
+
+	if protocol, ok := annotations[IngressAnnotationHealthCheckProtocol]; ok {
+		config.Protocol = strings.ToUpper(protocol)
+		config.TimeoutSeconds = cfn.HealthCheckTimeouts[config.Protocol]
+	}
+
+	// HTTP health checks default to the healthz server of the reverse proxy
+	if config.Protocol == cfn.HealthCheckProtocolHTTP {
+		config.Path = DefaultHealthCheckPath
+		config.Port = ""
+	}
+
+	if path, ok := annotations[IngressAnnotationHealthCheckPath]; ok {
+		config.Path = path
+	}
+
+	if port, ok := annotations[IngressAnnotationHealthCheckPort]; ok {
+		config.Port = port
+	}
+
+	var err error
+	if config.IntervalSeconds, err = getAnnotationInt(ingress, IngressAnnotationHealthCheckIntervalSeconds, config.IntervalSeconds); err != nil {
+		return nil, err
+	}
+
+	if config.TimeoutSeconds, err = getAnnotationInt(ingress, IngressAnnotationHealthCheckTimeoutSeconds, config.TimeoutSeconds); err != nil {
+		return nil, err
+	}
+
+	if config.HealthyThresholdCount, err = getAnnotationInt(ingress, IngressAnnotationHealthyThresholdCount, config.HealthyThresholdCount); err != nil {
+		return nil, err
+	}
+
+	if config.UnhealthyThresholdCount, err = getAnnotationInt(ingress, IngressAnnotationUnhealthyThresholdCount, config.UnhealthyThresholdCount); err != nil {
+		return nil, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// getAnnotationMap parses a comma separated list of key=value pairs
+func getAnnotationMap(ingress *extensionsv1beta1.Ingress, annotation string) (map[string]string, error) {
+	values := map[string]string{}
+	for _, pair := range getAnnotationList(ingress, annotation) {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("%s must be a list of key=value pairs, got %q", annotation, pair)
+		}
+		values[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	return values, nil
+}
+
+func getAttributesConfig(ingress *extensionsv1beta1.Ingress) (*cfn.AttributesConfig, error) {
+	loadBalancer, err := getAnnotationMap(ingress, IngressAnnotationLoadBalancerAttributes)
+	if err != nil {
+		return nil, err
+	}
+
+	targetGroup, err := getAnnotationMap(ingress, IngressAnnotationTargetGroupAttributes)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &cfn.AttributesConfig{
+		LoadBalancer: loadBalancer,
+		TargetGroup:  targetGroup,
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// validateRules checks the ingress routes some traffic, through the paths of a rule or the default backend
+func validateRules(ingress *extensionsv1beta1.Ingress) error {
+	if ingress.Spec.Backend != nil {
+		return nil
+	}
+
+	for _, rule := range ingress.Spec.Rules {
+		if rule.HTTP != nil && len(rule.HTTP.Paths) > 0 {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("ingress %s has no rule with paths and no default backend", ingress.Name)
+}
+
+// buildTemplateConfig builds the template configuration from the ingress, the caller sets the network and ports
+func buildTemplateConfig(ingress *extensionsv1beta1.Ingress) (*cfn.TemplateConfig, error) {
+	if err := validateRules(ingress); err != nil {
+		return nil, err
+	}
+
+	if _, err := getCustomErrorService(ingress); err != nil {
+		return nil, err
+	}
+
+	if err := validateTLS(ingress); err != nil {
+		return nil, err
+	}
+
+	healthCheck, err := getHealthCheckConfig(ingress)
+	if err != nil {
+		return nil, err
+	}
+
+	attributes, err := getAttributesConfig(ingress)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses, err := getAddressConfig(ingress)
 	if err != nil {
-		rulePathsStr = ""
-	} else {
-		rulePathsStr = string(rulePaths)
+		return nil, err
 	}
-	if rulePathsStr != cfn.StackOutputMap(stack)[cfn.OutputKeyIngressRules] {
+
+	sourceRanges, err := getSourceRanges(ingress)
+	if err != nil {
+		return nil, err
+	}
+
+	apiGateway, err := getAPIGatewayConfig(ingress)
+	if err != nil {
+		return nil, err
+	}
+
+	monitoring, err := getMonitoringConfig(ingress)
+	if err != nil {
+		return nil, err
+	}
+
+	// The VPC Link integrations call the NLB over plain HTTP on the TCP listener
+	listeners := getListenerConfig(ingress)
+	if apiGateway.Enabled && !listeners.TCP {
+		return nil, fmt.Errorf("%s needs the TCP listener, remove %s", IngressAnnotationAPIGateway, IngressAnnotationTCPListener)
+	}
+
+	return &cfn.TemplateConfig{
+		Rules:           ingress.Spec.Rules,
+		Backend:         ingress.Spec.Backend,
+		TLS:             ingress.Spec.TLS,
+		Listeners:       getListenerConfig(ingress),
+		Scheme:          getScheme(ingress),
+		TargetType:      getTargetType(ingress),
+		HealthCheck:     healthCheck,
+		Attributes:      attributes,
+		Addresses:       addresses,
+		IPAddressType:   getIPAddressType(ingress),
+		SourceRanges:    sourceRanges,
+		DNS:             getDNSConfig(ingress),
+		EndpointService: getEndpointServiceConfig(ingress),
+		APIGateway:      apiGateway,
+		Monitoring:      monitoring,
+	}, nil
+}
+
+func getTargetType(ingress *extensionsv1beta1.Ingress) string {
+	if ingress.ObjectMeta.Annotations[IngressAnnotationTargetType] == cfn.TargetTypeIP {
+		return cfn.TargetTypeIP
+	}
+
+	return cfn.TargetTypeInstance
+}
+
+// getStackTargetType returns the target type the stack was built with, stacks without the output predate ip targets
+func getStackTargetType(stack *cloudformation.Stack) string {
+	if targetType := cfn.StackOutputMap(stack)[cfn.OutputKeyTargetType]; targetType != "" {
+		return targetType
+	}
+
+	return cfn.TargetTypeInstance
+}
+
+// getTargetPorts returns the traffic and healthz ports of the reverse proxy targets,
+// the proxy pod ports for ip targets and the NodePorts otherwise
+func getTargetPorts(ingress *extensionsv1beta1.Ingress, svc *corev1.Service) (int, int) {
+	if getTargetType(ingress) == cfn.TargetTypeIP {
+		return getNginxServicePort(ingress), DefaultNginxHealthzPort
+	}
+
+	healthzPort := 0
+	for _, port := range svc.Spec.Ports {
+		if port.Name == "healthz" {
+			healthzPort = int(port.NodePort)
+		}
+	}
+
+	return int(svc.Spec.Ports[0].NodePort), healthzPort
+}
+
+// getTLSTargetPort returns the port of the reverse proxy targets for the TLS traffic, zero without spec.tls
+func getTLSTargetPort(ingress *extensionsv1beta1.Ingress, svc *corev1.Service) int {
+	if len(ingress.Spec.TLS) == 0 {
+		return 0
+	}
+
+	if getTargetType(ingress) == cfn.TargetTypeIP {
+		return DefaultNginxTLSPort
+	}
+
+	for _, port := range svc.Spec.Ports {
+		if port.Name == "https" {
+			return int(port.NodePort)
+		}
+	}
+
+	return 0
+}
+
+func createReverseProxyResourceName(name string) string {
+	return fmt.Sprintf("%s%s", name, reverseProxyResourceSuffix)
+}
+
+func getIngressNameFromReverseProxyResourceName(name string) (string, bool) {
+	if !strings.HasSuffix(name, reverseProxyResourceSuffix) {
+		return "", false
+	}
+
+	return strings.TrimSuffix(name, reverseProxyResourceSuffix), true
+}
+
+func getListFromMap(data map[string]bool) []string {
+	list := []string{}
+	for key := range data {
+		list = append(list, key)
+	}
+	sort.Strings(list)
+	return list
+}
+
+func getSortedKeys(data map[string]string) []string {
+	keys := []string{}
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// shouldReplace checks for changes CloudFormation can only apply by replacing the load balancer
+func shouldReplace(stack *cloudformation.Stack, instance *extensionsv1beta1.Ingress, r *ReconcileIngress) bool {
+	// Stacks created before the scheme was configurable have no Scheme output and are always internal
+	scheme := cfn.StackOutputMap(stack)[cfn.OutputKeyScheme]
+	if scheme == "" {
+		scheme = network.SchemeInternal
+	}
+
+	if scheme != getScheme(instance) {
+		r.log.Info("Scheme in Outputs is not matching, Should Replace", zap.String("current", scheme), zap.String("desired", getScheme(instance)))
+		return true
+	}
+
+	// The ASG attachments of instance targets have to be removed before the target group goes away
+	if getStackTargetType(stack) != getTargetType(instance) {
+		r.log.Info("TargetType in Outputs is not matching, Should Replace", zap.String("current", getStackTargetType(stack)), zap.String("desired", getTargetType(instance)))
+		return true
+	}
+
+	// A replacement NLB can not take over addresses the old one still holds, so it has to be deleted first.
+	// Stacks created before addresses were configurable have no Addresses output and no pinned addresses.
+	addresses := cfn.StackOutputMap(stack)[cfn.OutputKeyAddresses]
+	if addresses == "" {
+		addresses = cfn.OutputValue(&cfn.AddressConfig{})
+	}
+
+	// An invalid address configuration is reported by update, it is not a reason to replace
+	if desired, err := getAddressConfig(instance); err == nil && cfn.OutputValue(desired) != addresses {
+		r.log.Info("Addresses in Outputs are not matching, Should Replace", zap.String("current", addresses), zap.String("desired", cfn.OutputValue(desired)))
+		return true
+	}
+
+	if shouldReplaceDrift(instance) {
+		r.log.Info("Drift was not remediated by the update, Should Replace")
+		return true
+	}
+
+	return false
+}
+
+// shouldUpdate checks for changes to apply to the stack. An invalid annotation still triggers the update,
+// which reports the validation error.
+func shouldUpdate(stack *cloudformation.Stack, instance *extensionsv1beta1.Ingress, r *ReconcileIngress) bool {
+	outputs := cfn.StackOutputMap(stack)
+	if cfn.IngressRulesOutput(instance.Spec.Rules, instance.Spec.Backend) != outputs[cfn.OutputKeyIngressRules] {
 		r.log.Info("Rules in Outputs are not matching, Should Update")
 		return true
-	} else {
-		r.log.Debug("Rules in Outputs are matching, Should Update not triggered.")
 	}
+
+	if cfn.TLSOutput(instance.Spec.TLS) != outputs[cfn.OutputKeyTLS] {
+		r.log.Info("TLS in Outputs is not matching, Should Update")
+		return true
+	}
+
+	if cfn.OutputValue(getListenerConfig(instance)) != outputs[cfn.OutputKeyListeners] {
+		r.log.Info("Listeners in Outputs are not matching, Should Update")
+		return true
+	}
+
+	healthCheck, _ := getHealthCheckConfig(instance)
+	if cfn.OutputValue(healthCheck) != outputs[cfn.OutputKeyHealthCheck] {
+		r.log.Info("HealthCheck in Outputs is not matching, Should Update")
+		return true
+	}
+
+	// Address changes replace the stack, an invalid address configuration triggers the update that reports it
+	if _, err := getAddressConfig(instance); err != nil {
+		r.log.Info("Addresses are invalid, Should Update", zap.Error(err))
+		return true
+	}
+
+	// Stacks created before dualstack support have no IPAddressType output and are ipv4
+	ipAddressType := outputs[cfn.OutputKeyIPAddressType]
+	if ipAddressType == "" {
+		ipAddressType = network.IPAddressTypeIPv4
+	}
+
+	if ipAddressType != getIPAddressType(instance) {
+		r.log.Info("IPAddressType in Outputs is not matching, Should Update")
+		return true
+	}
+
+	sourceRanges, _ := getSourceRanges(instance)
+	if cfn.OutputValue(sourceRanges) != outputs[cfn.OutputKeySourceRanges] {
+		r.log.Info("SourceRanges in Outputs are not matching, Should Update")
+		return true
+	}
+
+	if cfn.OutputValue(getDNSConfig(instance)) != outputs[cfn.OutputKeyDNS] {
+		r.log.Info("DNS in Outputs is not matching, Should Update")
+		return true
+	}
+
+	if cfn.OutputValue(getEndpointServiceConfig(instance)) != outputs[cfn.OutputKeyEndpointService] {
+		r.log.Info("EndpointService in Outputs is not matching, Should Update")
+		return true
+	}
+
+	monitoring, _ := getMonitoringConfig(instance)
+	if cfn.OutputValue(monitoring) != outputs[cfn.OutputKeyMonitoring] {
+		r.log.Info("Monitoring in Outputs is not matching, Should Update")
+		return true
+	}
+
+	apiGateway, _ := getAPIGatewayConfig(instance)
+	if cfn.OutputValue(apiGateway) != outputs[cfn.OutputKeyAPIGateway] {
+		r.log.Info("APIGateway in Outputs is not matching, Should Update")
+		return true
+	}
+
+	attributes, _ := getAttributesConfig(instance)
+	if cfn.OutputValue(attributes) != outputs[cfn.OutputKeyAttributes] {
+		r.log.Info("Attributes in Outputs are not matching, Should Update")
+		return true
+	}
+
+	r.log.Debug("Outputs are matching, Should Update not triggered.")
 	return false
 }
@@ -0,0 +1,866 @@
+package ingress
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	cfn "github.com/danushkaf/aws-nlb-ingress-controller/pkg/cloudformation"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestGetHealthCheckConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        *cfn.HealthCheckConfig
+		wantErr     bool
+	}{
+		{
+			name:        "defaults to a TCP health check on the traffic port",
+			annotations: map[string]string{},
+			want:        &cfn.DefaultHealthCheckConfig,
+		},
+		{
+			name: "HTTP health check targets the proxy healthz server",
+			annotations: map[string]string{
+				IngressAnnotationHealthCheckProtocol:        "http",
+				IngressAnnotationHealthCheckIntervalSeconds: "10",
+				IngressAnnotationHealthyThresholdCount:      "2",
+				IngressAnnotationUnhealthyThresholdCount:    "2",
+			},
+			want: &cfn.HealthCheckConfig{
+				Protocol:                "HTTP",
+				Path:                    "/healthz",
+				IntervalSeconds:         10,
+				TimeoutSeconds:          6,
+				HealthyThresholdCount:   2,
+				UnhealthyThresholdCount: 2,
+			},
+		},
+		{
+			name: "HTTP health check on a custom path and port",
+			annotations: map[string]string{
+				IngressAnnotationHealthCheckProtocol: "HTTP",
+				IngressAnnotationHealthCheckPath:     "/ping",
+				IngressAnnotationHealthCheckPort:     "traffic-port",
+			},
+			want: &cfn.HealthCheckConfig{
+				Protocol:                "HTTP",
+				Path:                    "/ping",
+				Port:                    "traffic-port",
+				IntervalSeconds:         30,
+				TimeoutSeconds:          6,
+				HealthyThresholdCount:   3,
+				UnhealthyThresholdCount: 3,
+			},
+		},
+		{
+			name:        "rejects non numeric values",
+			annotations: map[string]string{IngressAnnotationHealthCheckIntervalSeconds: "ten"},
+			wantErr:     true,
+		},
+		{
+			name:        "rejects combinations NLB does not accept",
+			annotations: map[string]string{IngressAnnotationHealthCheckIntervalSeconds: "15"},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := newAnnotatedIngress(tt.annotations)
+
+			got, err := getHealthCheckConfig(instance)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("getHealthCheckConfig() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getHealthCheckConfig() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetAttributesConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        *cfn.AttributesConfig
+		wantErr     bool
+	}{
+		{
+			name:        "no attributes",
+			annotations: map[string]string{},
+			want:        &cfn.AttributesConfig{},
+		},
+		{
+			name: "parses key=value lists",
+			annotations: map[string]string{
+				IngressAnnotationLoadBalancerAttributes: "load_balancing.cross_zone.enabled=true, deletion_protection.enabled=true",
+				IngressAnnotationTargetGroupAttributes:  "deregistration_delay.timeout_seconds=30",
+			},
+			want: &cfn.AttributesConfig{
+				LoadBalancer: map[string]string{"load_balancing.cross_zone.enabled": "true", "deletion_protection.enabled": "true"},
+				TargetGroup:  map[string]string{"deregistration_delay.timeout_seconds": "30"},
+			},
+		},
+		{
+			name:        "rejects entries without a value",
+			annotations: map[string]string{IngressAnnotationLoadBalancerAttributes: "deletion_protection.enabled"},
+			wantErr:     true,
+		},
+		{
+			name:        "rejects unknown attributes",
+			annotations: map[string]string{IngressAnnotationTargetGroupAttributes: "slow_start.duration_seconds=30"},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := newAnnotatedIngress(tt.annotations)
+
+			got, err := getAttributesConfig(instance)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("getAttributesConfig() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getAttributesConfig() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetAddressConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        *cfn.AddressConfig
+		wantErr     bool
+	}{
+		{
+			name:        "no addresses",
+			annotations: map[string]string{},
+			want:        &cfn.AddressConfig{},
+		},
+		{
+			name: "elastic ips on an internet-facing nlb",
+			annotations: map[string]string{
+				IngressAnnotationScheme:         "internet-facing",
+				IngressAnnotationEIPAllocations: "eipalloc-a, eipalloc-b",
+			},
+			want: &cfn.AddressConfig{AllocationIDs: []string{"eipalloc-a", "eipalloc-b"}},
+		},
+		{
+			name:        "private addresses on an internal nlb",
+			annotations: map[string]string{IngressAnnotationPrivateIPv4: "10.0.0.10,10.0.1.10"},
+			want:        &cfn.AddressConfig{PrivateIPv4Addresses: []string{"10.0.0.10", "10.0.1.10"}},
+		},
+		{
+			name:        "rejects elastic ips on an internal nlb",
+			annotations: map[string]string{IngressAnnotationEIPAllocations: "eipalloc-a"},
+			wantErr:     true,
+		},
+		{
+			name: "rejects private addresses on an internet-facing nlb",
+			annotations: map[string]string{
+				IngressAnnotationScheme:      "internet-facing",
+				IngressAnnotationPrivateIPv4: "10.0.0.10",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := newAnnotatedIngress(tt.annotations)
+
+			got, err := getAddressConfig(instance)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("getAddressConfig() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getAddressConfig() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetSourceRanges(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        []string
+		wantErr     bool
+	}{
+		{
+			name:        "defaults to the vpc",
+			annotations: map[string]string{},
+			want:        []string{},
+		},
+		{
+			name:        "ipv4 and ipv6 ranges",
+			annotations: map[string]string{IngressAnnotationSourceRanges: "203.0.113.0/24, 2001:db8::/32"},
+			want:        []string{"203.0.113.0/24", "2001:db8::/32"},
+		},
+		{
+			name:        "rejects addresses without prefix length",
+			annotations: map[string]string{IngressAnnotationSourceRanges: "203.0.113.10"},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := newAnnotatedIngress(tt.annotations)
+
+			got, err := getSourceRanges(instance)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("getSourceRanges() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getSourceRanges() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetDNSConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		hosts       []string
+		want        *cfn.DNSConfig
+	}{
+		{
+			name:  "no hosted zone",
+			hosts: []string{"foo.example.com"},
+			want:  &cfn.DNSConfig{},
+		},
+		{
+			name:        "hosts of all rules, sorted and unique",
+			annotations: map[string]string{IngressAnnotationHostedZoneID: "Z123"},
+			hosts:       []string{"foo.example.com", "", "bar.example.com", "foo.example.com"},
+			want:        &cfn.DNSConfig{HostedZoneID: "Z123", Hosts: []string{"bar.example.com", "foo.example.com"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := newAnnotatedIngress(tt.annotations)
+			rule := instance.Spec.Rules[0]
+			instance.Spec.Rules = nil
+			for _, host := range tt.hosts {
+				rule.Host = host
+				instance.Spec.Rules = append(instance.Spec.Rules, rule)
+			}
+
+			if got := getDNSConfig(instance); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getDNSConfig() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetEndpointServiceConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        *cfn.EndpointServiceConfig
+	}{
+		{
+			name:        "disabled by default",
+			annotations: map[string]string{IngressAnnotationEndpointServiceAllowedPrincipals: "arn:aws:iam::123456789012:root"},
+			want:        &cfn.EndpointServiceConfig{},
+		},
+		{
+			name:        "acceptance required by default",
+			annotations: map[string]string{IngressAnnotationEndpointService: "true"},
+			want:        &cfn.EndpointServiceConfig{Enabled: true, AcceptanceRequired: true},
+		},
+		{
+			name: "allowed principals without acceptance",
+			annotations: map[string]string{
+				IngressAnnotationEndpointService:                   "true",
+				IngressAnnotationEndpointServiceAcceptanceRequired: "false",
+				IngressAnnotationEndpointServiceAllowedPrincipals:  "arn:aws:iam::123456789012:root, arn:aws:iam::210987654321:root",
+			},
+			want: &cfn.EndpointServiceConfig{
+				Enabled:           true,
+				AllowedPrincipals: []string{"arn:aws:iam::123456789012:root", "arn:aws:iam::210987654321:root"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := newAnnotatedIngress(tt.annotations)
+
+			if got := getEndpointServiceConfig(instance); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getEndpointServiceConfig() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetOutputAnnotations(t *testing.T) {
+	instance := newMockIngress("foobar", false, false)
+	outputs := map[string]string{
+		cfn.OutputKeyEndpointServiceID:   "vpce-svc-foo",
+		cfn.OutputKeyEndpointServiceName: "com.amazonaws.vpce.us-west-2.vpce-svc-foo",
+	}
+
+	if !setOutputAnnotations(instance, outputs) {
+		t.Errorf("setOutputAnnotations() = false, want true when the service is created")
+	}
+	if instance.Annotations[IngressAnnotationEndpointServiceName] != "com.amazonaws.vpce.us-west-2.vpce-svc-foo" {
+		t.Errorf("Got %s = %s, want the service name", IngressAnnotationEndpointServiceName, instance.Annotations[IngressAnnotationEndpointServiceName])
+	}
+
+	if setOutputAnnotations(instance, outputs) {
+		t.Errorf("setOutputAnnotations() = true, want false when nothing changed")
+	}
+
+	if !setOutputAnnotations(instance, map[string]string{}) {
+		t.Errorf("setOutputAnnotations() = false, want true when the service is removed")
+	}
+	if _, ok := instance.Annotations[IngressAnnotationEndpointServiceID]; ok {
+		t.Errorf("Got %s, want it removed with the service", IngressAnnotationEndpointServiceID)
+	}
+}
+
+func TestGetAPIGatewayConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        *cfn.APIGatewayConfig
+		wantErr     bool
+	}{
+		{
+			name:        "disabled by default",
+			annotations: map[string]string{IngressAnnotationAPIGatewayStageName: "test"},
+			want:        &cfn.APIGatewayConfig{},
+		},
+		{
+			name:        "defaults",
+			annotations: map[string]string{IngressAnnotationAPIGateway: "true"},
+			want:        &cfn.APIGatewayConfig{Enabled: true, StageName: "prod", EndpointType: "REGIONAL"},
+		},
+		{
+			name: "resources and usage plans from JSON",
+			annotations: map[string]string{
+				IngressAnnotationAPIGateway:             "true",
+				IngressAnnotationAPIGatewayEndpointType: "edge",
+				IngressAnnotationAPIGatewayResources:    `[{"path":"/api/v1/foobar","caching_enabled":true,"method":["GET"]}]`,
+				IngressAnnotationAPIGatewayUsagePlans:   `[{"plan_name":"gold","api_keys":[{"name":"customer-a"}],"quota_limit":1000,"quota_period":"DAY"}]`,
+			},
+			want: &cfn.APIGatewayConfig{
+				Enabled:      true,
+				StageName:    "prod",
+				EndpointType: "EDGE",
+				Resources:    []cfn.APIResource{{Path: "/api/v1/foobar", CachingEnabled: true, Methods: []string{"GET"}}},
+				UsagePlans:   []cfn.UsagePlan{{PlanName: "gold", APIKeys: []cfn.APIKey{{Name: "customer-a"}}, QuotaLimit: 1000, QuotaPeriod: "DAY"}},
+			},
+		},
+		{
+			name: "rejects invalid JSON",
+			annotations: map[string]string{
+				IngressAnnotationAPIGateway:           "true",
+				IngressAnnotationAPIGatewayUsagePlans: `{"plan_name":"gold"}`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "rejects invalid configuration",
+			annotations: map[string]string{
+				IngressAnnotationAPIGateway:          "true",
+				IngressAnnotationAPIGatewayStageName: "pro-d",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := newAnnotatedIngress(tt.annotations)
+
+			got, err := getAPIGatewayConfig(instance)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("getAPIGatewayConfig() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getAPIGatewayConfig() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetMonitoringConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        *cfn.MonitoringConfig
+		wantErr     bool
+	}{
+		{
+			name:        "disabled by default",
+			annotations: map[string]string{IngressAnnotationCloudWatchMinHealthyHosts: "2"},
+			want:        &cfn.MonitoringConfig{},
+		},
+		{
+			name:        "dashboard only",
+			annotations: map[string]string{IngressAnnotationCloudWatchDashboard: "true"},
+			want:        &cfn.MonitoringConfig{Dashboard: true},
+		},
+		{
+			name: "alarms with defaults",
+			annotations: map[string]string{
+				IngressAnnotationCloudWatchAlarms:       "true",
+				IngressAnnotationCloudWatchAlarmActions: "arn:aws:sns:us-east-1:123456789012:alerts, arn:aws:sns:us-east-1:123456789012:pager",
+			},
+			want: &cfn.MonitoringConfig{
+				Alarms:                 true,
+				AlarmActions:           []string{"arn:aws:sns:us-east-1:123456789012:alerts", "arn:aws:sns:us-east-1:123456789012:pager"},
+				UnhealthyHostThreshold: 1,
+				MinHealthyHosts:        1,
+				TCPResetThreshold:      100,
+				PeriodSeconds:          60,
+				EvaluationPeriods:      3,
+			},
+		},
+		{
+			name: "alarms with thresholds",
+			annotations: map[string]string{
+				IngressAnnotationCloudWatchAlarms:                 "true",
+				IngressAnnotationCloudWatchDashboard:              "true",
+				IngressAnnotationCloudWatchUnhealthyHostThreshold: "2",
+				IngressAnnotationCloudWatchMinHealthyHosts:        "3",
+				IngressAnnotationCloudWatchTCPResetThreshold:      "500",
+				IngressAnnotationCloudWatchAlarmPeriod:            "300",
+				IngressAnnotationCloudWatchEvaluationPeriods:      "1",
+			},
+			want: &cfn.MonitoringConfig{
+				Alarms:                 true,
+				Dashboard:              true,
+				UnhealthyHostThreshold: 2,
+				MinHealthyHosts:        3,
+				TCPResetThreshold:      500,
+				PeriodSeconds:          300,
+				EvaluationPeriods:      1,
+			},
+		},
+		{
+			name: "rejects non integer thresholds",
+			annotations: map[string]string{
+				IngressAnnotationCloudWatchAlarms:          "true",
+				IngressAnnotationCloudWatchMinHealthyHosts: "many",
+			},
+			wantErr: true,
+		},
+		{
+			name: "rejects invalid period",
+			annotations: map[string]string{
+				IngressAnnotationCloudWatchAlarms:      "true",
+				IngressAnnotationCloudWatchAlarmPeriod: "45",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := newAnnotatedIngress(tt.annotations)
+
+			got, err := getMonitoringConfig(instance)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("getMonitoringConfig() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getMonitoringConfig() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetRecoveryPolicy(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        *recoveryPolicy
+		wantErr     bool
+	}{
+		{
+			name: "recovers by default",
+			want: &recoveryPolicy{Enabled: true, MaxRetries: 5, Backoff: 30 * time.Second},
+		},
+		{
+			name: "custom retries and backoff",
+			annotations: map[string]string{
+				IngressAnnotationRecoveryPolicy:         "auto",
+				IngressAnnotationRecoveryMaxRetries:     "10",
+				IngressAnnotationRecoveryBackoffSeconds: "60",
+			},
+			want: &recoveryPolicy{Enabled: true, MaxRetries: 10, Backoff: time.Minute},
+		},
+		{
+			name:        "disabled",
+			annotations: map[string]string{IngressAnnotationRecoveryPolicy: "none"},
+			want:        &recoveryPolicy{Enabled: false, MaxRetries: 5, Backoff: 30 * time.Second},
+		},
+		{
+			name:        "rejects zero backoff",
+			annotations: map[string]string{IngressAnnotationRecoveryBackoffSeconds: "0"},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := newAnnotatedIngress(tt.annotations)
+
+			got, err := getRecoveryPolicy(instance)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("getRecoveryPolicy() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getRecoveryPolicy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetDriftPolicy(t *testing.T) {
+	tests := []struct {
+		name         string
+		annotations  map[string]string
+		wantPolicy   string
+		wantInterval time.Duration
+		wantErr      bool
+	}{
+		{
+			name:         "disabled by default",
+			wantPolicy:   DriftPolicyNone,
+			wantInterval: time.Hour,
+		},
+		{
+			name:         "remediate with custom interval",
+			annotations:  map[string]string{IngressAnnotationDriftPolicy: "remediate", IngressAnnotationDriftCheckInterval: "15m"},
+			wantPolicy:   DriftPolicyRemediate,
+			wantInterval: 15 * time.Minute,
+		},
+		{
+			name:        "rejects unknown policies",
+			annotations: map[string]string{IngressAnnotationDriftPolicy: "fix"},
+			wantErr:     true,
+		},
+		{
+			name:        "rejects invalid intervals",
+			annotations: map[string]string{IngressAnnotationDriftPolicy: "report", IngressAnnotationDriftCheckInterval: "hourly"},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := newAnnotatedIngress(tt.annotations)
+
+			policy, interval, err := getDriftPolicy(instance)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("getDriftPolicy() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if policy != tt.wantPolicy || interval != tt.wantInterval {
+				t.Errorf("getDriftPolicy() = %v, %v, want %v, %v", policy, interval, tt.wantPolicy, tt.wantInterval)
+			}
+		})
+	}
+}
+
+func TestRecoveryPolicy_delay(t *testing.T) {
+	policy := &recoveryPolicy{Backoff: 30 * time.Second}
+	for attempts, want := range map[int]time.Duration{1: 30 * time.Second, 2: time.Minute, 3: 2 * time.Minute, 20: time.Hour} {
+		if got := policy.delay(attempts); got != want {
+			t.Errorf("recoveryPolicy.delay(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
+
+func TestBuildTemplateConfig_Rules(t *testing.T) {
+	backend := &extensionsv1beta1.IngressBackend{ServiceName: "default", ServicePort: intstr.FromInt(80)}
+	path := func(path string, service string) extensionsv1beta1.HTTPIngressPath {
+		return extensionsv1beta1.HTTPIngressPath{Path: path, Backend: extensionsv1beta1.IngressBackend{ServiceName: service, ServicePort: intstr.FromInt(80)}}
+	}
+
+	tests := []struct {
+		name      string
+		rules     []extensionsv1beta1.IngressRule
+		backend   *extensionsv1beta1.IngressBackend
+		wantPaths []string
+		wantNginx []string
+		wantErr   bool
+	}{
+		{
+			name: "paths of all rules",
+			rules: []extensionsv1beta1.IngressRule{
+				{Host: "foo.example.com", IngressRuleValue: extensionsv1beta1.IngressRuleValue{HTTP: &extensionsv1beta1.HTTPIngressRuleValue{Paths: []extensionsv1beta1.HTTPIngressPath{path("/foo", "foo")}}}},
+				{Host: "bar.example.com", IngressRuleValue: extensionsv1beta1.IngressRuleValue{HTTP: &extensionsv1beta1.HTTPIngressRuleValue{Paths: []extensionsv1beta1.HTTPIngressPath{path("/bar", "bar"), path("/foo", "foo")}}}},
+			},
+			wantPaths: []string{"/foo", "/bar"},
+			wantNginx: []string{"location /foo {", "location /bar {", "http://bar:80"},
+		},
+		{
+			name: "rules without paths are skipped",
+			rules: []extensionsv1beta1.IngressRule{
+				{Host: "foo.example.com"},
+				{IngressRuleValue: extensionsv1beta1.IngressRuleValue{HTTP: &extensionsv1beta1.HTTPIngressRuleValue{Paths: []extensionsv1beta1.HTTPIngressPath{path("", "foo")}}}},
+			},
+			wantPaths: []string{"/"},
+			wantNginx: []string{"location / {", "http://foo:80"},
+		},
+		{
+			name:      "default backend only",
+			backend:   backend,
+			wantPaths: []string{"/"},
+			wantNginx: []string{"location / {", "http://default:80"},
+		},
+		{
+			name: "default backend after the paths",
+			rules: []extensionsv1beta1.IngressRule{
+				{IngressRuleValue: extensionsv1beta1.IngressRuleValue{HTTP: &extensionsv1beta1.HTTPIngressRuleValue{Paths: []extensionsv1beta1.HTTPIngressPath{path("/foo", "foo")}}}},
+			},
+			backend:   backend,
+			wantPaths: []string{"/foo", "/"},
+			wantNginx: []string{"location /foo {", "location / {", "http://default:80"},
+		},
+		{
+			name:    "no rules and no default backend",
+			rules:   []extensionsv1beta1.IngressRule{{Host: "foo.example.com"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := newMockIngress("foobar", false, false)
+			instance.Spec.Rules, instance.Spec.Backend = tt.rules, tt.backend
+
+			got, err := buildTemplateConfig(instance)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("buildTemplateConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if paths := got.Paths(); !reflect.DeepEqual(paths, tt.wantPaths) {
+				t.Errorf("buildTemplateConfig().Paths() = %v, want %v", paths, tt.wantPaths)
+			}
+
+			nginx := buildNginxConfig(instance, staticUpstreams(instance))
+			for _, want := range tt.wantNginx {
+				if !strings.Contains(nginx, want) {
+					t.Errorf("buildNginxConfig() = %s, want %s", nginx, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildNginxServers(t *testing.T) {
+	backend := func(service string) *extensionsv1beta1.IngressBackend {
+		return &extensionsv1beta1.IngressBackend{ServiceName: service, ServicePort: intstr.FromInt(80)}
+	}
+	rule := func(host string, paths ...string) extensionsv1beta1.IngressRule {
+		rule := extensionsv1beta1.IngressRule{Host: host, IngressRuleValue: extensionsv1beta1.IngressRuleValue{HTTP: &extensionsv1beta1.HTTPIngressRuleValue{}}}
+		for _, path := range paths {
+			rule.HTTP.Paths = append(rule.HTTP.Paths, extensionsv1beta1.HTTPIngressPath{Path: path, Backend: *backend(host + path)})
+		}
+		return rule
+	}
+
+	tests := []struct {
+		name        string
+		rules       []extensionsv1beta1.IngressRule
+		backend     *extensionsv1beta1.IngressBackend
+		tls         []extensionsv1beta1.IngressTLS
+		annotations map[string]string
+		options     Options
+		want        []nginxServer
+	}{
+		{
+			name:  "rules without a host go to the default server",
+			rules: []extensionsv1beta1.IngressRule{rule("", "/foo")},
+			want: []nginxServer{
+				{Default: true, Locations: []nginxLocation{{Path: "/"}, {Path: "/foo", Upstream: "/foo:80"}}},
+			},
+		},
+		{
+			name:  "a server per host with sorted paths",
+			rules: []extensionsv1beta1.IngressRule{rule("foo.example.com", "/b", "/a"), rule("*.example.com", "/a"), rule("foo.example.com", "/c")},
+			want: []nginxServer{
+				{Default: true, Locations: []nginxLocation{{Path: "/"}}},
+				{Host: "*.example.com", Locations: []nginxLocation{{Path: "/"}, {Path: "/a", Upstream: "*.example.com/a:80"}}},
+				{Host: "foo.example.com", Locations: []nginxLocation{{Path: "/"}, {Path: "/a", Upstream: "foo.example.com/a:80"}, {Path: "/b", Upstream: "foo.example.com/b:80"}, {Path: "/c", Upstream: "foo.example.com/c:80"}}},
+			},
+		},
+		{
+			name:  "same path on two hosts",
+			rules: []extensionsv1beta1.IngressRule{rule("foo.example.com", "/"), rule("bar.example.com", "/")},
+			want: []nginxServer{
+				{Default: true, Locations: []nginxLocation{{Path: "/"}}},
+				{Host: "bar.example.com", Locations: []nginxLocation{{Path: "/", Upstream: "bar.example.com/:80"}}},
+				{Host: "foo.example.com", Locations: []nginxLocation{{Path: "/", Upstream: "foo.example.com/:80"}}},
+			},
+		},
+		{
+			name:    "default backend in every server",
+			rules:   []extensionsv1beta1.IngressRule{rule("foo.example.com", "/foo"), rule("bar.example.com", "/")},
+			backend: backend("default"),
+			want: []nginxServer{
+				{Default: true, Locations: []nginxLocation{{Path: "/", Upstream: "default:80"}}},
+				{Host: "bar.example.com", Locations: []nginxLocation{{Path: "/", Upstream: "bar.example.com/:80"}}},
+				{Host: "foo.example.com", Locations: []nginxLocation{{Path: "/", Upstream: "default:80"}, {Path: "/foo", Upstream: "foo.example.com/foo:80"}}},
+			},
+		},
+		{
+			name:        "default backend before the custom error service",
+			rules:       []extensionsv1beta1.IngressRule{rule("", "/foo")},
+			backend:     backend("default"),
+			annotations: map[string]string{IngressAnnotationCustomErrorService: "errors:8080"},
+			want: []nginxServer{
+				{Default: true, Locations: []nginxLocation{{Path: "/", Upstream: "default:80"}, {Path: "/foo", Upstream: "/foo:80"}}},
+			},
+		},
+		{
+			name:        "custom error service before the controller default backend",
+			rules:       []extensionsv1beta1.IngressRule{rule("", "/foo")},
+			annotations: map[string]string{IngressAnnotationCustomErrorService: "errors:8080"},
+			options:     Options{DefaultBackendService: "kube-system/default-http-backend:80"},
+			want: []nginxServer{
+				{Default: true, Locations: []nginxLocation{{Path: "/", Upstream: "errors:8080"}, {Path: "/foo", Upstream: "/foo:80"}}},
+			},
+		},
+		{
+			name:    "controller default backend",
+			rules:   []extensionsv1beta1.IngressRule{rule("", "/foo")},
+			options: Options{DefaultBackendService: "kube-system/default-http-backend:80"},
+			want: []nginxServer{
+				{Default: true, Locations: []nginxLocation{{Path: "/", Upstream: "default-http-backend.kube-system.svc:80"}, {Path: "/foo", Upstream: "/foo:80"}}},
+			},
+		},
+		{
+			name:    "named ports without a service are unavailable",
+			rules:   []extensionsv1beta1.IngressRule{rule("", "/foo")},
+			backend: &extensionsv1beta1.IngressBackend{ServiceName: "default", ServicePort: intstr.FromString("http")},
+			want: []nginxServer{
+				{Default: true, Locations: []nginxLocation{{Path: "/", Unavailable: true}, {Path: "/foo", Upstream: "/foo:80"}}},
+			},
+		},
+		{
+			name:  "tls hosts get their certificate and the default server the first one",
+			rules: []extensionsv1beta1.IngressRule{rule("foo.example.com", "/"), rule("bar.example.com", "/")},
+			tls: []extensionsv1beta1.IngressTLS{
+				{Hosts: []string{"foo.example.com", "baz.example.com"}, SecretName: "foo-tls"},
+				{Hosts: []string{"foo.example.com"}, SecretName: "other-tls"},
+			},
+			want: []nginxServer{
+				{Default: true, TLS: secretCertificate("foo-tls"), Locations: []nginxLocation{{Path: "/"}}},
+				{Host: "bar.example.com", Locations: []nginxLocation{{Path: "/", Upstream: "bar.example.com/:80"}}},
+				{Host: "baz.example.com", TLS: secretCertificate("foo-tls"), Locations: []nginxLocation{{Path: "/"}}},
+				{Host: "foo.example.com", TLS: secretCertificate("foo-tls"), Locations: []nginxLocation{{Path: "/", Upstream: "foo.example.com/:80"}}},
+			},
+		},
+		{
+			name:  "tls entry without hosts for the default server",
+			rules: []extensionsv1beta1.IngressRule{rule("foo.example.com", "/")},
+			tls: []extensionsv1beta1.IngressTLS{
+				{Hosts: []string{"foo.example.com"}, SecretName: "foo-tls"},
+				{SecretName: "default-tls"},
+			},
+			want: []nginxServer{
+				{Default: true, TLS: secretCertificate("default-tls"), Locations: []nginxLocation{{Path: "/"}}},
+				{Host: "foo.example.com", TLS: secretCertificate("foo-tls"), Locations: []nginxLocation{{Path: "/", Upstream: "foo.example.com/:80"}}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := newAnnotatedIngress(tt.annotations)
+			instance.Spec.Rules, instance.Spec.Backend, instance.Spec.TLS = tt.rules, tt.backend, tt.tls
+
+			defaults := ControllerOptions
+			defer func() { ControllerOptions = defaults }()
+			ControllerOptions.DefaultBackendService = tt.options.DefaultBackendService
+
+			if got := buildNginxServers(instance, staticUpstreams(instance)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("buildNginxServers() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetCustomErrorService(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{name: "not set"},
+		{name: "service and port", value: " errors:8080 ", want: "errors:8080"},
+		{name: "missing port", value: "errors", wantErr: true},
+		{name: "named port", value: "errors:http", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := newMockIngress("foobar", false, false)
+			instance.Annotations[IngressAnnotationCustomErrorService] = tt.value
+
+			got, err := getCustomErrorService(instance)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getCustomErrorService() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("getCustomErrorService() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateTLS(t *testing.T) {
+	tests := []struct {
+		name        string
+		tls         []extensionsv1beta1.IngressTLS
+		annotations map[string]string
+		wantErr     bool
+	}{
+		{name: "no tls"},
+		{name: "tls secret", tls: []extensionsv1beta1.IngressTLS{{Hosts: []string{"foo.example.com"}, SecretName: "foo-tls"}}},
+		{name: "missing secret", tls: []extensionsv1beta1.IngressTLS{{Hosts: []string{"foo.example.com"}}}, wantErr: true},
+		{
+			name:        "tls with certificate arns",
+			tls:         []extensionsv1beta1.IngressTLS{{SecretName: "foo-tls"}},
+			annotations: map[string]string{IngressAnnotationCertificateARN: "arn:aws:acm:us-east-1:123456789012:certificate/foo"},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := newAnnotatedIngress(tt.annotations)
+			instance.Spec.TLS = tt.tls
+
+			if err := validateTLS(instance); (err != nil) != tt.wantErr {
+				t.Errorf("validateTLS() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// newAnnotatedIngress returns the mock ingress with the annotations of a test case added
+func newAnnotatedIngress(annotations map[string]string) *extensionsv1beta1.Ingress {
+	instance := newMockIngress("foobar", false, false)
+	for k, v := range annotations {
+		instance.Annotations[k] = v
+	}
+
+	return instance
+}
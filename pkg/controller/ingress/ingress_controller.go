@@ -18,6 +18,9 @@ package ingress
 import (
 	"context"
 	"fmt"
+	"path"
+	"reflect"
+	"strconv"
 	"strings"
 	"time"
 
@@ -32,6 +35,8 @@ import (
 	"github.com/aws/aws-sdk-go/service/cloudformation/cloudformationiface"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
 	cfn "github.com/danushkaf/aws-nlb-ingress-controller/pkg/cloudformation"
 	"github.com/danushkaf/aws-nlb-ingress-controller/pkg/finalizers"
 	"github.com/danushkaf/aws-nlb-ingress-controller/pkg/logging"
@@ -44,6 +49,8 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
@@ -55,18 +62,101 @@ import (
 
 const (
 	ingressNameLengthLimit            = 51
+	reverseProxyResourceSuffix        = "-reverse-proxy"
 	FinalizerCFNStack                 = "nlb.networking.amazonaws.com/ingress-finalizer"
 	IngressClassAnnotation            = "kubernetes.io/ingress.class"
 	IngressAnnotationNodeSelector     = "nlb.ingress.kubernetes.io/node-selector"
 	IngressAnnotationNginxReplicas    = "nlb.ingress.kubernetes.io/nginx-replicas"
 	IngressAnnotationNginxImage       = "nlb.ingress.kubernetes.io/nginx-image"
 	IngressAnnotationNginxServicePort = "nlb.ingress.kubernetes.io/nginx-service-port"
+	IngressAnnotationCertificateARN   = "nlb.ingress.kubernetes.io/certificate-arn"
+	IngressAnnotationSSLPolicy        = "nlb.ingress.kubernetes.io/ssl-policy"
+	IngressAnnotationALPNPolicy       = "nlb.ingress.kubernetes.io/alpn-policy"
+	IngressAnnotationTCPListener      = "nlb.ingress.kubernetes.io/tcp-listener"
+	IngressAnnotationScheme           = "nlb.ingress.kubernetes.io/scheme"
+	IngressAnnotationSubnets          = "nlb.ingress.kubernetes.io/subnets"
+	IngressAnnotationTargetType       = "nlb.ingress.kubernetes.io/target-type"
+	IngressAnnotationEIPAllocations   = "nlb.ingress.kubernetes.io/eip-allocations"
+	IngressAnnotationPrivateIPv4      = "nlb.ingress.kubernetes.io/private-ipv4-addresses"
+	IngressAnnotationIPAddressType    = "nlb.ingress.kubernetes.io/ip-address-type"
+	IngressAnnotationSourceRanges     = "nlb.ingress.kubernetes.io/source-ranges"
+	IngressAnnotationHostedZoneID     = "nlb.ingress.kubernetes.io/hosted-zone-id"
+
+	IngressAnnotationCustomErrorService = "nlb.ingress.kubernetes.io/custom-error-service"
+
+	IngressAnnotationHealthCheckProtocol        = "nlb.ingress.kubernetes.io/healthcheck-protocol"
+	IngressAnnotationHealthCheckPath            = "nlb.ingress.kubernetes.io/healthcheck-path"
+	IngressAnnotationHealthCheckPort            = "nlb.ingress.kubernetes.io/healthcheck-port"
+	IngressAnnotationHealthCheckIntervalSeconds = "nlb.ingress.kubernetes.io/healthcheck-interval-seconds"
+	IngressAnnotationHealthCheckTimeoutSeconds  = "nlb.ingress.kubernetes.io/healthcheck-timeout-seconds"
+	IngressAnnotationHealthyThresholdCount      = "nlb.ingress.kubernetes.io/healthy-threshold-count"
+	IngressAnnotationUnhealthyThresholdCount    = "nlb.ingress.kubernetes.io/unhealthy-threshold-count"
+
+	IngressAnnotationEndpointService                   = "nlb.ingress.kubernetes.io/endpoint-service"
+	IngressAnnotationEndpointServiceAcceptanceRequired = "nlb.ingress.kubernetes.io/endpoint-service-acceptance-required"
+	IngressAnnotationEndpointServiceAllowedPrincipals  = "nlb.ingress.kubernetes.io/endpoint-service-allowed-principals"
+
+	IngressAnnotationAPIGateway             = "nlb.ingress.kubernetes.io/apigateway"
+	IngressAnnotationAPIGatewayStageName    = "nlb.ingress.kubernetes.io/apigateway-stage-name"
+	IngressAnnotationAPIGatewayEndpointType = "nlb.ingress.kubernetes.io/apigateway-endpoint-type"
+	IngressAnnotationAPIGatewayResources    = "nlb.ingress.kubernetes.io/apigateway-resources"
+	IngressAnnotationAPIGatewayUsagePlans   = "nlb.ingress.kubernetes.io/apigateway-usage-plans"
+
+	IngressAnnotationCloudWatchAlarms                 = "nlb.ingress.kubernetes.io/cloudwatch-alarms"
+	IngressAnnotationCloudWatchDashboard              = "nlb.ingress.kubernetes.io/cloudwatch-dashboard"
+	IngressAnnotationCloudWatchAlarmActions           = "nlb.ingress.kubernetes.io/cloudwatch-alarm-actions"
+	IngressAnnotationCloudWatchUnhealthyHostThreshold = "nlb.ingress.kubernetes.io/cloudwatch-unhealthy-host-threshold"
+	IngressAnnotationCloudWatchMinHealthyHosts        = "nlb.ingress.kubernetes.io/cloudwatch-min-healthy-hosts"
+	IngressAnnotationCloudWatchTCPResetThreshold      = "nlb.ingress.kubernetes.io/cloudwatch-tcp-reset-threshold"
+	IngressAnnotationCloudWatchAlarmPeriod            = "nlb.ingress.kubernetes.io/cloudwatch-alarm-period"
+	IngressAnnotationCloudWatchEvaluationPeriods      = "nlb.ingress.kubernetes.io/cloudwatch-alarm-evaluation-periods"
+
+	IngressAnnotationRequireApproval   = "nlb.ingress.kubernetes.io/require-approval"
+	IngressAnnotationApprovedChangeSet = "nlb.ingress.kubernetes.io/approved-change-set"
+
+	// Set by the controller when it creates a change set for the stack
+	IngressAnnotationChangeSet      = "nlb.ingress.kubernetes.io/change-set"
+	IngressAnnotationPlannedChanges = "nlb.ingress.kubernetes.io/planned-changes"
+
+	IngressAnnotationRecoveryPolicy         = "nlb.ingress.kubernetes.io/recovery-policy"
+	IngressAnnotationRecoveryMaxRetries     = "nlb.ingress.kubernetes.io/recovery-max-retries"
+	IngressAnnotationRecoveryBackoffSeconds = "nlb.ingress.kubernetes.io/recovery-backoff-seconds"
+
+	// Set by the controller while it recovers a failed stack
+	IngressAnnotationRecoveryAttempts    = "nlb.ingress.kubernetes.io/recovery-attempts"
+	IngressAnnotationRecoveryLastAttempt = "nlb.ingress.kubernetes.io/recovery-last-attempt"
+
+	// Set by the controller from the stack events
+	IngressAnnotationLastStackEvent    = "nlb.ingress.kubernetes.io/last-stack-event"
+	IngressAnnotationLastFailureReason = "nlb.ingress.kubernetes.io/last-failure-reason"
+
+	IngressAnnotationDriftPolicy        = "nlb.ingress.kubernetes.io/drift-policy"
+	IngressAnnotationDriftCheckInterval = "nlb.ingress.kubernetes.io/drift-check-interval"
+
+	// Set by the controller when it checks the stack for drift
+	IngressAnnotationDriftDetectionID = "nlb.ingress.kubernetes.io/drift-detection-id"
+	IngressAnnotationLastDriftCheck   = "nlb.ingress.kubernetes.io/last-drift-check"
+	IngressAnnotationDriftStatus      = "nlb.ingress.kubernetes.io/drift-status"
+
+	// Set by the controller from the stack outputs
+	IngressAnnotationEndpointServiceID   = "nlb.ingress.kubernetes.io/endpoint-service-id"
+	IngressAnnotationEndpointServiceName = "nlb.ingress.kubernetes.io/endpoint-service-name"
+	IngressAnnotationAPIGatewayURL       = "nlb.ingress.kubernetes.io/apigateway-url"
+
+	// Set by the direct provisioner, the outputs of the configuration it applied
+	IngressAnnotationAppliedConfig = "nlb.ingress.kubernetes.io/applied-config"
+
+	IngressAnnotationLoadBalancerAttributes = "nlb.ingress.kubernetes.io/load-balancer-attributes"
+	IngressAnnotationTargetGroupAttributes  = "nlb.ingress.kubernetes.io/target-group-attributes"
 )
 
 var (
 	DefaultNginxReplicas    = 3
 	DefaultNginxImage       = "nginx:latest"
 	DefaultNginxServicePort = 8080
+	DefaultNginxHealthzPort = 10254
+	DefaultNginxTLSPort     = 8443
+	DefaultHealthCheckPath  = "/healthz"
 	DefaultNodeSelector     = labels.NewSelector()
 )
 
@@ -108,14 +198,21 @@ func newReconciler(mgr manager.Manager) reconcile.Reconciler {
 
 	sess := getAWSSession(logger)
 
-	return &ReconcileIngress{
+	r := &ReconcileIngress{
 		Client:         mgr.GetClient(),
 		scheme:         mgr.GetScheme(),
 		log:            logger,
 		cfnSvc:         cloudformation.New(sess),
 		ec2Svc:         ec2.New(sess),
+		elbv2Svc:       elbv2.New(sess),
 		autoscalingSvc: autoscaling.New(sess),
+		recorder:       mgr.GetEventRecorderFor("nlb-ingress-controller"),
 	}
+
+	logger.Info("using provisioner", zap.String("provisioner", ControllerOptions.Provisioner))
+	r.provisioner = newProvisioner(ControllerOptions, r)
+
+	return r
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
@@ -132,6 +229,39 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		return err
 	}
 
+	// Watch the reverse proxy endpoints to keep ip targets registered with the target group
+	err = c.Watch(&source.Kind{Type: &corev1.Endpoints{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(o handler.MapObject) []reconcile.Request {
+			name, ok := getIngressNameFromReverseProxyResourceName(o.Meta.GetName())
+			if !ok {
+				return nil
+			}
+
+			return []reconcile.Request{
+				{NamespacedName: k8stypes.NamespacedName{Name: name, Namespace: o.Meta.GetNamespace()}},
+			}
+		}),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Watch the tls secrets to roll the reverse proxy when certificates change
+	err = c.Watch(&source.Kind{Type: &corev1.Secret{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: mapSecretToIngresses(mgr.GetClient()),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Watch the backend services to resolve their ports again when they change
+	err = c.Watch(&source.Kind{Type: &corev1.Service{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: mapServiceToIngresses(mgr.GetClient()),
+	})
+	if err != nil {
+		return err
+	}
+
 	// // TODO(user): Modify this to be the types you create
 	// // Uncomment watch a Deployment created by Ingress - change this for objects you create
 	// err = c.Watch(&source.Kind{Type: &appsv1.Deployment{}}, &handler.EnqueueRequestForOwner{
@@ -153,11 +283,16 @@ type ReconcileIngress struct {
 	scheme         *runtime.Scheme
 	cfnSvc         cloudformationiface.CloudFormationAPI
 	ec2Svc         ec2iface.EC2API
+	elbv2Svc       elbv2iface.ELBV2API
 	autoscalingSvc autoscalingiface.AutoScalingAPI
+	recorder       record.EventRecorder
+	provisioner    provisioner
 	log            *zap.Logger
 }
 
-func (r *ReconcileIngress) fetchNetworkingInfo(instance *extensionsv1beta1.Ingress) (*network.Network, error) {
+// fetchNetworkingInfo looks up the network of the worker nodes and selects the load balancer subnets,
+// preferring the subnets in inUse the load balancer already has
+func (r *ReconcileIngress) fetchNetworkingInfo(instance *extensionsv1beta1.Ingress, inUse []string) (*network.Network, error) {
 	// TODO: We probably want to add some way of specifying which worker nodes we want to use. (security group ingress rules etc...)
 	r.log.Info("fetching worker nodes")
 	nodes := corev1.NodeList{
@@ -185,6 +320,10 @@ func (r *ReconcileIngress) fetchNetworkingInfo(instance *extensionsv1beta1.Ingre
 		return nil, err
 	}
 
+	if len(vpcIDs) > 1 {
+		return nil, fmt.Errorf("worker nodes span multiple vpcs %s, use %s to select nodes in a single vpc", strings.Join(vpcIDs, ", "), IngressAnnotationNodeSelector)
+	}
+
 	r.log.Info("describing VPCs", zap.String("VPCs", strings.Join(vpcIDs, ",")))
 	describeVPCResponse, err := r.ec2Svc.DescribeVpcs(&ec2.DescribeVpcsInput{
 		VpcIds: aws.StringSlice(vpcIDs),
@@ -194,12 +333,62 @@ func (r *ReconcileIngress) fetchNetworkingInfo(instance *extensionsv1beta1.Ingre
 		return nil, fmt.Errorf("unable to find vpc %s", strings.Join(vpcIDs, ", "))
 	}
 
+	vpc := describeVPCResponse.Vpcs[0]
+	scheme := getScheme(instance)
+	var subnets []*ec2.Subnet
+	if annotatedSubnetIds := getAnnotationList(instance, IngressAnnotationSubnets); len(annotatedSubnetIds) > 0 {
+		r.log.Info("using subnets from annotation", zap.String("subnets", strings.Join(annotatedSubnetIds, ",")))
+		subnets, err = network.DescribeSubnets(r.ec2Svc, annotatedSubnetIds)
+	} else if scheme == network.SchemeInternetFacing {
+		r.log.Info("discovering subnets for nlb", zap.String("scheme", scheme), zap.String("vpc", *vpc.VpcId))
+		subnets, err = network.DiscoverSubnets(r.ec2Svc, *vpc.VpcId, scheme)
+	} else {
+		subnets, err = network.DescribeSubnets(r.ec2Svc, subnetIds)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return r.buildNetwork(instance, vpc, subnets, inUse, nodeInstanceIds, securityGroups, asgNames)
+}
+
+// buildNetwork selects the load balancer subnets among the candidates and pairs them with the addresses of the ingress
+func (r *ReconcileIngress) buildNetwork(instance *extensionsv1beta1.Ingress, vpc *ec2.Vpc, subnets []*ec2.Subnet, inUse []string, instanceIDs []string, securityGroups []string, asgNames []string) (*network.Network, error) {
+	choices, err := network.SelectSubnets(*vpc.VpcId, subnets, network.SubnetRoleTag(getScheme(instance)), inUse)
+	if err != nil {
+		return nil, err
+	}
+
+	selectedSubnetIds := []string{}
+	for _, choice := range choices {
+		r.log.Info("selected subnet for nlb", zap.String("availabilityZone", choice.AvailabilityZone), zap.String("subnet", choice.SubnetID), zap.String("reason", choice.Reason))
+		selectedSubnetIds = append(selectedSubnetIds, choice.SubnetID)
+	}
+
+	if getIPAddressType(instance) == network.IPAddressTypeDualStack {
+		if err := network.RequireIPv6(choices); err != nil {
+			return nil, err
+		}
+	}
+
+	addresses, err := getAddressConfig(instance)
+	if err != nil {
+		return nil, err
+	}
+
+	subnetMappings, err := network.PairSubnetMappings(choices, addresses.AllocationIDs, addresses.PrivateIPv4Addresses)
+	if err != nil {
+		return nil, err
+	}
+
 	return &network.Network{
-		InstanceIDs:      nodeInstanceIds,
+		InstanceIDs:      instanceIDs,
 		SecurityGroupIDs: securityGroups,
-		SubnetIDs:        subnetIds,
+		SubnetIDs:        selectedSubnetIds,
+		Subnets:          choices,
+		SubnetMappings:   subnetMappings,
 		ASGNames:         asgNames,
-		Vpc:              describeVPCResponse.Vpcs[0],
+		Vpc:              vpc,
 	}, nil
 }
 
@@ -210,6 +399,9 @@ func (r *ReconcileIngress) fetchNetworkingInfo(instance *extensionsv1beta1.Ingre
 // +kubebuilder:rbac:groups=apps,resources=deployments/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=core,resources=nodes;services;configmaps,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core,resources=nodes/status;services/status;configmaps/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=core,resources=endpoints,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
 // +kubebuilder:rbac:groups=extensions,resources=ingresses,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=extensions,resources=ingresses/status,verbs=get;update;patch
 func (r *ReconcileIngress) Reconcile(request reconcile.Request) (reconcile.Result, error) {
@@ -254,8 +446,13 @@ func (r *ReconcileIngress) Reconcile(request reconcile.Request) (reconcile.Resul
 	}
 
 	// Check if stack exists
-	stack, err := cfn.DescribeStack(r.cfnSvc, instance.ObjectMeta.Name)
-	if err != nil && cfn.IsDoesNotExist(err, instance.ObjectMeta.Name) {
+	stack, err := r.provisioner.describe(instance)
+	if err != nil {
+		r.log.Error("error describing stack", zap.Error(err))
+		return reconcile.Result{}, err
+	}
+
+	if stack == nil {
 		r.log.Info("creating nlb", zap.String("stackName", instance.ObjectMeta.Name))
 		instance, err := r.create(instance)
 		if err != nil {
@@ -266,16 +463,30 @@ func (r *ReconcileIngress) Reconcile(request reconcile.Request) (reconcile.Resul
 			return reconcile.Result{}, err
 		}
 
+		// The template is exported again on every check until the pipeline creates the stack
+		if r.exportsStacks() {
+			r.log.Info("exported template is waiting for the pipeline", zap.String("stackName", instance.ObjectMeta.Name))
+			return reconcile.Result{RequeueAfter: 20 * time.Second}, nil
+		}
+
 		return reconcile.Result{Requeue: true}, nil
-	} else if err != nil {
-		r.log.Error("error describing stack", zap.Error(err))
-		return reconcile.Result{}, err
 	}
 
 	r.log.Info("Found Stack", zap.String("stackName", instance.ObjectMeta.Name), zap.String("StackStatus", *stack.StackStatus))
 
+	if r.managesStacks() && r.recordStackEvents(instance) {
+		if err := r.Update(context.TODO(), instance); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
 	if cfn.IsFailed(*stack.StackStatus) {
-		return reconcile.Result{}, r.Update(context.TODO(), instance)
+		result, err := r.recover(instance, stack)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+
+		return result, r.Update(context.TODO(), instance)
 	}
 
 	if cfn.IsComplete(*stack.StackStatus) == false {
@@ -284,23 +495,98 @@ func (r *ReconcileIngress) Reconcile(request reconcile.Request) (reconcile.Resul
 		return reconcile.Result{RequeueAfter: 20 * time.Second}, r.Update(context.TODO(), instance)
 	}
 
-	if cfn.IsComplete(*stack.StackStatus) && shouldUpdate(stack, instance, r) {
+	// The pipeline decides how to apply an exported template, a replacement is exported like any other change
+	needsReplace := cfn.IsComplete(*stack.StackStatus) && shouldReplace(stack, instance, r)
+	if needsReplace && !r.exportsStacks() {
+		if !approveReplacement(instance) {
+			// Keep the current stack in sync while the replacement waits for approval
+			r.log.Info("replacement is waiting for approval", zap.String("changeSet", instance.ObjectMeta.Annotations[IngressAnnotationChangeSet]))
+			if err := r.Update(context.TODO(), instance); err != nil {
+				return reconcile.Result{}, err
+			}
+		} else {
+			r.log.Info("replacing nlb cloudformation stack", zap.String("stackName", instance.ObjectMeta.Name))
+			if err := r.replace(instance, getStackTargetType(stack)); err != nil {
+				return reconcile.Result{}, err
+			}
+
+			return reconcile.Result{RequeueAfter: 20 * time.Second}, r.Update(context.TODO(), instance)
+		}
+	} else if cfn.IsComplete(*stack.StackStatus) && (needsReplace || shouldUpdate(stack, instance, r) || shouldRemediateDrift(instance)) {
 		r.log.Info("updating nlb cloudformation stack", zap.String("stackName", instance.ObjectMeta.Name))
-		if err := r.update(instance, stack); err != nil {
+		status, err := r.update(instance, stack)
+		if err != nil {
 			return reconcile.Result{}, err
 		}
 
-		return reconcile.Result{Requeue: true}, nil
+		if err := r.Update(context.TODO(), instance); err != nil {
+			return reconcile.Result{}, err
+		}
+
+		if status != updateWaiting {
+			return reconcile.Result{Requeue: true}, nil
+		}
+
+		// Keep the current stack in sync while the change waits for approval or for the pipeline
+		if r.exportsStacks() {
+			r.log.Info("exported template is waiting for the pipeline", zap.String("stackName", instance.ObjectMeta.Name))
+		} else {
+			r.log.Info("change set is waiting for approval", zap.String("changeSet", instance.ObjectMeta.Annotations[IngressAnnotationChangeSet]))
+		}
 	}
 
-	err = r.attachTGToASG(instance)
-	if err != nil {
-		r.log.Error("unable to verify ASG after create/update", zap.Error(err))
+	if err := r.syncProxyConfig(instance); err != nil {
+		r.log.Error("unable to update proxy config for the backend services", zap.Error(err))
 		return reconcile.Result{}, err
 	}
 
+	if len(instance.Spec.TLS) > 0 {
+		if err := r.syncTLSSecrets(instance); err != nil {
+			r.log.Error("unable to roll out proxy for the tls secrets", zap.Error(err))
+			return reconcile.Result{}, err
+		}
+	}
+
+	if getTargetType(instance) == cfn.TargetTypeIP {
+		err = r.syncIPTargets(instance)
+		if err != nil {
+			r.log.Error("unable to register proxy pods with target group", zap.Error(err))
+			return reconcile.Result{}, err
+		}
+	} else {
+		err = r.attachTGToASG(instance)
+		if err != nil {
+			r.log.Error("unable to verify ASG after create/update", zap.Error(err))
+			return reconcile.Result{}, err
+		}
+	}
+
+	result, driftChanged := reconcile.Result{}, false
+	if r.managesStacks() {
+		result, driftChanged, err = r.checkDrift(instance)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
 	outputs := cfn.StackOutputMap(stack)
 
+	changed := setOutputAnnotations(instance, outputs)
+	if driftChanged {
+		changed = true
+	}
+	if clearRecoveryAttempts(instance) {
+		r.log.Info("stack recovered", zap.String("stackName", instance.ObjectMeta.Name))
+		changed = true
+	}
+
+	if changed {
+		r.log.Info("updating annotations from stack outputs")
+		if err := r.Update(context.TODO(), instance); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
 	u := outputs[cfn.OutputKeyNLBEndpoint]
 	if u == "" {
 		r.log.Error("unable to parse url from stack output", zap.Error(err), zap.String("output", outputs[cfn.OutputKeyNLBEndpoint]))
@@ -308,7 +594,7 @@ func (r *ReconcileIngress) Reconcile(request reconcile.Request) (reconcile.Resul
 	}
 
 	r.log.Info("Stack Create/Update Complete")
-	instance.Status = extensionsv1beta1.IngressStatus{
+	status := extensionsv1beta1.IngressStatus{
 		LoadBalancer: corev1.LoadBalancerStatus{
 			Ingress: []corev1.LoadBalancerIngress{
 				corev1.LoadBalancerIngress{Hostname: u},
@@ -316,26 +602,39 @@ func (r *ReconcileIngress) Reconcile(request reconcile.Request) (reconcile.Resul
 		},
 	}
 
-	return reconcile.Result{}, nil
+	if !reflect.DeepEqual(instance.Status, status) {
+		r.log.Info("updating ingress status", zap.String("hostname", u))
+		instance.Status = status
+		if err := r.Status().Update(context.TODO(), instance); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	return result, nil
 
 }
 
-func (r *ReconcileIngress) getASGsAndTargetGroup(instance *extensionsv1beta1.Ingress) ([]string, string, error) {
+func (r *ReconcileIngress) getASGsAndTargetGroups(instance *extensionsv1beta1.Ingress) ([]string, []string, error) {
 	stackName := instance.ObjectMeta.Name
 
-	network, err := r.fetchNetworkingInfo(instance)
+	network, err := r.fetchNetworkingInfo(instance, nil)
 	if err != nil {
 		r.log.Error("error fetching network information", zap.String("stackName", stackName))
-		return nil, "", err
+		return nil, nil, err
 	}
 
-	targetGroupARN, err := cfn.GetResourceID(r.cfnSvc, stackName, "TargetGroup")
+	targetGroupARN, tlsTargetGroupARN, err := r.provisioner.targetGroupARNs(instance)
 	if err != nil {
 		r.log.Error("error getting TargetGroupARN", zap.String("stackName", stackName))
-		return nil, "", err
+		return nil, nil, err
+	}
+
+	targetGroupARNs := []string{targetGroupARN}
+	if tlsTargetGroupARN != "" {
+		targetGroupARNs = append(targetGroupARNs, tlsTargetGroupARN)
 	}
 
-	return network.ASGNames, targetGroupARN, nil
+	return network.ASGNames, targetGroupARNs, nil
 }
 
 func (r *ReconcileIngress) getTargetGroupsFromASG(asgName string) ([]string, error) {
@@ -350,7 +649,7 @@ func (r *ReconcileIngress) getTargetGroupsFromASG(asgName string) ([]string, err
 }
 
 func (r *ReconcileIngress) attachTGToASG(instance *extensionsv1beta1.Ingress) error {
-	asgNames, targetGroupARN, err := r.getASGsAndTargetGroup(instance)
+	asgNames, targetGroupARNs, err := r.getASGsAndTargetGroups(instance)
 	if err != nil {
 		return err
 	}
@@ -364,9 +663,12 @@ func (r *ReconcileIngress) attachTGToASG(instance *extensionsv1beta1.Ingress) er
 			return err
 		}
 
-		if contains(existingTargetGroupARNs, targetGroupARN) {
-			r.log.Info("targetGroupARN already attached to ASG", zap.String("stackName", stackName), zap.String("asgName", asgName), zap.String("targetGroupARN", targetGroupARN))
-		} else {
+		for _, targetGroupARN := range targetGroupARNs {
+			if contains(existingTargetGroupARNs, targetGroupARN) {
+				r.log.Info("targetGroupARN already attached to ASG", zap.String("stackName", stackName), zap.String("asgName", asgName), zap.String("targetGroupARN", targetGroupARN))
+				continue
+			}
+
 			r.log.Info("attaching targetGroupARN to ASG", zap.String("stackName", stackName), zap.String("asgName", asgName), zap.String("targetGroupARN", targetGroupARN))
 			_, err = r.autoscalingSvc.AttachLoadBalancerTargetGroups(&autoscaling.AttachLoadBalancerTargetGroupsInput{
 				AutoScalingGroupName: aws.String(asgName),
@@ -383,7 +685,7 @@ func (r *ReconcileIngress) attachTGToASG(instance *extensionsv1beta1.Ingress) er
 }
 
 func (r *ReconcileIngress) detachTGFromASG(instance *extensionsv1beta1.Ingress) error {
-	asgNames, targetGroupARN, err := r.getASGsAndTargetGroup(instance)
+	asgNames, targetGroupARNs, err := r.getASGsAndTargetGroups(instance)
 	if err != nil {
 		return err
 	}
@@ -397,7 +699,12 @@ func (r *ReconcileIngress) detachTGFromASG(instance *extensionsv1beta1.Ingress)
 			return err
 		}
 
-		if contains(existingTargetGroupARNs, targetGroupARN) {
+		for _, targetGroupARN := range targetGroupARNs {
+			if !contains(existingTargetGroupARNs, targetGroupARN) {
+				r.log.Info("targetGroupARN already removed from ASG", zap.String("stackName", stackName), zap.String("asgName", asgName), zap.String("targetGroupARN", targetGroupARN))
+				continue
+			}
+
 			r.log.Info("detaching targetGroupARN from ASG", zap.String("stackName", stackName), zap.String("asgName", asgName), zap.String("targetGroupARN", targetGroupARN))
 			_, err = r.autoscalingSvc.DetachLoadBalancerTargetGroups(&autoscaling.DetachLoadBalancerTargetGroupsInput{
 				AutoScalingGroupName: aws.String(asgName),
@@ -407,8 +714,97 @@ func (r *ReconcileIngress) detachTGFromASG(instance *extensionsv1beta1.Ingress)
 				r.log.Error("error detaching targetGroupARN from ASG", zap.String("stackName", stackName), zap.String("asgName", asgName), zap.String("targetGroupARN", targetGroupARN))
 				return err
 			}
-		} else {
-			r.log.Info("targetGroupARN already removed from ASG", zap.String("stackName", stackName), zap.String("asgName", asgName), zap.String("targetGroupARN", targetGroupARN))
+		}
+	}
+
+	return nil
+}
+
+// syncIPTargets registers the ready reverse proxy pods with the target groups and deregisters the ones that are gone
+func (r *ReconcileIngress) syncIPTargets(instance *extensionsv1beta1.Ingress) error {
+	stackName := instance.ObjectMeta.Name
+
+	targetGroupARN, tlsTargetGroupARN, err := r.provisioner.targetGroupARNs(instance)
+	if err != nil {
+		r.log.Error("error getting TargetGroupARN", zap.String("stackName", stackName))
+		return err
+	}
+
+	endpoints := &corev1.Endpoints{}
+	if err := r.Get(context.TODO(), k8stypes.NamespacedName{Name: createReverseProxyResourceName(instance.Name), Namespace: instance.Namespace}, endpoints); err != nil && !errors.IsNotFound(err) {
+		r.log.Error("unable to fetch proxy endpoints", zap.Error(err))
+		return err
+	}
+
+	desired := map[string]bool{}
+	for _, subset := range endpoints.Subsets {
+		for _, address := range subset.Addresses {
+			desired[address.IP] = true
+		}
+	}
+
+	if err := r.syncTargetGroupIPs(instance, targetGroupARN, int64(getNginxServicePort(instance)), desired); err != nil {
+		return err
+	}
+
+	if tlsTargetGroupARN != "" {
+		return r.syncTargetGroupIPs(instance, tlsTargetGroupARN, int64(DefaultNginxTLSPort), desired)
+	}
+
+	return nil
+}
+
+// syncTargetGroupIPs registers the desired pod ips with the target group on port and deregisters the other targets
+func (r *ReconcileIngress) syncTargetGroupIPs(instance *extensionsv1beta1.Ingress, targetGroupARN string, port int64, desired map[string]bool) error {
+	stackName := instance.ObjectMeta.Name
+
+	health, err := r.elbv2Svc.DescribeTargetHealth(&elbv2.DescribeTargetHealthInput{
+		TargetGroupArn: aws.String(targetGroupARN),
+	})
+	if err != nil {
+		r.log.Error("error describing target health", zap.String("stackName", stackName), zap.String("targetGroupARN", targetGroupARN))
+		return err
+	}
+
+	// A pod ip registered on another port is replaced by a target on port
+	current := map[string]bool{}
+	deregister := []*elbv2.TargetDescription{}
+	for _, description := range health.TargetHealthDescriptions {
+		id := aws.StringValue(description.Target.Id)
+		if desired[id] && aws.Int64Value(description.Target.Port) == port {
+			current[id] = true
+			continue
+		}
+
+		deregister = append(deregister, description.Target)
+	}
+
+	register := []*elbv2.TargetDescription{}
+	for _, ip := range getListFromMap(desired) {
+		if !current[ip] {
+			register = append(register, &elbv2.TargetDescription{Id: aws.String(ip), Port: aws.Int64(port)})
+		}
+	}
+
+	if len(register) > 0 {
+		r.log.Info("registering proxy pods with targetGroupARN", zap.String("stackName", stackName), zap.String("targetGroupARN", targetGroupARN), zap.Int("count", len(register)))
+		if _, err := r.elbv2Svc.RegisterTargets(&elbv2.RegisterTargetsInput{
+			TargetGroupArn: aws.String(targetGroupARN),
+			Targets:        register,
+		}); err != nil {
+			r.log.Error("error registering targets", zap.String("stackName", stackName), zap.String("targetGroupARN", targetGroupARN))
+			return err
+		}
+	}
+
+	if len(deregister) > 0 {
+		r.log.Info("deregistering proxy pods from targetGroupARN", zap.String("stackName", stackName), zap.String("targetGroupARN", targetGroupARN), zap.Int("count", len(deregister)))
+		if _, err := r.elbv2Svc.DeregisterTargets(&elbv2.DeregisterTargetsInput{
+			TargetGroupArn: aws.String(targetGroupARN),
+			Targets:        deregister,
+		}); err != nil {
+			r.log.Error("error deregistering targets", zap.String("stackName", stackName), zap.String("targetGroupARN", targetGroupARN))
+			return err
 		}
 	}
 
@@ -425,19 +821,33 @@ func contains(records []string, key string) bool {
 }
 
 func (r *ReconcileIngress) delete(instance *extensionsv1beta1.Ingress) (*extensionsv1beta1.Ingress, *reconcile.Result, error) {
-	stack, err := cfn.DescribeStack(r.cfnSvc, instance.ObjectMeta.Name)
-	if err != nil && cfn.IsDoesNotExist(err, instance.ObjectMeta.Name) {
-		r.log.Info("stack doesn't exist, removing finalizer", zap.String("stackName", instance.ObjectMeta.Name))
-		instance.SetFinalizers(finalizers.RemoveFinalizer(instance, FinalizerCFNStack))
-		return instance, &reconcile.Result{}, nil
-	}
-
+	stack, err := r.provisioner.describe(instance)
 	if err != nil {
 		r.log.Error("error describing nlb cloudformation stack", zap.String("stackName", instance.ObjectMeta.Name), zap.Error(err))
 		return nil, nil, err
 	}
 
+	// An exported template can be waiting for the pipeline without a stack, it is removed so the pipeline never creates one
+	if stack == nil {
+		if err := r.provisioner.delete(instance); err != nil {
+			r.log.Error("error deleting nlb cloudformation stack", zap.Error(err))
+			return nil, nil, err
+		}
+
+		r.log.Info("stack doesn't exist, removing finalizer", zap.String("stackName", instance.ObjectMeta.Name))
+		instance.SetFinalizers(finalizers.RemoveFinalizer(instance, FinalizerCFNStack))
+		return instance, &reconcile.Result{}, nil
+	}
+
 	if cfn.IsDeleting(*stack.StackStatus) {
+		// The direct provisioner deletes the target groups once the load balancer is gone, there is no stack deleting them
+		if r.provisionsDirectly() {
+			if err := r.provisioner.delete(instance); err != nil {
+				r.log.Error("error deleting nlb target groups", zap.Error(err))
+				return nil, nil, err
+			}
+		}
+
 		r.log.Info("retrying delete in 5 seconds", zap.String("status", *stack.StackStatus))
 		return instance, &reconcile.Result{RequeueAfter: 5 * time.Second}, nil
 	}
@@ -448,30 +858,110 @@ func (r *ReconcileIngress) delete(instance *extensionsv1beta1.Ingress) (*extensi
 		return instance, &reconcile.Result{}, nil
 	}
 
-	// We want to retry delete even if DELETE_FAILED since removing Loadbalancer/VPCLink can be a bit finnicky
+	// Failed deletes are retried with backoff, removing Loadbalancer/VPCLink can be a bit finnicky
+	if *stack.StackStatus == cloudformation.StackStatusDeleteFailed {
+		result, err := r.recover(instance, stack)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if err := r.Update(context.TODO(), instance); err != nil {
+			return nil, nil, err
+		}
+
+		return instance, &result, nil
+	}
+
 	r.log.Info(
 		"deleting nlb cloudformation stack",
 		zap.String("stackName", instance.ObjectMeta.Name),
 		zap.String("status", *stack.StackStatus),
 	)
 
-	err = r.detachTGFromASG(instance)
-	if err != nil {
-		r.log.Error("unable to verify ASG before delete", zap.Error(err))
-		return nil, nil, err
+	// The stack keeps the ASG attachments of the target type it was built with, whatever the annotation says now
+	if getStackTargetType(stack) == cfn.TargetTypeInstance {
+		err = r.detachTGFromASG(instance)
+		if err != nil {
+			r.log.Error("unable to verify ASG before delete", zap.Error(err))
+			return nil, nil, err
+		}
 	}
 
-	if _, err := r.cfnSvc.DeleteStack(&cloudformation.DeleteStackInput{
-		StackName: aws.String(instance.GetObjectMeta().GetName()),
-	}); err != nil {
+	if err := r.provisioner.delete(instance); err != nil {
 		r.log.Error("error deleting nlb cloudformation stack", zap.Error(err))
 		return nil, nil, err
 	}
 
+	// The pipeline deletes the stack once the template is gone, check back until it has
+	if r.exportsStacks() {
+		return instance, &reconcile.Result{RequeueAfter: 20 * time.Second}, nil
+	}
+
+	// The load balancer is deleted without waiting, check back to delete its target groups
+	if r.provisionsDirectly() {
+		return instance, &reconcile.Result{RequeueAfter: 5 * time.Second}, nil
+	}
+
 	return instance, &reconcile.Result{}, nil
 }
 
-func (r *ReconcileIngress) buildReverseProxyResources(instance *extensionsv1beta1.Ingress) []metav1.Object {
+// replacementChangeSetName names the replacement of the stack for approval, after the settings that replace the load balancer
+// so a later edit needs a new approval
+func replacementChangeSetName(instance *extensionsv1beta1.Ingress) string {
+	addresses, _ := getAddressConfig(instance)
+	return cfn.ChangeSetName([]byte(cfn.OutputValue(map[string]string{
+		"scheme":     getScheme(instance),
+		"targetType": getTargetType(instance),
+		"addresses":  cfn.OutputValue(addresses),
+	})))
+}
+
+// approveReplacement records the replacement of the load balancer on the ingress like a change set,
+// it returns false while the replacement waits for approval
+func approveReplacement(instance *extensionsv1beta1.Ingress) bool {
+	requireApproval, _ := strconv.ParseBool(instance.ObjectMeta.Annotations[IngressAnnotationRequireApproval])
+	if !requireApproval {
+		return true
+	}
+
+	changeSetName := replacementChangeSetName(instance)
+	instance.ObjectMeta.Annotations[IngressAnnotationChangeSet] = changeSetName
+	instance.ObjectMeta.Annotations[IngressAnnotationPlannedChanges] = cfn.OutputValue([]cfn.PlannedChange{
+		{
+			LogicalID:    cfn.LoadBalancerResourceName,
+			ResourceType: "AWS::ElasticLoadBalancingV2::LoadBalancer",
+			Action:       cloudformation.ChangeActionModify,
+			Replacement:  true,
+		},
+	})
+
+	return instance.ObjectMeta.Annotations[IngressAnnotationApprovedChangeSet] == changeSetName
+}
+
+// replace deletes the stack so it gets recreated on a later reconcile, used for changes CloudFormation can not apply in place
+func (r *ReconcileIngress) replace(instance *extensionsv1beta1.Ingress, targetType string) error {
+	if targetType == cfn.TargetTypeInstance {
+		err := r.detachTGFromASG(instance)
+		if err != nil {
+			r.log.Error("unable to verify ASG before replace", zap.Error(err))
+			return err
+		}
+	}
+
+	if err := r.provisioner.delete(instance); err != nil {
+		r.log.Error("error deleting nlb cloudformation stack for replacement", zap.Error(err))
+		return err
+	}
+
+	// An approval only covers the replacement it names, and the new stack has not drifted
+	delete(instance.ObjectMeta.Annotations, IngressAnnotationApprovedChangeSet)
+	delete(instance.ObjectMeta.Annotations, IngressAnnotationDriftStatus)
+
+	return nil
+}
+
+// buildReverseProxyResources builds the ConfigMap, Deployment and Service of the proxy, with the backends proxied to upstreams
+func (r *ReconcileIngress) buildReverseProxyResources(instance *extensionsv1beta1.Ingress, upstreams backendUpstreams) []metav1.Object {
 	resourceName := createReverseProxyResourceName(instance.Name)
 
 	configMap := &corev1.ConfigMap{
@@ -484,7 +974,7 @@ func (r *ReconcileIngress) buildReverseProxyResources(instance *extensionsv1beta
 			Namespace: instance.Namespace,
 		},
 		Data: map[string]string{
-			"nginx.conf": buildNginxConfig(instance),
+			"nginx.conf": buildNginxConfig(instance, upstreams),
 		},
 	}
 
@@ -538,6 +1028,38 @@ func (r *ReconcileIngress) buildReverseProxyResources(instance *extensionsv1beta
 		},
 	}
 
+	// The secrets of the tls section are mounted next to the config, nginx reads them when it starts
+	for i, secretName := range getTLSSecretNames(instance) {
+		volumeName := fmt.Sprintf("tls-%d", i)
+		deploy.Spec.Template.Spec.Volumes = append(deploy.Spec.Template.Spec.Volumes, corev1.Volume{
+			Name: volumeName,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					DefaultMode: &defaultMode,
+					SecretName:  secretName,
+				},
+			},
+		})
+		deploy.Spec.Template.Spec.Containers[0].VolumeMounts = append(deploy.Spec.Template.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+			MountPath: path.Join(nginxTLSPath, secretName),
+			Name:      volumeName,
+			ReadOnly:  true,
+		})
+	}
+
+	return []metav1.Object{configMap, deploy, buildReverseProxyService(instance)}
+}
+
+// buildReverseProxyService builds the Service of the proxy the load balancer targets
+func buildReverseProxyService(instance *extensionsv1beta1.Ingress) *corev1.Service {
+	resourceName := createReverseProxyResourceName(instance.Name)
+
+	// ip targets reach the proxy pods directly, there is no need to expose a NodePort
+	serviceType := corev1.ServiceTypeNodePort
+	if getTargetType(instance) == cfn.TargetTypeIP {
+		serviceType = corev1.ServiceTypeClusterIP
+	}
+
 	service := &corev1.Service{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "v1",
@@ -554,23 +1076,113 @@ func (r *ReconcileIngress) buildReverseProxyResources(instance *extensionsv1beta
 					Protocol: "TCP",
 					Port:     int32(getNginxServicePort(instance)),
 				},
+				corev1.ServicePort{
+					Name:     "healthz",
+					Protocol: "TCP",
+					Port:     int32(DefaultNginxHealthzPort),
+				},
 			},
 			Selector:        map[string]string{"deployment": resourceName},
 			SessionAffinity: corev1.ServiceAffinityNone,
-			Type:            corev1.ServiceTypeNodePort,
+			Type:            serviceType,
 		},
 	}
 
-	return []metav1.Object{configMap, deploy, service}
+	if len(instance.Spec.TLS) > 0 {
+		service.Spec.Ports = append(service.Spec.Ports, corev1.ServicePort{
+			Name:     "https",
+			Protocol: "TCP",
+			Port:     int32(DefaultNginxTLSPort),
+		})
+	}
+
+	return service
+}
+
+// keepNodePorts copies the NodePorts of the current proxy Service to the ports of the same name, so recreating the proxy
+// does not move the targets of the load balancer
+func keepNodePorts(service *corev1.Service, current *corev1.Service) {
+	if current == nil || service.Spec.Type != corev1.ServiceTypeNodePort || current.Spec.Type != corev1.ServiceTypeNodePort {
+		return
+	}
+
+	for i := range service.Spec.Ports {
+		if port := findServicePort(current, intstr.FromString(service.Spec.Ports[i].Name)); port != nil {
+			service.Spec.Ports[i].NodePort = port.NodePort
+		}
+	}
+}
+
+// proxyService returns the proxy Service for the template of the load balancer. Ports the ingress now needs are added in place,
+// keeping the NodePorts the load balancer targets, the proxy is only created when it is missing or changes type.
+func (r *ReconcileIngress) proxyService(instance *extensionsv1beta1.Ingress) (*corev1.Service, error) {
+	svc := &corev1.Service{}
+	err := r.Get(context.TODO(), k8stypes.NamespacedName{Name: createReverseProxyResourceName(instance.Name), Namespace: instance.Namespace}, svc)
+	if errors.IsNotFound(err) {
+		return r.updateReverseProxy(instance)
+	} else if err != nil {
+		r.log.Error("unable to fetch proxy service", zap.Error(err))
+		return nil, err
+	}
+
+	desired := buildReverseProxyService(instance)
+	if svc.Spec.Type != desired.Spec.Type {
+		return r.updateReverseProxy(instance)
+	}
+
+	added := false
+	for _, port := range desired.Spec.Ports {
+		if findServicePort(svc, intstr.FromString(port.Name)) == nil {
+			svc.Spec.Ports = append(svc.Spec.Ports, port)
+			added = true
+		}
+	}
+
+	if added {
+		r.log.Info("adding ports to proxy service", zap.String("name", svc.Name))
+		if err := r.Update(context.TODO(), svc); err != nil {
+			return nil, err
+		}
+	}
+
+	return svc, nil
 }
 
 func (r *ReconcileIngress) updateReverseProxy(instance *extensionsv1beta1.Ingress) (*corev1.Service, error) {
-	objects := r.buildReverseProxyResources(instance)
+	checksum, err := r.getTLSChecksum(instance)
+	if err != nil {
+		r.recorder.Event(instance, corev1.EventTypeWarning, "InvalidTLSSecret", err.Error())
+		return nil, err
+	}
+
+	upstreams, err := r.resolveBackends(instance)
+	if err != nil {
+		return nil, err
+	}
+
+	name := k8stypes.NamespacedName{Name: createReverseProxyResourceName(instance.Name), Namespace: instance.Namespace}
+	current := &corev1.Service{}
+	if err := r.Get(context.TODO(), name, current); errors.IsNotFound(err) {
+		current = nil
+	} else if err != nil {
+		r.log.Error("unable to fetch proxy service", zap.Error(err))
+		return nil, err
+	}
+
+	objects := r.buildReverseProxyResources(instance, upstreams)
 	for _, object := range objects {
 		if err := controllerutil.SetControllerReference(instance, object, r.scheme); err != nil {
 			return nil, err
 		}
 
+		if deploy, ok := object.(*appsv1.Deployment); ok && checksum != "" {
+			setTLSChecksum(deploy, checksum)
+		}
+
+		if service, ok := object.(*corev1.Service); ok {
+			keepNodePorts(service, current)
+		}
+
 		runtimeObject := object.(runtime.Object)
 
 		// Fix update issue on reverse proxy. Deleting current resource. Need to find reason for this
@@ -585,7 +1197,7 @@ func (r *ReconcileIngress) updateReverseProxy(instance *extensionsv1beta1.Ingres
 
 	r.log.Info("fetching proxy service details")
 	svc := &corev1.Service{}
-	if err := r.Get(context.TODO(), k8stypes.NamespacedName{Name: createReverseProxyResourceName(instance.Name), Namespace: instance.Namespace}, svc); err != nil {
+	if err := r.Get(context.TODO(), name, svc); err != nil {
 		r.log.Error("unable to fetch proxy service", zap.Error(err))
 
 		return nil, err
@@ -595,43 +1207,31 @@ func (r *ReconcileIngress) updateReverseProxy(instance *extensionsv1beta1.Ingres
 }
 
 func (r *ReconcileIngress) create(instance *extensionsv1beta1.Ingress) (*extensionsv1beta1.Ingress, error) {
-	r.log.Info("creating reverse proxy")
-	svc, err := r.updateReverseProxy(instance)
+	cfg, err := buildTemplateConfig(instance)
 	if err != nil {
-		r.log.Error("error creating proxy resources", zap.Error(err))
+		r.log.Error("invalid ingress annotations", zap.Error(err))
 		return nil, err
 	}
 
-	// Fetch worker node networking info (grabs all nodes for now)
-	network, err := r.fetchNetworkingInfo(instance)
+	// An existing proxy keeps its NodePorts, like the one of an exported template still waiting for the pipeline
+	r.log.Info("creating reverse proxy")
+	svc, err := r.proxyService(instance)
 	if err != nil {
-		r.log.Error("unable to fetch networking info", zap.Error(err))
+		r.log.Error("error creating proxy resources", zap.Error(err))
 		return nil, err
 	}
 
-	cfnTemplate := cfn.BuildNLBTemplateFromIngressRule(&cfn.TemplateConfig{
-		Network:  network,
-		Rule:     instance.Spec.Rules[0],
-		NodePort: int(svc.Spec.Ports[0].NodePort),
-	})
-
-	b, err := cfnTemplate.YAML()
+	// Fetch worker node networking info (grabs all nodes for now)
+	network, err := r.fetchNetworkingInfo(instance, nil)
 	if err != nil {
+		r.log.Error("unable to fetch networking info", zap.Error(err))
 		return nil, err
 	}
 
-	r.log.Info("creating cloudformation stack")
-	if _, err := r.cfnSvc.CreateStack(&cloudformation.CreateStackInput{
-		TemplateBody: aws.String(string(b)),
-		StackName:    aws.String(instance.GetObjectMeta().GetName()),
-		Capabilities: aws.StringSlice([]string{"CAPABILITY_IAM"}),
-		Tags: []*cloudformation.Tag{
-			{
-				Key:   aws.String("managedBy"),
-				Value: aws.String("amazon-nlb-ingress-controller"),
-			},
-		},
-	}); err != nil {
+	cfg.Network = network
+	cfg.NodePort, cfg.HealthCheckNodePort = getTargetPorts(instance, svc)
+	cfg.TLSNodePort = getTLSTargetPort(instance, svc)
+	if err := r.provisioner.create(instance, cfg); err != nil {
 		return nil, err
 	}
 
@@ -641,44 +1241,137 @@ func (r *ReconcileIngress) create(instance *extensionsv1beta1.Ingress) (*extensi
 	return instance, nil
 }
 
-func (r *ReconcileIngress) update(instance *extensionsv1beta1.Ingress, stack *cloudformation.Stack) error {
-	network, err := r.fetchNetworkingInfo(instance)
+// update applies the ingress to the load balancer through the provisioner. The template targets the NodePorts the proxy
+// has now, the proxy is only rebuilt once the change is applied so a change waiting for approval keeps serving.
+func (r *ReconcileIngress) update(instance *extensionsv1beta1.Ingress, stack *cloudformation.Stack) (updateStatus, error) {
+	cfg, err := buildTemplateConfig(instance)
 	if err != nil {
-		r.log.Error("unable to fetch networking info", zap.Error(err))
-		return err
+		r.log.Error("invalid ingress annotations", zap.Error(err))
+		return updatePending, err
 	}
 
-	r.log.Info("updating proxy")
-	svc, err := r.updateReverseProxy(instance)
+	subnetIDs, err := r.provisioner.subnetIDs(instance)
 	if err != nil {
-		r.log.Error("error creating proxy resources", zap.Error(err))
-		return err
+		r.log.Error("unable to fetch load balancer subnets", zap.Error(err))
+		return updatePending, err
 	}
 
-	cfnTemplate := cfn.BuildNLBTemplateFromIngressRule(&cfn.TemplateConfig{
-		Network:  network,
-		Rule:     instance.Spec.Rules[0],
-		NodePort: int(svc.Spec.Ports[0].NodePort),
-	})
-	b, err := cfnTemplate.YAML()
+	network, err := r.fetchNetworkingInfo(instance, subnetIDs)
 	if err != nil {
-		return err
+		r.log.Error("unable to fetch networking info", zap.Error(err))
+		return updatePending, err
 	}
 
-	if _, err := r.cfnSvc.UpdateStack(&cloudformation.UpdateStackInput{
-		TemplateBody: aws.String(string(b)),
-		StackName:    aws.String(instance.GetObjectMeta().GetName()),
-		Capabilities: aws.StringSlice([]string{"CAPABILITY_IAM"}),
-		Tags: []*cloudformation.Tag{
-			{
-				Key:   aws.String("managedBy"),
-				Value: aws.String("aws-nlb-ingress-controller"),
+	svc, err := r.proxyService(instance)
+	if err != nil {
+		r.log.Error("error fetching proxy service", zap.Error(err))
+		return updatePending, err
+	}
+
+	cfg.Network = network
+	cfg.NodePort, cfg.HealthCheckNodePort = getTargetPorts(instance, svc)
+	cfg.TLSNodePort = getTLSTargetPort(instance, svc)
+	status, err := r.provisioner.update(instance, cfg)
+	if err != nil || status != updateApplied {
+		return status, err
+	}
+
+	r.log.Info("updating proxy")
+	if _, err := r.updateReverseProxy(instance); err != nil {
+		r.log.Error("error creating proxy resources", zap.Error(err))
+		return status, err
+	}
+
+	return status, nil
+}
+
+// applyChangeSet moves the stack to templateBody through a change set, recording the planned changes on the ingress.
+// It returns updateWaiting when the change set replaces resources and waits for approval.
+func (r *ReconcileIngress) applyChangeSet(instance *extensionsv1beta1.Ingress, templateBody []byte) (updateStatus, error) {
+	stackName := instance.GetObjectMeta().GetName()
+	changeSetName := cfn.ChangeSetName(templateBody)
+
+	changeSet, err := cfn.DescribeChangeSet(r.cfnSvc, stackName, changeSetName)
+	if err != nil && cfn.IsChangeSetNotFound(err) {
+		if err := cfn.DeleteStaleChangeSets(r.cfnSvc, stackName, changeSetName); err != nil {
+			r.log.Error("unable to delete stale change sets", zap.Error(err))
+			return updatePending, err
+		}
+
+		r.log.Info("creating change set", zap.String("changeSet", changeSetName))
+		if _, err := r.cfnSvc.CreateChangeSet(&cloudformation.CreateChangeSetInput{
+			ChangeSetName: aws.String(changeSetName),
+			ChangeSetType: aws.String(cloudformation.ChangeSetTypeUpdate),
+			TemplateBody:  aws.String(string(templateBody)),
+			StackName:     aws.String(stackName),
+			Capabilities:  aws.StringSlice([]string{"CAPABILITY_IAM"}),
+			Tags: []*cloudformation.Tag{
+				{
+					Key:   aws.String("managedBy"),
+					Value: aws.String("aws-nlb-ingress-controller"),
+				},
 			},
-		},
+		}); err != nil {
+			r.log.Error("unable to create change set", zap.Error(err))
+			return updatePending, err
+		}
+
+		return updatePending, nil
+	} else if err != nil {
+		r.log.Error("error describing change set", zap.Error(err))
+		return updatePending, err
+	}
+
+	if cfn.IsChangeSetPending(changeSet) {
+		return updatePending, nil
+	}
+
+	if aws.StringValue(changeSet.Status) == cloudformation.ChangeSetStatusFailed {
+		// Failed change sets are deleted so the next reconcile starts over
+		if _, err := r.cfnSvc.DeleteChangeSet(&cloudformation.DeleteChangeSetInput{
+			StackName:     aws.String(stackName),
+			ChangeSetName: aws.String(changeSetName),
+		}); err != nil {
+			return updatePending, err
+		}
+
+		if cfn.IsChangeSetEmpty(changeSet) {
+			// The template has no changes to put the drifted properties back, the stack is replaced instead
+			if shouldRemediateDrift(instance) {
+				r.recorder.Event(instance, corev1.EventTypeWarning, "DriftNotRemediated", "CloudFormation has no changes to apply, replacing the stack")
+				instance.ObjectMeta.Annotations[IngressAnnotationDriftStatus] = DriftStatusNotRemediated
+			}
+			return updateApplied, nil
+		}
+
+		return updatePending, fmt.Errorf("change set %s failed: %s", changeSetName, aws.StringValue(changeSet.StatusReason))
+	}
+
+	changes := cfn.PlannedChanges(changeSet)
+	instance.ObjectMeta.Annotations[IngressAnnotationChangeSet] = changeSetName
+	instance.ObjectMeta.Annotations[IngressAnnotationPlannedChanges] = cfn.OutputValue(changes)
+
+	requireApproval, _ := strconv.ParseBool(instance.ObjectMeta.Annotations[IngressAnnotationRequireApproval])
+	if requireApproval && cfn.HasReplacement(changes) && instance.ObjectMeta.Annotations[IngressAnnotationApprovedChangeSet] != changeSetName {
+		return updateWaiting, nil
+	}
+
+	r.log.Info("executing change set", zap.String("changeSet", changeSetName))
+	if _, err := r.cfnSvc.ExecuteChangeSet(&cloudformation.ExecuteChangeSetInput{
+		StackName:     aws.String(stackName),
+		ChangeSetName: aws.String(changeSetName),
 	}); err != nil {
-		r.log.Error("unable to fetch proxy service", zap.Error(err))
-		return err
+		r.log.Error("unable to execute change set", zap.Error(err))
+		return updatePending, err
 	}
 
-	return nil
+	// An approval only covers the change set it names
+	delete(instance.ObjectMeta.Annotations, IngressAnnotationApprovedChangeSet)
+
+	// The next drift check tells whether the update remediated the drift
+	if shouldRemediateDrift(instance) {
+		delete(instance.ObjectMeta.Annotations, IngressAnnotationDriftStatus)
+	}
+
+	return updateApplied, nil
 }
@@ -16,7 +16,12 @@ limitations under the License.
 package ingress
 
 import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -25,15 +30,23 @@ import (
 	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
 	"github.com/aws/aws-sdk-go/service/cloudformation"
 	"github.com/aws/aws-sdk-go/service/cloudformation/cloudformationiface"
+	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/elbv2"
 	controllercfn "github.com/danushkaf/aws-nlb-ingress-controller/pkg/cloudformation"
+	"github.com/danushkaf/aws-nlb-ingress-controller/pkg/finalizers"
 	"github.com/danushkaf/aws-nlb-ingress-controller/pkg/logging"
+	"github.com/danushkaf/aws-nlb-ingress-controller/pkg/network"
 	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -309,7 +322,7 @@ func TestReconcileIngress_Reconcile(t *testing.T) {
 			wantErr: true,
 		},
 		{
-			name: "if cfn stack has failed status",
+			name: "if cfn stack has failed status - delete it to recreate",
 			fields: fields{
 				Client: fakeclient.NewFakeClient(newMockIngress("failed", false, false)),
 				cfnSvc: &mockCloudformation{
@@ -331,7 +344,7 @@ func TestReconcileIngress_Reconcile(t *testing.T) {
 					},
 				},
 			},
-			want:    reconcile.Result{},
+			want:    reconcile.Result{RequeueAfter: 20 * time.Second},
 			wantErr: false,
 		},
 		{
@@ -816,8 +829,10 @@ func TestReconcileIngress_Reconcile(t *testing.T) {
 				cfnSvc:         tt.fields.cfnSvc,
 				ec2Svc:         tt.fields.ec2Svc,
 				autoscalingSvc: tt.fields.austoscalingSvc,
+				recorder:       &record.FakeRecorder{},
 				log:            tt.fields.log,
 			}
+			r.provisioner = &cloudFormationProvisioner{r}
 			got, err := r.Reconcile(tt.args.request)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("ReconcileIngress.Reconcile() error = %v, wantErr %v", err, tt.wantErr)
@@ -830,6 +845,175 @@ func TestReconcileIngress_Reconcile(t *testing.T) {
 	}
 }
 
+func TestReconcileIngress_replace(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		outputs     map[string]string
+		// approve approves the replacement the ingress asks for
+		approve           bool
+		want              reconcile.Result
+		wantDeleted       bool
+		wantPlannedChange bool
+	}{
+		{
+			name:        "replaces the stack when the scheme changed",
+			annotations: map[string]string{IngressAnnotationScheme: "internet-facing"},
+			outputs:     map[string]string{controllercfn.OutputKeyScheme: "internal"},
+			want:        reconcile.Result{RequeueAfter: 20 * time.Second},
+			wantDeleted: true,
+		},
+		{
+			name:        "replaces the stack when the elastic ips changed",
+			annotations: map[string]string{IngressAnnotationScheme: "internet-facing", IngressAnnotationEIPAllocations: "eipalloc-foo"},
+			outputs:     map[string]string{controllercfn.OutputKeyScheme: "internet-facing"},
+			want:        reconcile.Result{RequeueAfter: 20 * time.Second},
+			wantDeleted: true,
+		},
+		{
+			name:        "replaces the stack for drift the update did not remediate",
+			annotations: map[string]string{IngressAnnotationDriftPolicy: DriftPolicyRemediate, IngressAnnotationDriftStatus: DriftStatusNotRemediated},
+			outputs:     map[string]string{controllercfn.OutputKeyScheme: "internal"},
+			want:        reconcile.Result{RequeueAfter: 20 * time.Second},
+			wantDeleted: true,
+		},
+		{
+			name:              "waits for approval of the replacement",
+			annotations:       map[string]string{IngressAnnotationScheme: "internet-facing", IngressAnnotationRequireApproval: "true"},
+			outputs:           map[string]string{controllercfn.OutputKeyScheme: "internal"},
+			want:              reconcile.Result{},
+			wantPlannedChange: true,
+		},
+		{
+			name:              "approval of an earlier replacement",
+			annotations:       map[string]string{IngressAnnotationScheme: "internet-facing", IngressAnnotationRequireApproval: "true", IngressAnnotationApprovedChangeSet: "nlb-ingress-00000000"},
+			outputs:           map[string]string{controllercfn.OutputKeyScheme: "internal"},
+			want:              reconcile.Result{},
+			wantPlannedChange: true,
+		},
+		{
+			name:              "replaces the stack once the replacement is approved",
+			annotations:       map[string]string{IngressAnnotationScheme: "internet-facing", IngressAnnotationRequireApproval: "true"},
+			outputs:           map[string]string{controllercfn.OutputKeyScheme: "internal"},
+			approve:           true,
+			want:              reconcile.Result{RequeueAfter: 20 * time.Second},
+			wantDeleted:       true,
+			wantPlannedChange: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := newMockIngress("replace", false, true)
+			for k, v := range tt.annotations {
+				instance.Annotations[k] = v
+			}
+			if tt.approve {
+				instance.Annotations[IngressAnnotationApprovedChangeSet] = replacementChangeSetName(instance)
+			}
+
+			outputs := []*cloudformation.Output{
+				{OutputKey: aws.String(controllercfn.OutputKeyNLBEndpoint), OutputValue: aws.String("foo.com")},
+			}
+			for k, v := range tt.outputs {
+				outputs = append(outputs, &cloudformation.Output{OutputKey: aws.String(k), OutputValue: aws.String(v)})
+			}
+			cfnSvc := &mockCloudformation{
+				Stacks: map[string]*cloudformation.Stack{
+					"replace": &cloudformation.Stack{
+						StackStatus: aws.String(cloudformation.StackStatusCreateComplete),
+						Outputs:     outputs,
+					},
+				},
+			}
+
+			r := &ReconcileIngress{
+				Client:         fakeclient.NewFakeClient(instance, newMockNodeList()),
+				scheme:         scheme.Scheme,
+				cfnSvc:         cfnSvc,
+				ec2Svc:         &mockEC2{},
+				autoscalingSvc: &mockAutoscaling{},
+				recorder:       &record.FakeRecorder{},
+				log:            logging.New(),
+			}
+			r.provisioner = &cloudFormationProvisioner{r}
+			got, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "replace", Namespace: "default"}})
+			if err != nil {
+				t.Fatalf("ReconcileIngress.Reconcile() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReconcileIngress.Reconcile() = %v, want %v", got, tt.want)
+			}
+			if _, ok := cfnSvc.Stacks["replace"]; ok == tt.wantDeleted {
+				t.Errorf("ReconcileIngress.Reconcile() deleted the stack = %v, want %v", !ok, tt.wantDeleted)
+			}
+
+			stored := &extensionsv1beta1.Ingress{}
+			if err := r.Get(context.TODO(), types.NamespacedName{Name: "replace", Namespace: "default"}, stored); err != nil {
+				t.Fatal(err)
+			}
+			if (stored.Annotations[IngressAnnotationPlannedChanges] != "") != tt.wantPlannedChange {
+				t.Errorf("ReconcileIngress.Reconcile() planned changes = %q, want planned changes %v", stored.Annotations[IngressAnnotationPlannedChanges], tt.wantPlannedChange)
+			}
+			if tt.wantPlannedChange && stored.Annotations[IngressAnnotationChangeSet] != replacementChangeSetName(instance) {
+				t.Errorf("ReconcileIngress.Reconcile() change set = %q, want %q", stored.Annotations[IngressAnnotationChangeSet], replacementChangeSetName(instance))
+			}
+			if tt.wantDeleted && stored.Annotations[IngressAnnotationApprovedChangeSet] != "" {
+				t.Errorf("ReconcileIngress.Reconcile() kept the approval after replacing")
+			}
+			if tt.wantDeleted && stored.Annotations[IngressAnnotationDriftStatus] != "" {
+				t.Errorf("ReconcileIngress.Reconcile() kept the drift status after replacing")
+			}
+		})
+	}
+}
+
+func TestReconcileIngress_status(t *testing.T) {
+	instance := newMockIngress("status", false, true)
+	cfg, err := buildTemplateConfig(instance)
+	if err != nil {
+		t.Fatalf("buildTemplateConfig() error = %v", err)
+	}
+
+	// The stack is up to date with the ingress
+	outputs := []*cloudformation.Output{
+		{OutputKey: aws.String(controllercfn.OutputKeyNLBEndpoint), OutputValue: aws.String("foo.com")},
+	}
+	for k, v := range cfg.WithDefaults().Outputs() {
+		outputs = append(outputs, &cloudformation.Output{OutputKey: aws.String(k), OutputValue: aws.String(v)})
+	}
+	cfnSvc := &mockCloudformation{
+		Stacks: map[string]*cloudformation.Stack{
+			"status": &cloudformation.Stack{
+				StackStatus: aws.String(cloudformation.StackStatusCreateComplete),
+				Outputs:     outputs,
+			},
+		},
+	}
+
+	r := &ReconcileIngress{
+		Client:         fakeclient.NewFakeClient(instance, newMockNodeList()),
+		scheme:         scheme.Scheme,
+		cfnSvc:         cfnSvc,
+		ec2Svc:         &mockEC2{},
+		autoscalingSvc: &mockAutoscaling{},
+		recorder:       &record.FakeRecorder{},
+		log:            logging.New(),
+	}
+	r.provisioner = &cloudFormationProvisioner{r}
+	if _, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "status", Namespace: "default"}}); err != nil {
+		t.Fatalf("ReconcileIngress.Reconcile() error = %v", err)
+	}
+
+	got := &extensionsv1beta1.Ingress{}
+	if err := r.Get(context.TODO(), types.NamespacedName{Name: "status", Namespace: "default"}, got); err != nil {
+		t.Fatal(err)
+	}
+	want := []corev1.LoadBalancerIngress{{Hostname: "foo.com"}}
+	if !reflect.DeepEqual(got.Status.LoadBalancer.Ingress, want) {
+		t.Errorf("ReconcileIngress.Reconcile() status = %v, want %v", got.Status.LoadBalancer.Ingress, want)
+	}
+}
+
 func TestReconcileIngress_create(t *testing.T) {
 	type fields struct {
 		Client          client.Client
@@ -876,8 +1060,10 @@ func TestReconcileIngress_create(t *testing.T) {
 				cfnSvc:         tt.fields.cfnSvc,
 				ec2Svc:         tt.fields.ec2Svc,
 				autoscalingSvc: tt.fields.austoscalingSvc,
+				recorder:       &record.FakeRecorder{},
 				log:            tt.fields.log,
 			}
+			r.provisioner = &cloudFormationProvisioner{r}
 			got, err := r.create(tt.args.instance)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("ReconcileIngress.create() error = %v, wantErr %v", err, tt.wantErr)
@@ -890,6 +1076,35 @@ func TestReconcileIngress_create(t *testing.T) {
 	}
 }
 
+func TestReconcileIngress_deleteExported(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "foobar"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "foobar", ExportTemplateKey), []byte("template"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &ReconcileIngress{
+		Client: fakeclient.NewFakeClient(),
+		cfnSvc: &mockCloudformation{Stacks: map[string]*cloudformation.Stack{}},
+		log:    logging.New(),
+	}
+	r.provisioner = &exportProvisioner{r: r, dir: dir}
+
+	// The pipeline has not created the stack yet
+	instance, _, err := r.delete(newMockIngress("foobar", true, true))
+	if err != nil {
+		t.Fatalf("ReconcileIngress.delete() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "foobar")); !os.IsNotExist(err) {
+		t.Errorf("ReconcileIngress.delete() left the exported template, stat error = %v", err)
+	}
+	if finalizers.HasFinalizer(instance, FinalizerCFNStack) {
+		t.Errorf("ReconcileIngress.delete() kept the finalizer")
+	}
+}
+
 func TestReconcileIngress_delete(t *testing.T) {
 	type fields struct {
 		Client          client.Client
@@ -1085,6 +1300,36 @@ func TestReconcileIngress_delete(t *testing.T) {
 			want1:   nil,
 			wantErr: true,
 		},
+		{
+			name: "detaches targetGroupARN of an instance stack after the target type annotation changed",
+			fields: fields{
+				scheme: scheme.Scheme,
+				Client: fakeclient.NewFakeClient(newMockNodeList()),
+				cfnSvc: &mockCloudformation{
+					Stacks: map[string]*cloudformation.Stack{
+						"foobar": &cloudformation.Stack{
+							StackStatus: aws.String(cloudformation.StackStatusCreateComplete),
+						},
+					},
+				},
+				ec2Svc:          &mockEC2{getASGTag: true},
+				austoscalingSvc: &mockAutoscaling{detachTGErr: true, withTargetGroupARN: true},
+				log:             logging.New(),
+			},
+			args: args{
+				instance: &extensionsv1beta1.Ingress{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        "foobar",
+						Namespace:   "default",
+						Annotations: map[string]string{IngressAnnotationTargetType: controllercfn.TargetTypeIP},
+						Finalizers:  []string{FinalizerCFNStack},
+					},
+					Spec: extensionsv1beta1.IngressSpec{}},
+			},
+			want:    nil,
+			want1:   nil,
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -1096,6 +1341,7 @@ func TestReconcileIngress_delete(t *testing.T) {
 				autoscalingSvc: tt.fields.austoscalingSvc,
 				log:            tt.fields.log,
 			}
+			r.provisioner = &cloudFormationProvisioner{r}
 			got, got1, err := r.delete(tt.args.instance)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("ReconcileIngress.delete() error = %v, wantErr %v", err, tt.wantErr)
@@ -1111,3 +1357,1268 @@ func TestReconcileIngress_delete(t *testing.T) {
 		})
 	}
 }
+
+func TestReconcileIngress_syncIPTargets(t *testing.T) {
+	tests := []struct {
+		name             string
+		client           client.Client
+		elbv2Svc         *mockELBV2
+		tls              bool
+		wantRegistered   []string
+		wantDeregistered []string
+		wantErr          bool
+	}{
+		{
+			name:           "registers ready proxy pods",
+			client:         fakeclient.NewFakeClient(newMockEndpoints("foobar", "10.0.0.1", "10.0.0.2")),
+			elbv2Svc:       &mockELBV2{},
+			wantRegistered: []string{"10.0.0.1", "10.0.0.2"},
+		},
+		{
+			name:             "deregisters proxy pods that are gone",
+			client:           fakeclient.NewFakeClient(newMockEndpoints("foobar", "10.0.0.1")),
+			elbv2Svc:         &mockELBV2{Targets: []string{"10.0.0.1", "10.0.0.3"}},
+			wantDeregistered: []string{"10.0.0.3"},
+		},
+		{
+			name:             "deregisters everything without endpoints",
+			client:           fakeclient.NewFakeClient(),
+			elbv2Svc:         &mockELBV2{Targets: []string{"10.0.0.1"}},
+			wantDeregistered: []string{"10.0.0.1"},
+		},
+		{
+			name:             "registers proxy pods again on the proxy port",
+			client:           fakeclient.NewFakeClient(newMockEndpoints("foobar", "10.0.0.1")),
+			elbv2Svc:         &mockELBV2{Targets: []string{"10.0.0.1"}, TargetPort: 9090},
+			wantRegistered:   []string{"10.0.0.1"},
+			wantDeregistered: []string{"10.0.0.1"},
+		},
+		{
+			name:           "registers ready proxy pods with the tls target group",
+			client:         fakeclient.NewFakeClient(newMockEndpoints("foobar", "10.0.0.1")),
+			elbv2Svc:       &mockELBV2{},
+			tls:            true,
+			wantRegistered: []string{"10.0.0.1", "10.0.0.1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &ReconcileIngress{
+				Client: tt.client,
+				cfnSvc: &mockCloudformation{
+					Stacks: map[string]*cloudformation.Stack{
+						"foobar": &cloudformation.Stack{
+							StackStatus: aws.String(cloudformation.StackStatusCreateComplete),
+						},
+					},
+					TLSTargetGroup: tt.tls,
+				},
+				elbv2Svc: tt.elbv2Svc,
+				log:      logging.New(),
+			}
+			r.provisioner = &cloudFormationProvisioner{r}
+			instance := newMockIngress("foobar", false, true)
+			instance.Annotations[IngressAnnotationTargetType] = "ip"
+
+			err := r.syncIPTargets(instance)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ReconcileIngress.syncIPTargets() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(tt.elbv2Svc.Registered, tt.wantRegistered) {
+				t.Errorf("ReconcileIngress.syncIPTargets() registered = %v, want %v", tt.elbv2Svc.Registered, tt.wantRegistered)
+			}
+			if !reflect.DeepEqual(tt.elbv2Svc.Deregistered, tt.wantDeregistered) {
+				t.Errorf("ReconcileIngress.syncIPTargets() deregistered = %v, want %v", tt.elbv2Svc.Deregistered, tt.wantDeregistered)
+			}
+		})
+	}
+}
+
+func TestReconcileIngress_syncTLSSecrets(t *testing.T) {
+	secret := func(name string, secretType corev1.SecretType, cert string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Type:       secretType,
+			Data:       map[string][]byte{corev1.TLSCertKey: []byte(cert), corev1.TLSPrivateKeyKey: []byte("key")},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		secret   *corev1.Secret
+		checksum string
+		wantErr  bool
+	}{
+		{
+			name:   "rolls out the proxy when the secret changed",
+			secret: secret("foo-tls", corev1.SecretTypeTLS, "new"),
+		},
+		{
+			name:    "rejects secrets that are not of type kubernetes.io/tls",
+			secret:  secret("foo-tls", corev1.SecretTypeOpaque, "new"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := newMockIngress("foobar", false, true)
+			instance.Spec.TLS = []extensionsv1beta1.IngressTLS{{Hosts: []string{"foo.example.com"}, SecretName: "foo-tls"}}
+
+			r := &ReconcileIngress{
+				scheme:   scheme.Scheme,
+				recorder: record.NewFakeRecorder(10),
+				log:      logging.New(),
+			}
+			deploy := r.buildReverseProxyResources(instance, staticUpstreams(instance))[1].(*appsv1.Deployment)
+			setTLSChecksum(deploy, "old")
+			r.Client = fakeclient.NewFakeClient(tt.secret, deploy)
+
+			err := r.syncTLSSecrets(instance)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReconcileIngress.syncTLSSecrets() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			want, _ := r.getTLSChecksum(instance)
+			got := &appsv1.Deployment{}
+			if err := r.Get(context.TODO(), types.NamespacedName{Name: deploy.Name, Namespace: deploy.Namespace}, got); err != nil {
+				t.Fatal(err)
+			}
+			if checksum := got.Spec.Template.Annotations[ProxyAnnotationTLSChecksum]; checksum != want {
+				t.Errorf("ReconcileIngress.syncTLSSecrets() checksum = %v, want %v", checksum, want)
+			}
+			if len(got.Spec.Template.Spec.Volumes) != 2 || got.Spec.Template.Spec.Volumes[1].Secret.SecretName != "foo-tls" {
+				t.Errorf("ReconcileIngress.syncTLSSecrets() volumes = %v, want the config and the tls secret", got.Spec.Template.Spec.Volumes)
+			}
+		})
+	}
+}
+
+func TestReconcileIngress_resolveBackends(t *testing.T) {
+	service := func(name string, ports ...corev1.ServicePort) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       corev1.ServiceSpec{Ports: ports},
+		}
+	}
+
+	tests := []struct {
+		name          string
+		servicePort   intstr.IntOrString
+		objects       []runtime.Object
+		wantUpstreams backendUpstreams
+		wantEvents    int
+	}{
+		{
+			name:          "resolves a named port",
+			servicePort:   intstr.FromString("http"),
+			objects:       []runtime.Object{service("foo", corev1.ServicePort{Name: "http", Port: 8080})},
+			wantUpstreams: backendUpstreams{"foo:http": "foo:8080"},
+		},
+		{
+			name:          "keeps a port number of the service",
+			servicePort:   intstr.FromInt(8080),
+			objects:       []runtime.Object{service("foo", corev1.ServicePort{Name: "http", Port: 8080})},
+			wantUpstreams: backendUpstreams{"foo:8080": "foo:8080"},
+		},
+		{
+			name:          "reports a missing port",
+			servicePort:   intstr.FromString("grpc"),
+			objects:       []runtime.Object{service("foo", corev1.ServicePort{Name: "http", Port: 8080})},
+			wantUpstreams: backendUpstreams{},
+			wantEvents:    1,
+		},
+		{
+			name:          "reports a missing service",
+			servicePort:   intstr.FromString("http"),
+			wantUpstreams: backendUpstreams{},
+			wantEvents:    1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			r := &ReconcileIngress{
+				Client:   fakeclient.NewFakeClient(tt.objects...),
+				recorder: recorder,
+				log:      logging.New(),
+			}
+			instance := newMockIngress("foobar", false, true)
+			instance.Spec.Rules[0].HTTP.Paths[0].Backend.ServicePort = tt.servicePort
+
+			got, err := r.resolveBackends(instance)
+			if err != nil {
+				t.Fatalf("ReconcileIngress.resolveBackends() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.wantUpstreams) {
+				t.Errorf("ReconcileIngress.resolveBackends() = %v, want %v", got, tt.wantUpstreams)
+			}
+			if len(recorder.Events) != tt.wantEvents {
+				t.Errorf("ReconcileIngress.resolveBackends() recorded %d events, want %d", len(recorder.Events), tt.wantEvents)
+			}
+		})
+	}
+}
+
+func TestReconcileIngress_syncProxyConfig(t *testing.T) {
+	instance := newMockIngress("foobar", false, true)
+	instance.Spec.Rules[0].HTTP.Paths[0].Backend.ServicePort = intstr.FromString("http")
+
+	r := &ReconcileIngress{
+		scheme:   scheme.Scheme,
+		recorder: &record.FakeRecorder{},
+		log:      logging.New(),
+	}
+
+	// The proxy was rendered before the service existed
+	objects := r.buildReverseProxyResources(instance, backendUpstreams{})
+	r.Client = fakeclient.NewFakeClient(
+		objects[0].(runtime.Object),
+		objects[1].(runtime.Object),
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+			Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "http", Port: 8080}}},
+		},
+	)
+
+	if err := r.syncProxyConfig(instance); err != nil {
+		t.Fatalf("ReconcileIngress.syncProxyConfig() error = %v", err)
+	}
+
+	name := types.NamespacedName{Name: createReverseProxyResourceName(instance.Name), Namespace: instance.Namespace}
+	configMap := &corev1.ConfigMap{}
+	if err := r.Get(context.TODO(), name, configMap); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(configMap.Data["nginx.conf"], "proxy_pass         http://foo:8080;") {
+		t.Errorf("ReconcileIngress.syncProxyConfig() nginx.conf = %s, want the resolved port", configMap.Data["nginx.conf"])
+	}
+
+	deploy := &appsv1.Deployment{}
+	if err := r.Get(context.TODO(), name, deploy); err != nil {
+		t.Fatal(err)
+	}
+	if deploy.Spec.Template.Annotations[ProxyAnnotationConfigChecksum] == "" {
+		t.Errorf("ReconcileIngress.syncProxyConfig() did not roll out the proxy")
+	}
+}
+
+func TestReconcileIngress_proxyService(t *testing.T) {
+	current := func() *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: createReverseProxyResourceName("foobar"), Namespace: "default"},
+			Spec: corev1.ServiceSpec{
+				Type: corev1.ServiceTypeNodePort,
+				Ports: []corev1.ServicePort{
+					{Name: "http", Port: 80, NodePort: 30080},
+					{Name: "healthz", Port: int32(DefaultNginxHealthzPort), NodePort: 30081},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		tls       []extensionsv1beta1.IngressTLS
+		wantPorts []string
+	}{
+		{
+			name:      "keeps the service as it is",
+			wantPorts: []string{"http", "healthz"},
+		},
+		{
+			name:      "adds the tls port in place",
+			tls:       []extensionsv1beta1.IngressTLS{{Hosts: []string{"foo.example.com"}, SecretName: "foo-tls"}},
+			wantPorts: []string{"http", "healthz", "https"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &ReconcileIngress{
+				Client:   fakeclient.NewFakeClient(current()),
+				recorder: &record.FakeRecorder{},
+				log:      logging.New(),
+			}
+			instance := newMockIngress("foobar", false, true)
+			instance.Spec.TLS = tt.tls
+
+			got, err := r.proxyService(instance)
+			if err != nil {
+				t.Fatalf("ReconcileIngress.proxyService() error = %v", err)
+			}
+
+			stored := &corev1.Service{}
+			if err := r.Get(context.TODO(), types.NamespacedName{Name: got.Name, Namespace: got.Namespace}, stored); err != nil {
+				t.Fatal(err)
+			}
+			ports := []string{}
+			for _, port := range stored.Spec.Ports {
+				ports = append(ports, port.Name)
+			}
+			if !reflect.DeepEqual(ports, tt.wantPorts) {
+				t.Errorf("ReconcileIngress.proxyService() ports = %v, want %v", ports, tt.wantPorts)
+			}
+			if nodePort, healthzPort := getTargetPorts(instance, got); nodePort != 30080 || healthzPort != 30081 {
+				t.Errorf("ReconcileIngress.proxyService() node ports = %d, %d, want 30080, 30081", nodePort, healthzPort)
+			}
+		})
+	}
+}
+
+func TestKeepNodePorts(t *testing.T) {
+	current := &corev1.Service{
+		Spec: corev1.ServiceSpec{
+			Type:  corev1.ServiceTypeNodePort,
+			Ports: []corev1.ServicePort{{Name: "http", NodePort: 30080}, {Name: "healthz", NodePort: 30081}},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		service *corev1.Service
+		current *corev1.Service
+		want    []int32
+	}{
+		{
+			name:    "copies the node ports by name",
+			service: &corev1.Service{Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeNodePort, Ports: []corev1.ServicePort{{Name: "healthz"}, {Name: "http"}, {Name: "https"}}}},
+			current: current,
+			want:    []int32{30081, 30080, 0},
+		},
+		{
+			name:    "new service",
+			service: &corev1.Service{Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeNodePort, Ports: []corev1.ServicePort{{Name: "http"}}}},
+			want:    []int32{0},
+		},
+		{
+			name:    "cluster ip service for ip targets",
+			service: &corev1.Service{Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeClusterIP, Ports: []corev1.ServicePort{{Name: "http"}}}},
+			current: current,
+			want:    []int32{0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keepNodePorts(tt.service, tt.current)
+
+			got := []int32{}
+			for _, port := range tt.service.Spec.Ports {
+				got = append(got, port.NodePort)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("keepNodePorts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconcileIngress_applyChangeSet(t *testing.T) {
+	templateBody := []byte("template")
+	changeSetName := controllercfn.ChangeSetName(templateBody)
+	changeSet := func(status string, reason string, replacements ...string) *cloudformation.DescribeChangeSetOutput {
+		out := &cloudformation.DescribeChangeSetOutput{
+			ChangeSetName:   aws.String(changeSetName),
+			Status:          aws.String(status),
+			StatusReason:    aws.String(reason),
+			ExecutionStatus: aws.String(cloudformation.ExecutionStatusAvailable),
+		}
+		for _, replacement := range replacements {
+			out.Changes = append(out.Changes, &cloudformation.Change{
+				Type: aws.String(cloudformation.ChangeTypeResource),
+				ResourceChange: &cloudformation.ResourceChange{
+					LogicalResourceId: aws.String("LoadBalancer"),
+					ResourceType:      aws.String("AWS::ElasticLoadBalancingV2::LoadBalancer"),
+					Action:            aws.String(cloudformation.ChangeActionModify),
+					Replacement:       aws.String(replacement),
+				},
+			})
+		}
+		return out
+	}
+
+	tests := []struct {
+		name               string
+		annotations        map[string]string
+		changeSets         map[string]*cloudformation.DescribeChangeSetOutput
+		want               updateStatus
+		wantErr            bool
+		wantExecuted       []string
+		wantChangeSets     int
+		wantPlannedChanges string
+		wantEvents         int
+		wantReplaceDrift   bool
+	}{
+		{
+			name: "creates the change set and deletes stale ones",
+			changeSets: map[string]*cloudformation.DescribeChangeSetOutput{
+				"nlb-ingress-00000000": changeSet(cloudformation.ChangeSetStatusCreateComplete, ""),
+			},
+			want:           updatePending,
+			wantChangeSets: 1,
+		},
+		{
+			name: "waits for the change set to be created",
+			changeSets: map[string]*cloudformation.DescribeChangeSetOutput{
+				changeSetName: changeSet(cloudformation.ChangeSetStatusCreateInProgress, ""),
+			},
+			want:           updatePending,
+			wantChangeSets: 1,
+		},
+		{
+			name:        "executes changes without replacements",
+			annotations: map[string]string{IngressAnnotationRequireApproval: "true"},
+			changeSets: map[string]*cloudformation.DescribeChangeSetOutput{
+				changeSetName: changeSet(cloudformation.ChangeSetStatusCreateComplete, "", cloudformation.ReplacementFalse),
+			},
+			want:               updateApplied,
+			wantExecuted:       []string{changeSetName},
+			wantPlannedChanges: `[{"logicalId":"LoadBalancer","resourceType":"AWS::ElasticLoadBalancingV2::LoadBalancer","action":"Modify","replacement":false}]`,
+		},
+		{
+			name: "executes replacements without require-approval",
+			changeSets: map[string]*cloudformation.DescribeChangeSetOutput{
+				changeSetName: changeSet(cloudformation.ChangeSetStatusCreateComplete, "", cloudformation.ReplacementTrue),
+			},
+			want:               updateApplied,
+			wantExecuted:       []string{changeSetName},
+			wantPlannedChanges: `[{"logicalId":"LoadBalancer","resourceType":"AWS::ElasticLoadBalancingV2::LoadBalancer","action":"Modify","replacement":true}]`,
+		},
+		{
+			name:        "waits for approval of replacements",
+			annotations: map[string]string{IngressAnnotationRequireApproval: "true", IngressAnnotationApprovedChangeSet: "nlb-ingress-00000000"},
+			changeSets: map[string]*cloudformation.DescribeChangeSetOutput{
+				changeSetName: changeSet(cloudformation.ChangeSetStatusCreateComplete, "", cloudformation.ReplacementConditional),
+			},
+			want:               updateWaiting,
+			wantChangeSets:     1,
+			wantPlannedChanges: `[{"logicalId":"LoadBalancer","resourceType":"AWS::ElasticLoadBalancingV2::LoadBalancer","action":"Modify","replacement":true}]`,
+		},
+		{
+			name:        "executes approved replacements",
+			annotations: map[string]string{IngressAnnotationRequireApproval: "true", IngressAnnotationApprovedChangeSet: changeSetName},
+			changeSets: map[string]*cloudformation.DescribeChangeSetOutput{
+				changeSetName: changeSet(cloudformation.ChangeSetStatusCreateComplete, "", cloudformation.ReplacementTrue),
+			},
+			want:               updateApplied,
+			wantExecuted:       []string{changeSetName},
+			wantPlannedChanges: `[{"logicalId":"LoadBalancer","resourceType":"AWS::ElasticLoadBalancingV2::LoadBalancer","action":"Modify","replacement":true}]`,
+		},
+		{
+			name: "deletes empty change sets",
+			changeSets: map[string]*cloudformation.DescribeChangeSetOutput{
+				changeSetName: changeSet(cloudformation.ChangeSetStatusFailed, "The submitted information didn't contain changes. Submit different information to create a change set."),
+			},
+			want: updateApplied,
+		},
+		{
+			name:        "clears remediated drift",
+			annotations: map[string]string{IngressAnnotationDriftPolicy: DriftPolicyRemediate, IngressAnnotationDriftStatus: cloudformation.StackDriftStatusDrifted},
+			changeSets: map[string]*cloudformation.DescribeChangeSetOutput{
+				changeSetName: changeSet(cloudformation.ChangeSetStatusCreateComplete, "", cloudformation.ReplacementFalse),
+			},
+			want:               updateApplied,
+			wantExecuted:       []string{changeSetName},
+			wantPlannedChanges: `[{"logicalId":"LoadBalancer","resourceType":"AWS::ElasticLoadBalancingV2::LoadBalancer","action":"Modify","replacement":false}]`,
+			wantEvents:         0,
+		},
+		{
+			name:        "replaces the stack for drift the update can not remediate",
+			annotations: map[string]string{IngressAnnotationDriftPolicy: DriftPolicyRemediate, IngressAnnotationDriftStatus: cloudformation.StackDriftStatusDrifted},
+			changeSets: map[string]*cloudformation.DescribeChangeSetOutput{
+				changeSetName: changeSet(cloudformation.ChangeSetStatusFailed, "No updates are to be performed."),
+			},
+			want:             updateApplied,
+			wantEvents:       1,
+			wantReplaceDrift: true,
+		},
+		{
+			name: "fails on failed change sets",
+			changeSets: map[string]*cloudformation.DescribeChangeSetOutput{
+				changeSetName: changeSet(cloudformation.ChangeSetStatusFailed, "Template format error"),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfnSvc := &mockCloudformation{ChangeSets: tt.changeSets}
+			recorder := record.NewFakeRecorder(10)
+			r := &ReconcileIngress{
+				cfnSvc:   cfnSvc,
+				recorder: recorder,
+				log:      logging.New(),
+			}
+			instance := newMockIngress("foobar", false, true)
+			for k, v := range tt.annotations {
+				instance.Annotations[k] = v
+			}
+
+			got, err := r.applyChangeSet(instance, templateBody)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ReconcileIngress.applyChangeSet() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ReconcileIngress.applyChangeSet() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(cfnSvc.Executed, tt.wantExecuted) {
+				t.Errorf("ReconcileIngress.applyChangeSet() executed = %v, want %v", cfnSvc.Executed, tt.wantExecuted)
+			}
+			if len(cfnSvc.ChangeSets) != tt.wantChangeSets {
+				t.Errorf("ReconcileIngress.applyChangeSet() left %d change sets, want %d", len(cfnSvc.ChangeSets), tt.wantChangeSets)
+			}
+			if instance.Annotations[IngressAnnotationPlannedChanges] != tt.wantPlannedChanges {
+				t.Errorf("ReconcileIngress.applyChangeSet() planned changes = %s, want %s", instance.Annotations[IngressAnnotationPlannedChanges], tt.wantPlannedChanges)
+			}
+			if tt.wantExecuted != nil && instance.Annotations[IngressAnnotationApprovedChangeSet] != "" {
+				t.Errorf("ReconcileIngress.applyChangeSet() kept the approval after executing")
+			}
+			if shouldRemediateDrift(instance) {
+				t.Errorf("ReconcileIngress.applyChangeSet() kept the drift status after remediating")
+			}
+			if shouldReplaceDrift(instance) != tt.wantReplaceDrift {
+				t.Errorf("shouldReplaceDrift() = %v, want %v", shouldReplaceDrift(instance), tt.wantReplaceDrift)
+			}
+			if len(recorder.Events) != tt.wantEvents {
+				t.Errorf("ReconcileIngress.applyChangeSet() recorded %d events, want %d", len(recorder.Events), tt.wantEvents)
+			}
+		})
+	}
+}
+
+func TestReconcileIngress_recover(t *testing.T) {
+	recent := time.Now().UTC().Format(time.RFC3339)
+	old := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	older := time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339)
+
+	tests := []struct {
+		name          string
+		status        string
+		annotations   map[string]string
+		want          reconcile.Result
+		wantErr       bool
+		wantDeleted   bool
+		wantContinued []string
+		wantAttempts  string
+		wantEvents    int
+	}{
+		{
+			name:         "deletes stacks that rolled back after create",
+			status:       cloudformation.StackStatusRollbackComplete,
+			want:         reconcile.Result{RequeueAfter: 20 * time.Second},
+			wantDeleted:  true,
+			wantAttempts: "1",
+		},
+		{
+			name:          "continues failed update rollbacks",
+			status:        cloudformation.StackStatusUpdateRollbackFailed,
+			want:          reconcile.Result{RequeueAfter: 20 * time.Second},
+			wantContinued: []string{"foobar"},
+			wantAttempts:  "1",
+		},
+		{
+			name:         "retries failed deletes",
+			status:       cloudformation.StackStatusDeleteFailed,
+			annotations:  map[string]string{IngressAnnotationRecoveryAttempts: "2", IngressAnnotationRecoveryLastAttempt: old},
+			want:         reconcile.Result{RequeueAfter: 20 * time.Second},
+			wantDeleted:  true,
+			wantAttempts: "3",
+		},
+		{
+			name:         "backs off between attempts",
+			status:       cloudformation.StackStatusRollbackComplete,
+			annotations:  map[string]string{IngressAnnotationRecoveryAttempts: "1", IngressAnnotationRecoveryLastAttempt: recent},
+			wantAttempts: "1",
+		},
+		{
+			name:         "gives up after the maximum retries",
+			status:       cloudformation.StackStatusRollbackComplete,
+			annotations:  map[string]string{IngressAnnotationRecoveryMaxRetries: "2", IngressAnnotationRecoveryAttempts: "2", IngressAnnotationRecoveryLastAttempt: old},
+			want:         reconcile.Result{},
+			wantAttempts: "2",
+		},
+		{
+			name:         "keeps retrying failed deletes at the maximum backoff",
+			status:       cloudformation.StackStatusDeleteFailed,
+			annotations:  map[string]string{IngressAnnotationRecoveryMaxRetries: "2", IngressAnnotationRecoveryAttempts: "2", IngressAnnotationRecoveryLastAttempt: older},
+			want:         reconcile.Result{RequeueAfter: 20 * time.Second},
+			wantDeleted:  true,
+			wantAttempts: "3",
+			wantEvents:   1,
+		},
+		{
+			name:        "does nothing when recovery is disabled",
+			status:      cloudformation.StackStatusRollbackComplete,
+			annotations: map[string]string{IngressAnnotationRecoveryPolicy: RecoveryPolicyNone},
+			want:        reconcile.Result{},
+		},
+		{
+			name:        "rejects unknown policies",
+			status:      cloudformation.StackStatusRollbackComplete,
+			annotations: map[string]string{IngressAnnotationRecoveryPolicy: "sometimes"},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stack := &cloudformation.Stack{StackStatus: aws.String(tt.status)}
+			cfnSvc := &mockCloudformation{Stacks: map[string]*cloudformation.Stack{"foobar": stack}}
+			recorder := record.NewFakeRecorder(10)
+			r := &ReconcileIngress{
+				cfnSvc:   cfnSvc,
+				recorder: recorder,
+				log:      logging.New(),
+			}
+			r.provisioner = &cloudFormationProvisioner{r}
+			instance := newMockIngress("foobar", false, true)
+			for k, v := range tt.annotations {
+				instance.Annotations[k] = v
+			}
+
+			got, err := r.recover(instance, stack)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ReconcileIngress.recover() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.name == "backs off between attempts" {
+				if got.RequeueAfter <= 0 || got.RequeueAfter > DefaultRecoveryBackoffSeconds*time.Second {
+					t.Errorf("ReconcileIngress.recover() = %v, want a requeue within the backoff", got)
+				}
+			} else if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReconcileIngress.recover() = %v, want %v", got, tt.want)
+			}
+			if _, ok := cfnSvc.Stacks["foobar"]; ok == tt.wantDeleted {
+				t.Errorf("ReconcileIngress.recover() deleted stack = %v, want %v", !ok, tt.wantDeleted)
+			}
+			if !reflect.DeepEqual(cfnSvc.Continued, tt.wantContinued) {
+				t.Errorf("ReconcileIngress.recover() continued = %v, want %v", cfnSvc.Continued, tt.wantContinued)
+			}
+			if instance.Annotations[IngressAnnotationRecoveryAttempts] != tt.wantAttempts {
+				t.Errorf("ReconcileIngress.recover() attempts = %s, want %s", instance.Annotations[IngressAnnotationRecoveryAttempts], tt.wantAttempts)
+			}
+			if len(recorder.Events) != tt.wantEvents {
+				t.Errorf("ReconcileIngress.recover() recorded %d events, want %d", len(recorder.Events), tt.wantEvents)
+			}
+		})
+	}
+}
+
+func TestReconcileIngress_recordStackEvents(t *testing.T) {
+	event := func(id string, logicalID string, status string, reason string) *cloudformation.StackEvent {
+		return &cloudformation.StackEvent{
+			EventId:              aws.String(id),
+			LogicalResourceId:    aws.String(logicalID),
+			ResourceType:         aws.String("AWS::ElasticLoadBalancingV2::LoadBalancer"),
+			ResourceStatus:       aws.String(status),
+			ResourceStatusReason: aws.String(reason),
+		}
+	}
+	// Newest first, as returned by DescribeStackEvents
+	events := []*cloudformation.StackEvent{
+		event("4", "foobar", cloudformation.StackStatusRollbackComplete, ""),
+		event("3", "LoadBalancer", cloudformation.ResourceStatusCreateFailed, "A load balancer cannot be attached to multiple subnets in the same Availability Zone"),
+		event("2", "LoadBalancer", cloudformation.ResourceStatusCreateInProgress, ""),
+		event("1", "foobar", cloudformation.StackStatusCreateInProgress, "User Initiated"),
+	}
+
+	tests := []struct {
+		name              string
+		lastEventID       string
+		want              bool
+		wantEvents        []string
+		wantLastEvent     string
+		wantFailureReason string
+	}{
+		{
+			name:        "emits the events since the last seen event",
+			lastEventID: "2",
+			want:        true,
+			wantEvents: []string{
+				"Warning CreateFailed LoadBalancer (AWS::ElasticLoadBalancingV2::LoadBalancer) CREATE_FAILED: A load balancer cannot be attached to multiple subnets in the same Availability Zone",
+				"Normal RollbackComplete foobar (AWS::ElasticLoadBalancingV2::LoadBalancer) ROLLBACK_COMPLETE",
+			},
+			wantLastEvent:     "4",
+			wantFailureReason: "LoadBalancer: A load balancer cannot be attached to multiple subnets in the same Availability Zone",
+		},
+		{
+			name: "emits all events of a new stack",
+			want: true,
+			wantEvents: []string{
+				"Normal CreateInProgress foobar (AWS::ElasticLoadBalancingV2::LoadBalancer) CREATE_IN_PROGRESS: User Initiated",
+				"Normal CreateInProgress LoadBalancer (AWS::ElasticLoadBalancingV2::LoadBalancer) CREATE_IN_PROGRESS",
+				"Warning CreateFailed LoadBalancer (AWS::ElasticLoadBalancingV2::LoadBalancer) CREATE_FAILED: A load balancer cannot be attached to multiple subnets in the same Availability Zone",
+				"Normal RollbackComplete foobar (AWS::ElasticLoadBalancingV2::LoadBalancer) ROLLBACK_COMPLETE",
+			},
+			wantLastEvent:     "4",
+			wantFailureReason: "LoadBalancer: A load balancer cannot be attached to multiple subnets in the same Availability Zone",
+		},
+		{
+			name:          "nothing new",
+			lastEventID:   "4",
+			want:          false,
+			wantEvents:    []string{},
+			wantLastEvent: "4",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			r := &ReconcileIngress{
+				cfnSvc:   &mockCloudformation{Events: events},
+				recorder: recorder,
+				log:      logging.New(),
+			}
+			instance := newMockIngress("foobar", false, true)
+			if tt.lastEventID != "" {
+				instance.Annotations[IngressAnnotationLastStackEvent] = tt.lastEventID
+			}
+
+			if got := r.recordStackEvents(instance); got != tt.want {
+				t.Errorf("ReconcileIngress.recordStackEvents() = %v, want %v", got, tt.want)
+			}
+			close(recorder.Events)
+			got := []string{}
+			for e := range recorder.Events {
+				got = append(got, e)
+			}
+			if !reflect.DeepEqual(got, tt.wantEvents) {
+				t.Errorf("ReconcileIngress.recordStackEvents() events = %v, want %v", got, tt.wantEvents)
+			}
+			if instance.Annotations[IngressAnnotationLastStackEvent] != tt.wantLastEvent {
+				t.Errorf("ReconcileIngress.recordStackEvents() last event = %s, want %s", instance.Annotations[IngressAnnotationLastStackEvent], tt.wantLastEvent)
+			}
+			if instance.Annotations[IngressAnnotationLastFailureReason] != tt.wantFailureReason {
+				t.Errorf("ReconcileIngress.recordStackEvents() failure reason = %s, want %s", instance.Annotations[IngressAnnotationLastFailureReason], tt.wantFailureReason)
+			}
+		})
+	}
+}
+
+func TestReconcileIngress_checkDrift(t *testing.T) {
+	recent := time.Now().UTC().Format(time.RFC3339)
+	detection := func(detectionStatus string, driftStatus string) *cloudformation.DescribeStackDriftDetectionStatusOutput {
+		return &cloudformation.DescribeStackDriftDetectionStatusOutput{
+			DetectionStatus:  aws.String(detectionStatus),
+			StackDriftStatus: aws.String(driftStatus),
+		}
+	}
+	drifts := []*cloudformation.StackResourceDrift{
+		{
+			LogicalResourceId:        aws.String("SecurityGroupIngress0"),
+			ResourceType:             aws.String("AWS::EC2::SecurityGroupIngress"),
+			StackResourceDriftStatus: aws.String(cloudformation.StackResourceDriftStatusDeleted),
+		},
+		{
+			LogicalResourceId:        aws.String("LoadBalancer"),
+			ResourceType:             aws.String("AWS::ElasticLoadBalancingV2::LoadBalancer"),
+			StackResourceDriftStatus: aws.String(cloudformation.StackResourceDriftStatusModified),
+			PropertyDifferences: []*cloudformation.PropertyDifference{
+				{PropertyPath: aws.String("/LoadBalancerAttributes/0/Value")},
+			},
+		},
+	}
+
+	tests := []struct {
+		name            string
+		annotations     map[string]string
+		detection       *cloudformation.DescribeStackDriftDetectionStatusOutput
+		want            reconcile.Result
+		wantChanged     bool
+		wantErr         bool
+		wantEvents      []string
+		wantDetectionID string
+		wantDriftStatus string
+		wantRemediate   bool
+	}{
+		{
+			name: "does nothing by default",
+			want: reconcile.Result{},
+		},
+		{
+			name:            "starts drift detection",
+			annotations:     map[string]string{IngressAnnotationDriftPolicy: DriftPolicyReport},
+			want:            reconcile.Result{RequeueAfter: 10 * time.Second},
+			wantChanged:     true,
+			wantDetectionID: "detection-foobar",
+		},
+		{
+			name:            "polls running drift detection",
+			annotations:     map[string]string{IngressAnnotationDriftPolicy: DriftPolicyReport, IngressAnnotationDriftDetectionID: "detection-foobar"},
+			detection:       detection(cloudformation.StackDriftDetectionStatusDetectionInProgress, ""),
+			want:            reconcile.Result{RequeueAfter: 10 * time.Second},
+			wantDetectionID: "detection-foobar",
+		},
+		{
+			name:            "reports drifted resources",
+			annotations:     map[string]string{IngressAnnotationDriftPolicy: DriftPolicyReport, IngressAnnotationDriftDetectionID: "detection-foobar", IngressAnnotationDriftCheckInterval: "30m"},
+			detection:       detection(cloudformation.StackDriftDetectionStatusDetectionComplete, cloudformation.StackDriftStatusDrifted),
+			want:            reconcile.Result{RequeueAfter: 30 * time.Minute},
+			wantChanged:     true,
+			wantDriftStatus: cloudformation.StackDriftStatusDrifted,
+			wantEvents: []string{
+				"Warning Drifted SecurityGroupIngress0 (AWS::EC2::SecurityGroupIngress) DELETED",
+				"Warning Drifted LoadBalancer (AWS::ElasticLoadBalancingV2::LoadBalancer) MODIFIED: /LoadBalancerAttributes/0/Value",
+			},
+		},
+		{
+			name:            "remediates drifted resources",
+			annotations:     map[string]string{IngressAnnotationDriftPolicy: DriftPolicyRemediate, IngressAnnotationDriftDetectionID: "detection-foobar"},
+			detection:       detection(cloudformation.StackDriftDetectionStatusDetectionComplete, cloudformation.StackDriftStatusDrifted),
+			want:            reconcile.Result{RequeueAfter: time.Hour},
+			wantChanged:     true,
+			wantDriftStatus: cloudformation.StackDriftStatusDrifted,
+			wantEvents: []string{
+				"Warning Drifted SecurityGroupIngress0 (AWS::EC2::SecurityGroupIngress) DELETED",
+				"Warning Drifted LoadBalancer (AWS::ElasticLoadBalancingV2::LoadBalancer) MODIFIED: /LoadBalancerAttributes/0/Value",
+			},
+			wantRemediate: true,
+		},
+		{
+			name:            "stack in sync",
+			annotations:     map[string]string{IngressAnnotationDriftPolicy: DriftPolicyRemediate, IngressAnnotationDriftDetectionID: "detection-foobar"},
+			detection:       detection(cloudformation.StackDriftDetectionStatusDetectionComplete, cloudformation.StackDriftStatusInSync),
+			want:            reconcile.Result{RequeueAfter: time.Hour},
+			wantChanged:     true,
+			wantDriftStatus: cloudformation.StackDriftStatusInSync,
+		},
+		{
+			name:        "reports short intervals and skips the check",
+			annotations: map[string]string{IngressAnnotationDriftPolicy: DriftPolicyReport, IngressAnnotationDriftCheckInterval: "1m"},
+			want:        reconcile.Result{},
+			wantEvents:  []string{"Warning InvalidDriftPolicy nlb.ingress.kubernetes.io/drift-check-interval must be a duration of at least 5m0s, got \"1m\""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			r := &ReconcileIngress{
+				cfnSvc:   &mockCloudformation{Detection: tt.detection, Drifts: drifts},
+				recorder: recorder,
+				log:      logging.New(),
+			}
+			instance := newMockIngress("foobar", false, true)
+			for k, v := range tt.annotations {
+				instance.Annotations[k] = v
+			}
+
+			got, changed, err := r.checkDrift(instance)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ReconcileIngress.checkDrift() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReconcileIngress.checkDrift() = %v, want %v", got, tt.want)
+			}
+			if changed != tt.wantChanged {
+				t.Errorf("ReconcileIngress.checkDrift() changed = %v, want %v", changed, tt.wantChanged)
+			}
+			close(recorder.Events)
+			events := []string{}
+			for e := range recorder.Events {
+				events = append(events, e)
+			}
+			if len(events) > 0 || len(tt.wantEvents) > 0 {
+				if !reflect.DeepEqual(events, tt.wantEvents) {
+					t.Errorf("ReconcileIngress.checkDrift() events = %v, want %v", events, tt.wantEvents)
+				}
+			}
+			if instance.Annotations[IngressAnnotationDriftDetectionID] != tt.wantDetectionID {
+				t.Errorf("ReconcileIngress.checkDrift() detection = %s, want %s", instance.Annotations[IngressAnnotationDriftDetectionID], tt.wantDetectionID)
+			}
+			if instance.Annotations[IngressAnnotationDriftStatus] != tt.wantDriftStatus {
+				t.Errorf("ReconcileIngress.checkDrift() drift status = %s, want %s", instance.Annotations[IngressAnnotationDriftStatus], tt.wantDriftStatus)
+			}
+			if shouldRemediateDrift(instance) != tt.wantRemediate {
+				t.Errorf("shouldRemediateDrift() = %v, want %v", shouldRemediateDrift(instance), tt.wantRemediate)
+			}
+		})
+	}
+
+	// The next check waits for the interval
+	r := &ReconcileIngress{cfnSvc: &mockCloudformation{}, recorder: record.NewFakeRecorder(10), log: logging.New()}
+	instance := newMockIngress("foobar", false, true)
+	instance.Annotations[IngressAnnotationDriftPolicy] = DriftPolicyReport
+	instance.Annotations[IngressAnnotationLastDriftCheck] = recent
+	if got, changed, _ := r.checkDrift(instance); changed || got.RequeueAfter <= 0 || got.RequeueAfter > time.Hour {
+		t.Errorf("ReconcileIngress.checkDrift() = %v, %v, want to wait for the interval", got, changed)
+	}
+}
+
+func TestRender(t *testing.T) {
+	description := func() *network.Description {
+		return &network.Description{
+			VpcID:    "vpc-foobar",
+			VpcCIDRs: []string{"10.0.0.0/16"},
+			Subnets: []network.SubnetDescription{
+				{ID: "subnet-a", AvailabilityZone: "us-east-1a", CIDR: "10.0.0.0/24"},
+				{ID: "subnet-b", AvailabilityZone: "us-east-1b", CIDR: "10.0.1.0/24"},
+			},
+			SecurityGroupIDs:    []string{"sg-foobar"},
+			InstanceIDs:         []string{"i-foobar"},
+			NodePort:            30080,
+			HealthCheckNodePort: 30081,
+		}
+	}
+
+	tests := []struct {
+		name         string
+		annotations  map[string]string
+		description  func(*network.Description)
+		wantTemplate []string
+		wantErr      bool
+	}{
+		{
+			name:         "instance targets on the described node ports",
+			wantTemplate: []string{"vpc-foobar", "subnet-a", "subnet-b", "sg-foobar", "i-foobar", "Port: 30080"},
+		},
+		{
+			name:         "ip targets on the proxy ports",
+			annotations:  map[string]string{IngressAnnotationTargetType: "ip"},
+			description:  func(d *network.Description) { d.NodePort, d.HealthCheckNodePort = 0, 0 },
+			wantTemplate: []string{"TargetType: ip", "Port: 8080"},
+		},
+		{
+			name:         "subnets from the annotation",
+			annotations:  map[string]string{IngressAnnotationSubnets: "subnet-b"},
+			wantTemplate: []string{"- subnet-b"},
+		},
+		{
+			name:        "instance targets need node ports",
+			description: func(d *network.Description) { d.NodePort = 0 },
+			wantErr:     true,
+		},
+		{
+			name:        "needs the vpc",
+			description: func(d *network.Description) { d.VpcID = "" },
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := newMockIngress("foobar", false, false)
+			for k, v := range tt.annotations {
+				instance.Annotations[k] = v
+			}
+
+			d := description()
+			if tt.description != nil {
+				tt.description(d)
+			}
+
+			got, err := Render(instance, d, zap.NewNop())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Render() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			for _, want := range tt.wantTemplate {
+				if !strings.Contains(string(got.Template), want) {
+					t.Errorf("Render() template does not contain %q\n%s", want, got.Template)
+				}
+			}
+			if got.NginxConfig != buildNginxConfig(instance, staticUpstreams(instance)) {
+				t.Errorf("Render() nginx config = %v, want %v", got.NginxConfig, buildNginxConfig(instance, staticUpstreams(instance)))
+			}
+			if len(got.Objects) != 3 {
+				t.Errorf("Render() objects = %v, want the ConfigMap, Deployment and Service", got.Objects)
+			}
+		})
+	}
+}
+
+func TestDirectProvisioner(t *testing.T) {
+	tests := []struct {
+		name          string
+		annotations   map[string]string
+		owner         string
+		nodePorts     []int
+		delete        bool
+		wantErr       bool
+		wantPorts     []int64
+		wantListeners []int64
+		wantRules     []securityGroupRule
+	}{
+		{
+			name:          "creates the load balancer, target group and listener",
+			nodePorts:     []int{30080},
+			wantPorts:     []int64{30080},
+			wantListeners: []int64{80},
+			wantRules: []securityGroupRule{
+				{GroupID: "sg-foobar", CIDR: "10.0.0.0/16", Port: 30080},
+			},
+		},
+		{
+			name:          "adds a tls listener for the certificates",
+			annotations:   map[string]string{IngressAnnotationCertificateARN: "arn:cert/a,arn:cert/b"},
+			nodePorts:     []int{30080},
+			wantPorts:     []int64{30080},
+			wantListeners: []int64{80, 443},
+			wantRules: []securityGroupRule{
+				{GroupID: "sg-foobar", CIDR: "10.0.0.0/16", Port: 30080},
+			},
+		},
+		{
+			name:          "moves to a new target group when the node port changes",
+			nodePorts:     []int{30080, 30090},
+			wantPorts:     []int64{30090},
+			wantListeners: []int64{80},
+			wantRules: []securityGroupRule{
+				{GroupID: "sg-foobar", CIDR: "10.0.0.0/16", Port: 30090},
+			},
+		},
+		{
+			name:          "source ranges for traffic and the vpc range for health checks",
+			annotations:   map[string]string{IngressAnnotationSourceRanges: "192.168.0.0/24", IngressAnnotationHealthCheckPort: "30081"},
+			nodePorts:     []int{30080},
+			wantPorts:     []int64{30080},
+			wantListeners: []int64{80},
+			wantRules: []securityGroupRule{
+				{GroupID: "sg-foobar", CIDR: "192.168.0.0/24", Port: 30080},
+				{GroupID: "sg-foobar", CIDR: "10.0.0.0/16", Port: 30081},
+			},
+		},
+		{
+			name:      "delete removes the load balancer, then target groups and rules on the next pass",
+			nodePorts: []int{30080, 30090},
+			delete:    true,
+		},
+		{
+			name:      "load balancer of another ingress",
+			owner:     "default/other",
+			nodePorts: []int{30080},
+			wantErr:   true,
+		},
+		{
+			name:        "features that need cloudformation",
+			annotations: map[string]string{IngressAnnotationCloudWatchAlarms: "true"},
+			nodePorts:   []int{30080},
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			elbv2Svc, ec2Svc := newMockDirectELBV2(), &mockDirectEC2{Rules: map[securityGroupRule]string{}}
+			p := &directProvisioner{elbv2Svc: elbv2Svc, ec2Svc: ec2Svc, log: logging.New()}
+
+			instance := newMockIngress("foobar", false, true)
+			for key, value := range tt.annotations {
+				instance.Annotations[key] = value
+			}
+
+			if tt.owner != "" {
+				elbv2Svc.CreateLoadBalancer(&elbv2.CreateLoadBalancerInput{
+					Name: aws.String(directLoadBalancerName(instance)),
+					Tags: []*elbv2.Tag{{Key: aws.String(directTagIngress), Value: aws.String(tt.owner)}},
+				})
+			}
+
+			var err error
+			for i, nodePort := range tt.nodePorts {
+				cfg, cfgErr := buildTemplateConfig(instance)
+				if cfgErr != nil {
+					t.Fatalf("buildTemplateConfig() error = %v", cfgErr)
+				}
+				cfg.Network = &network.Network{
+					Vpc:              &ec2.Vpc{VpcId: aws.String("vpc-foobar"), CidrBlock: aws.String("10.0.0.0/16")},
+					SubnetIDs:        []string{"subnet-a", "subnet-b"},
+					SecurityGroupIDs: []string{"sg-foobar"},
+				}
+				cfg.NodePort, cfg.HealthCheckNodePort = nodePort, 30081
+
+				if i == 0 {
+					err = p.create(instance, cfg)
+				} else {
+					_, err = p.update(instance, cfg)
+				}
+				if err != nil {
+					break
+				}
+			}
+			if err == nil && tt.delete {
+				instance.ObjectMeta.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+				if err = p.delete(instance); err == nil {
+					if len(elbv2Svc.LoadBalancers) != 0 || len(elbv2Svc.TargetGroups) == 0 {
+						t.Errorf("first delete left load balancers %v and target groups %v, want the target groups only", elbv2Svc.LoadBalancers, elbv2Svc.TargetGroups)
+					}
+					if stack, _ := p.describe(instance); stack == nil || !controllercfn.IsDeleting(aws.StringValue(stack.StackStatus)) {
+						t.Errorf("directProvisioner.describe() = %v, want a delete in progress until the target groups are gone", stack)
+					}
+
+					err = p.delete(instance)
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("directProvisioner error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			ports := []int64{}
+			for _, targetGroup := range elbv2Svc.TargetGroups {
+				ports = append(ports, aws.Int64Value(targetGroup.Port))
+			}
+			if len(ports) != len(tt.wantPorts) || (len(ports) > 0 && ports[0] != tt.wantPorts[0]) {
+				t.Errorf("target group ports = %v, want %v", ports, tt.wantPorts)
+			}
+
+			listeners := map[int64]bool{}
+			for _, listener := range elbv2Svc.Listeners {
+				listeners[aws.Int64Value(listener.Port)] = true
+				if arn := aws.StringValue(listener.DefaultActions[0].TargetGroupArn); elbv2Svc.Tags[arn][directTagIngress] != "default/foobar" {
+					t.Errorf("listener %d forwards to %s, want a target group of the ingress", aws.Int64Value(listener.Port), arn)
+				}
+			}
+			if len(listeners) != len(tt.wantListeners) {
+				t.Errorf("listeners = %v, want %v", listeners, tt.wantListeners)
+			}
+			for _, port := range tt.wantListeners {
+				if !listeners[port] {
+					t.Errorf("listeners = %v, want %v", listeners, tt.wantListeners)
+				}
+			}
+
+			if len(ec2Svc.Rules) != len(tt.wantRules) {
+				t.Errorf("security group rules = %v, want %v", ec2Svc.Rules, tt.wantRules)
+			}
+			for _, rule := range tt.wantRules {
+				if _, ok := ec2Svc.Rules[rule]; !ok {
+					t.Errorf("security group rules = %v, want %v", ec2Svc.Rules, tt.wantRules)
+				}
+			}
+
+			stack, err := p.describe(instance)
+			if err != nil {
+				t.Fatalf("directProvisioner.describe() error = %v", err)
+			}
+			if tt.delete {
+				if stack != nil {
+					t.Errorf("directProvisioner.describe() = %v, want nil after delete", stack)
+				}
+				return
+			}
+
+			outputs := controllercfn.StackOutputMap(stack)
+			if aws.StringValue(stack.StackStatus) != cloudformation.StackStatusCreateComplete || outputs[controllercfn.OutputKeyNLBEndpoint] == "" {
+				t.Errorf("directProvisioner.describe() = %v, want a complete stack with the load balancer DNS name", stack)
+			}
+			if outputs[controllercfn.OutputKeyListeners] == "" {
+				t.Errorf("directProvisioner.describe() outputs = %v, want the applied config", outputs)
+			}
+		})
+	}
+}
+
+func TestExportProvisioner(t *testing.T) {
+	tests := []struct {
+		name  string
+		dir   bool
+		stack *cloudformation.Stack
+		want  string
+	}{
+		{
+			name: "configmap waits for the pipeline",
+		},
+		{
+			name: "directory waits for the pipeline",
+			dir:  true,
+		},
+		{
+			name:  "stack created by the pipeline",
+			stack: &cloudformation.Stack{StackName: aws.String("foobar"), StackStatus: aws.String(cloudformation.StackStatusCreateComplete)},
+			want:  cloudformation.StackStatusCreateComplete,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfnSvc := &mockCloudformation{Stacks: map[string]*cloudformation.Stack{}}
+			r := &ReconcileIngress{Client: fakeclient.NewFakeClient(), scheme: scheme.Scheme, cfnSvc: cfnSvc, log: logging.New()}
+			p := &exportProvisioner{r: r}
+			if tt.dir {
+				p.dir = t.TempDir()
+			}
+
+			exportedTemplate := func() string {
+				if tt.dir {
+					b, _ := ioutil.ReadFile(filepath.Join(p.dir, "foobar", ExportTemplateKey))
+					return string(b)
+				}
+
+				configMap := &corev1.ConfigMap{}
+				if err := r.Get(context.TODO(), types.NamespacedName{Name: "foobar-nlb-stack", Namespace: "default"}, configMap); err != nil {
+					return ""
+				}
+				if !strings.Contains(configMap.Data[ExportParametersKey], `"stackName": "foobar"`) {
+					t.Errorf("exported parameters = %s, want the stack name", configMap.Data[ExportParametersKey])
+				}
+				return configMap.Data[ExportTemplateKey]
+			}
+
+			instance := newMockIngress("foobar", false, true)
+			instance.UID = "foobar-uid"
+			cfg, err := buildTemplateConfig(instance)
+			if err != nil {
+				t.Fatalf("buildTemplateConfig() error = %v", err)
+			}
+			cfg.Network = &network.Network{
+				Vpc:              &ec2.Vpc{VpcId: aws.String("vpc-foobar"), CidrBlock: aws.String("10.0.0.0/16")},
+				SubnetIDs:        []string{"subnet-a", "subnet-b"},
+				SecurityGroupIDs: []string{"sg-foobar"},
+			}
+			cfg.NodePort, cfg.HealthCheckNodePort = 30080, 30081
+
+			if err := p.create(instance, cfg); err != nil {
+				t.Fatalf("exportProvisioner.create() error = %v", err)
+			}
+			if len(cfnSvc.Stacks) != 0 {
+				t.Errorf("exportProvisioner.create() created stacks %v, want none", cfnSvc.Stacks)
+			}
+			if template := exportedTemplate(); !strings.Contains(template, "Port: 30080") {
+				t.Errorf("exported template = %s, want the target group on port 30080", template)
+			}
+
+			if tt.stack != nil {
+				cfnSvc.Stacks["foobar"] = tt.stack
+			}
+			stack, err := p.describe(instance)
+			if err != nil {
+				t.Fatalf("exportProvisioner.describe() error = %v", err)
+			}
+			got := ""
+			if stack != nil {
+				got = aws.StringValue(stack.StackStatus)
+			}
+			if got != tt.want {
+				t.Errorf("exportProvisioner.describe() status = %q, want %q", got, tt.want)
+			}
+
+			// Until the stack exists every reconcile exports the template again, with the edits since
+			if stack == nil {
+				cfg.NodePort = 30090
+				if err := p.create(instance, cfg); err != nil {
+					t.Fatalf("exportProvisioner.create() error = %v", err)
+				}
+				if template := exportedTemplate(); !strings.Contains(template, "Port: 30090") {
+					t.Errorf("exported template = %s, want the target group on port 30090", template)
+				}
+			}
+
+			if err := p.delete(instance); err != nil {
+				t.Fatalf("exportProvisioner.delete() error = %v", err)
+			}
+			if exportedTemplate() != "" {
+				t.Errorf("exportProvisioner.delete() left the exported template")
+			}
+			if tt.stack != nil && len(cfnSvc.Stacks) != 1 {
+				t.Errorf("exportProvisioner.delete() deleted the stack, want it left to the pipeline")
+			}
+		})
+	}
+}
@@ -12,6 +12,8 @@ import (
 	"github.com/aws/aws-sdk-go/service/cloudformation/cloudformationiface"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
 	"github.com/danushkaf/aws-nlb-ingress-controller/pkg/finalizers"
 	corev1 "k8s.io/api/core/v1"
 	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
@@ -21,7 +23,15 @@ import (
 
 type mockCloudformation struct {
 	cloudformationiface.CloudFormationAPI
-	Stacks map[string]*cloudformation.Stack
+	Stacks     map[string]*cloudformation.Stack
+	ChangeSets map[string]*cloudformation.DescribeChangeSetOutput
+	Executed   []string
+	Continued  []string
+	Events     []*cloudformation.StackEvent
+	Detection  *cloudformation.DescribeStackDriftDetectionStatusOutput
+	Drifts     []*cloudformation.StackResourceDrift
+	// TLSTargetGroup adds the target group of spec.tls to the stack resources
+	TLSTargetGroup bool
 }
 
 func (m *mockCloudformation) CreateStack(in *cloudformation.CreateStackInput) (*cloudformation.CreateStackOutput, error) {
@@ -32,11 +42,53 @@ func (m *mockCloudformation) CreateStack(in *cloudformation.CreateStackInput) (*
 	return &cloudformation.CreateStackOutput{}, nil
 }
 
-func (m *mockCloudformation) UpdateStack(in *cloudformation.UpdateStackInput) (*cloudformation.UpdateStackOutput, error) {
+func (m *mockCloudformation) CreateChangeSet(in *cloudformation.CreateChangeSetInput) (*cloudformation.CreateChangeSetOutput, error) {
 	if *in.StackName == "brokenStackUpdate" {
-		return nil, fmt.Errorf("mockCloudformation.UpdateStack failed")
+		return nil, fmt.Errorf("mockCloudformation.CreateChangeSet failed")
 	}
-	return &cloudformation.UpdateStackOutput{}, nil
+
+	if m.ChangeSets == nil {
+		m.ChangeSets = map[string]*cloudformation.DescribeChangeSetOutput{}
+	}
+
+	m.ChangeSets[*in.ChangeSetName] = &cloudformation.DescribeChangeSetOutput{
+		ChangeSetName: in.ChangeSetName,
+		StackName:     in.StackName,
+		Status:        aws.String(cloudformation.ChangeSetStatusCreatePending),
+	}
+	return &cloudformation.CreateChangeSetOutput{}, nil
+}
+
+func (m *mockCloudformation) DescribeChangeSet(in *cloudformation.DescribeChangeSetInput) (*cloudformation.DescribeChangeSetOutput, error) {
+	if changeSet, ok := m.ChangeSets[*in.ChangeSetName]; ok {
+		return changeSet, nil
+	}
+
+	return nil, awserr.New(cloudformation.ErrCodeChangeSetNotFoundException, fmt.Sprintf("ChangeSet [%s] does not exist", *in.ChangeSetName), fmt.Errorf(""))
+}
+
+func (m *mockCloudformation) ListChangeSets(in *cloudformation.ListChangeSetsInput) (*cloudformation.ListChangeSetsOutput, error) {
+	summaries := []*cloudformation.ChangeSetSummary{}
+	for name := range m.ChangeSets {
+		summaries = append(summaries, &cloudformation.ChangeSetSummary{ChangeSetName: aws.String(name), StackName: in.StackName})
+	}
+
+	return &cloudformation.ListChangeSetsOutput{Summaries: summaries}, nil
+}
+
+func (m *mockCloudformation) DeleteChangeSet(in *cloudformation.DeleteChangeSetInput) (*cloudformation.DeleteChangeSetOutput, error) {
+	delete(m.ChangeSets, *in.ChangeSetName)
+	return &cloudformation.DeleteChangeSetOutput{}, nil
+}
+
+func (m *mockCloudformation) ExecuteChangeSet(in *cloudformation.ExecuteChangeSetInput) (*cloudformation.ExecuteChangeSetOutput, error) {
+	if _, ok := m.ChangeSets[*in.ChangeSetName]; !ok {
+		return nil, awserr.New(cloudformation.ErrCodeChangeSetNotFoundException, fmt.Sprintf("ChangeSet [%s] does not exist", *in.ChangeSetName), fmt.Errorf(""))
+	}
+
+	m.Executed = append(m.Executed, *in.ChangeSetName)
+	delete(m.ChangeSets, *in.ChangeSetName)
+	return &cloudformation.ExecuteChangeSetOutput{}, nil
 }
 
 func (m *mockCloudformation) DescribeStacks(in *cloudformation.DescribeStacksInput) (*cloudformation.DescribeStacksOutput, error) {
@@ -66,17 +118,80 @@ func (m *mockCloudformation) DeleteStack(in *cloudformation.DeleteStackInput) (*
 	return nil, awserr.New("ValidationError", fmt.Sprintf("Stack with id %s does not exist", *in.StackName), fmt.Errorf(""))
 }
 
+func (m *mockCloudformation) ContinueUpdateRollback(in *cloudformation.ContinueUpdateRollbackInput) (*cloudformation.ContinueUpdateRollbackOutput, error) {
+	m.Continued = append(m.Continued, *in.StackName)
+	return &cloudformation.ContinueUpdateRollbackOutput{}, nil
+}
+
+func (m *mockCloudformation) DescribeStackResources(in *cloudformation.DescribeStackResourcesInput) (*cloudformation.DescribeStackResourcesOutput, error) {
+	return &cloudformation.DescribeStackResourcesOutput{
+		StackResources: []*cloudformation.StackResource{
+			{
+				LogicalResourceId:    aws.String("LoadBalancer"),
+				ResourceStatus:       aws.String(cloudformation.ResourceStatusDeleteComplete),
+				ResourceStatusReason: aws.String(""),
+			},
+			{
+				LogicalResourceId:    aws.String("TargetGroup"),
+				ResourceStatus:       aws.String(cloudformation.ResourceStatusDeleteFailed),
+				ResourceStatusReason: aws.String("Target group is currently in use by a listener or a rule"),
+			},
+		},
+	}, nil
+}
+
+func (m *mockCloudformation) DescribeStackEventsPages(in *cloudformation.DescribeStackEventsInput, fn func(*cloudformation.DescribeStackEventsOutput, bool) bool) error {
+	// Two events per page, newest first
+	for i := 0; i < len(m.Events); i += 2 {
+		end := i + 2
+		if end > len(m.Events) {
+			end = len(m.Events)
+		}
+
+		if !fn(&cloudformation.DescribeStackEventsOutput{StackEvents: m.Events[i:end]}, end == len(m.Events)) {
+			break
+		}
+	}
+
+	return nil
+}
+
+func (m *mockCloudformation) DetectStackDrift(in *cloudformation.DetectStackDriftInput) (*cloudformation.DetectStackDriftOutput, error) {
+	return &cloudformation.DetectStackDriftOutput{StackDriftDetectionId: aws.String("detection-" + *in.StackName)}, nil
+}
+
+func (m *mockCloudformation) DescribeStackDriftDetectionStatus(in *cloudformation.DescribeStackDriftDetectionStatusInput) (*cloudformation.DescribeStackDriftDetectionStatusOutput, error) {
+	if m.Detection == nil {
+		return nil, awserr.New("ValidationError", fmt.Sprintf("Drift detection %s does not exist", *in.StackDriftDetectionId), fmt.Errorf(""))
+	}
+
+	return m.Detection, nil
+}
+
+func (m *mockCloudformation) DescribeStackResourceDriftsPages(in *cloudformation.DescribeStackResourceDriftsInput, fn func(*cloudformation.DescribeStackResourceDriftsOutput, bool) bool) error {
+	fn(&cloudformation.DescribeStackResourceDriftsOutput{StackResourceDrifts: m.Drifts}, true)
+	return nil
+}
+
 func (m *mockCloudformation) ListStackResources(in *cloudformation.ListStackResourcesInput) (*cloudformation.ListStackResourcesOutput, error) {
 
 	if _, ok := m.Stacks[*in.StackName]; ok {
-		return &cloudformation.ListStackResourcesOutput{
+		out := &cloudformation.ListStackResourcesOutput{
 			StackResourceSummaries: []*cloudformation.StackResourceSummary{
 				{
 					LogicalResourceId:  aws.String("TargetGroup"),
 					PhysicalResourceId: aws.String("tgroupARN"),
 				},
 			},
-		}, nil
+		}
+		if m.TLSTargetGroup {
+			out.StackResourceSummaries = append(out.StackResourceSummaries, &cloudformation.StackResourceSummary{
+				LogicalResourceId:  aws.String("TLSTargetGroup"),
+				PhysicalResourceId: aws.String("tlsTgroupARN"),
+			})
+		}
+
+		return out, nil
 	}
 
 	return nil, awserr.New("ValidationError", fmt.Sprintf("Cannot get targetgroup in %s stack", *in.StackName), fmt.Errorf(""))
@@ -98,6 +213,38 @@ func (m *mockEC2) DescribeVpcs(in *ec2.DescribeVpcsInput) (*ec2.DescribeVpcsOutp
 	}, nil
 }
 
+func (m *mockEC2) DescribeSubnets(in *ec2.DescribeSubnetsInput) (*ec2.DescribeSubnetsOutput, error) {
+	if len(in.SubnetIds) > 0 {
+		subnets := []*ec2.Subnet{}
+		for _, subnetID := range in.SubnetIds {
+			subnets = append(subnets, &ec2.Subnet{
+				SubnetId:                subnetID,
+				VpcId:                   aws.String("vpc-foobar"),
+				AvailabilityZone:        aws.String("us-west-2b"),
+				AvailableIpAddressCount: aws.Int64(100),
+			})
+		}
+
+		return &ec2.DescribeSubnetsOutput{Subnets: subnets}, nil
+	}
+
+	return &ec2.DescribeSubnetsOutput{
+		Subnets: []*ec2.Subnet{
+			&ec2.Subnet{
+				SubnetId:         aws.String("sub-public"),
+				VpcId:            aws.String("vpc-foobar"),
+				AvailabilityZone: aws.String("us-west-2b"),
+				Tags: []*ec2.Tag{
+					{
+						Key:   aws.String("kubernetes.io/role/elb"),
+						Value: aws.String("1"),
+					},
+				},
+			},
+		},
+	}, nil
+}
+
 func (m *mockEC2) DescribeInstances(in *ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error) {
 	if m.getASGTag {
 		return &ec2.DescribeInstancesOutput{
@@ -144,6 +291,45 @@ func (m *mockEC2) DescribeInstances(in *ec2.DescribeInstancesInput) (*ec2.Descri
 	}, nil
 }
 
+type mockELBV2 struct {
+	elbv2iface.ELBV2API
+	Targets []string
+	// TargetPort is the port of the Targets, 8080 when it is zero
+	TargetPort   int64
+	Registered   []string
+	Deregistered []string
+}
+
+func (m *mockELBV2) DescribeTargetHealth(in *elbv2.DescribeTargetHealthInput) (*elbv2.DescribeTargetHealthOutput, error) {
+	port := m.TargetPort
+	if port == 0 {
+		port = 8080
+	}
+
+	descriptions := []*elbv2.TargetHealthDescription{}
+	for _, target := range m.Targets {
+		descriptions = append(descriptions, &elbv2.TargetHealthDescription{
+			Target: &elbv2.TargetDescription{Id: aws.String(target), Port: aws.Int64(port)},
+		})
+	}
+
+	return &elbv2.DescribeTargetHealthOutput{TargetHealthDescriptions: descriptions}, nil
+}
+
+func (m *mockELBV2) RegisterTargets(in *elbv2.RegisterTargetsInput) (*elbv2.RegisterTargetsOutput, error) {
+	for _, target := range in.Targets {
+		m.Registered = append(m.Registered, *target.Id)
+	}
+	return &elbv2.RegisterTargetsOutput{}, nil
+}
+
+func (m *mockELBV2) DeregisterTargets(in *elbv2.DeregisterTargetsInput) (*elbv2.DeregisterTargetsOutput, error) {
+	for _, target := range in.Targets {
+		m.Deregistered = append(m.Deregistered, *target.Id)
+	}
+	return &elbv2.DeregisterTargetsOutput{}, nil
+}
+
 type mockAutoscaling struct {
 	autoscalingiface.AutoScalingAPI
 	withTargetGroupARN bool
@@ -241,7 +427,19 @@ func newMockIngress(name string, isDeleted, hasFinalizer bool) *extensionsv1beta
 func newMockService(name string) *corev1.Service {
 	return &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{Name: createReverseProxyResourceName(name), Namespace: "default"},
-		Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{NodePort: 30123}}},
+		Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeNodePort, Ports: []corev1.ServicePort{{Name: "http", NodePort: 30123}}},
+	}
+}
+
+func newMockEndpoints(name string, ips ...string) *corev1.Endpoints {
+	addresses := []corev1.EndpointAddress{}
+	for _, ip := range ips {
+		addresses = append(addresses, corev1.EndpointAddress{IP: ip})
+	}
+
+	return &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: createReverseProxyResourceName(name), Namespace: "default"},
+		Subsets:    []corev1.EndpointSubset{{Addresses: addresses}},
 	}
 }
 
@@ -256,3 +454,295 @@ func newMockNodeList() *corev1.NodeList {
 		},
 	}
 }
+
+// mockDirectELBV2 keeps the load balancers, target groups, listeners and tags the direct provisioner creates
+type mockDirectELBV2 struct {
+	elbv2iface.ELBV2API
+	LoadBalancers map[string]*elbv2.LoadBalancer
+	TargetGroups  map[string]*elbv2.TargetGroup
+	Listeners     map[string]*elbv2.Listener
+	Certificates  map[string][]string
+	Tags          map[string]map[string]string
+}
+
+func newMockDirectELBV2() *mockDirectELBV2 {
+	return &mockDirectELBV2{
+		LoadBalancers: map[string]*elbv2.LoadBalancer{},
+		TargetGroups:  map[string]*elbv2.TargetGroup{},
+		Listeners:     map[string]*elbv2.Listener{},
+		Certificates:  map[string][]string{},
+		Tags:          map[string]map[string]string{},
+	}
+}
+
+func (m *mockDirectELBV2) DescribeLoadBalancers(in *elbv2.DescribeLoadBalancersInput) (*elbv2.DescribeLoadBalancersOutput, error) {
+	out := &elbv2.DescribeLoadBalancersOutput{}
+	for _, name := range in.Names {
+		if lb, ok := m.LoadBalancers[*name]; ok {
+			out.LoadBalancers = append(out.LoadBalancers, lb)
+		}
+	}
+
+	if len(out.LoadBalancers) == 0 {
+		return nil, awserr.New(elbv2.ErrCodeLoadBalancerNotFoundException, "One or more load balancers not found", nil)
+	}
+
+	return out, nil
+}
+
+func (m *mockDirectELBV2) CreateLoadBalancer(in *elbv2.CreateLoadBalancerInput) (*elbv2.CreateLoadBalancerOutput, error) {
+	lb := &elbv2.LoadBalancer{
+		LoadBalancerArn:  aws.String("arn:lb/" + *in.Name),
+		LoadBalancerName: in.Name,
+		DNSName:          aws.String(*in.Name + ".elb.amazonaws.com"),
+		Scheme:           in.Scheme,
+		IpAddressType:    in.IpAddressType,
+		State:            &elbv2.LoadBalancerState{Code: aws.String(elbv2.LoadBalancerStateEnumActive)},
+	}
+	for _, subnet := range in.Subnets {
+		lb.AvailabilityZones = append(lb.AvailabilityZones, &elbv2.AvailabilityZone{SubnetId: subnet})
+	}
+
+	m.LoadBalancers[*in.Name] = lb
+	m.AddTags(&elbv2.AddTagsInput{ResourceArns: []*string{lb.LoadBalancerArn}, Tags: in.Tags})
+	return &elbv2.CreateLoadBalancerOutput{LoadBalancers: []*elbv2.LoadBalancer{lb}}, nil
+}
+
+func (m *mockDirectELBV2) DeleteLoadBalancer(in *elbv2.DeleteLoadBalancerInput) (*elbv2.DeleteLoadBalancerOutput, error) {
+	for name, lb := range m.LoadBalancers {
+		if *lb.LoadBalancerArn == *in.LoadBalancerArn {
+			delete(m.LoadBalancers, name)
+		}
+	}
+
+	for arn, listener := range m.Listeners {
+		if *listener.LoadBalancerArn == *in.LoadBalancerArn {
+			delete(m.Listeners, arn)
+		}
+	}
+
+	return &elbv2.DeleteLoadBalancerOutput{}, nil
+}
+
+func (m *mockDirectELBV2) SetIpAddressType(in *elbv2.SetIpAddressTypeInput) (*elbv2.SetIpAddressTypeOutput, error) {
+	return &elbv2.SetIpAddressTypeOutput{IpAddressType: in.IpAddressType}, nil
+}
+
+func (m *mockDirectELBV2) SetSubnets(in *elbv2.SetSubnetsInput) (*elbv2.SetSubnetsOutput, error) {
+	return &elbv2.SetSubnetsOutput{}, nil
+}
+
+func (m *mockDirectELBV2) ModifyLoadBalancerAttributes(in *elbv2.ModifyLoadBalancerAttributesInput) (*elbv2.ModifyLoadBalancerAttributesOutput, error) {
+	return &elbv2.ModifyLoadBalancerAttributesOutput{Attributes: in.Attributes}, nil
+}
+
+func (m *mockDirectELBV2) DescribeTargetGroups(in *elbv2.DescribeTargetGroupsInput) (*elbv2.DescribeTargetGroupsOutput, error) {
+	out := &elbv2.DescribeTargetGroupsOutput{}
+	for _, name := range in.Names {
+		if targetGroup, ok := m.TargetGroups[*name]; ok {
+			out.TargetGroups = append(out.TargetGroups, targetGroup)
+		}
+	}
+
+	if len(out.TargetGroups) == 0 {
+		return nil, awserr.New(elbv2.ErrCodeTargetGroupNotFoundException, "One or more target groups not found", nil)
+	}
+
+	return out, nil
+}
+
+func (m *mockDirectELBV2) DescribeTargetGroupsPages(in *elbv2.DescribeTargetGroupsInput, fn func(*elbv2.DescribeTargetGroupsOutput, bool) bool) error {
+	out := &elbv2.DescribeTargetGroupsOutput{}
+	for _, targetGroup := range m.TargetGroups {
+		out.TargetGroups = append(out.TargetGroups, targetGroup)
+	}
+
+	fn(out, true)
+	return nil
+}
+
+func (m *mockDirectELBV2) CreateTargetGroup(in *elbv2.CreateTargetGroupInput) (*elbv2.CreateTargetGroupOutput, error) {
+	targetGroup := &elbv2.TargetGroup{
+		TargetGroupArn:  aws.String("arn:tg/" + *in.Name),
+		TargetGroupName: in.Name,
+		Port:            in.Port,
+		TargetType:      in.TargetType,
+	}
+
+	m.TargetGroups[*in.Name] = targetGroup
+	return &elbv2.CreateTargetGroupOutput{TargetGroups: []*elbv2.TargetGroup{targetGroup}}, nil
+}
+
+func (m *mockDirectELBV2) DeleteTargetGroup(in *elbv2.DeleteTargetGroupInput) (*elbv2.DeleteTargetGroupOutput, error) {
+	for _, listener := range m.Listeners {
+		if aws.StringValue(listener.DefaultActions[0].TargetGroupArn) == *in.TargetGroupArn {
+			return nil, awserr.New(elbv2.ErrCodeResourceInUseException, "target group is in use by a listener", nil)
+		}
+	}
+
+	for name, targetGroup := range m.TargetGroups {
+		if *targetGroup.TargetGroupArn == *in.TargetGroupArn {
+			delete(m.TargetGroups, name)
+		}
+	}
+
+	return &elbv2.DeleteTargetGroupOutput{}, nil
+}
+
+func (m *mockDirectELBV2) ModifyTargetGroup(in *elbv2.ModifyTargetGroupInput) (*elbv2.ModifyTargetGroupOutput, error) {
+	return &elbv2.ModifyTargetGroupOutput{}, nil
+}
+
+func (m *mockDirectELBV2) ModifyTargetGroupAttributes(in *elbv2.ModifyTargetGroupAttributesInput) (*elbv2.ModifyTargetGroupAttributesOutput, error) {
+	return &elbv2.ModifyTargetGroupAttributesOutput{Attributes: in.Attributes}, nil
+}
+
+func (m *mockDirectELBV2) DescribeTargetHealth(in *elbv2.DescribeTargetHealthInput) (*elbv2.DescribeTargetHealthOutput, error) {
+	return &elbv2.DescribeTargetHealthOutput{}, nil
+}
+
+func (m *mockDirectELBV2) RegisterTargets(in *elbv2.RegisterTargetsInput) (*elbv2.RegisterTargetsOutput, error) {
+	return &elbv2.RegisterTargetsOutput{}, nil
+}
+
+func (m *mockDirectELBV2) DescribeListeners(in *elbv2.DescribeListenersInput) (*elbv2.DescribeListenersOutput, error) {
+	out := &elbv2.DescribeListenersOutput{}
+	for _, listener := range m.Listeners {
+		if *listener.LoadBalancerArn == *in.LoadBalancerArn {
+			out.Listeners = append(out.Listeners, listener)
+		}
+	}
+
+	return out, nil
+}
+
+func (m *mockDirectELBV2) CreateListener(in *elbv2.CreateListenerInput) (*elbv2.CreateListenerOutput, error) {
+	listener := &elbv2.Listener{
+		ListenerArn:     aws.String(fmt.Sprintf("%s/listener/%d", *in.LoadBalancerArn, *in.Port)),
+		LoadBalancerArn: in.LoadBalancerArn,
+		Protocol:        in.Protocol,
+		Port:            in.Port,
+		Certificates:    in.Certificates,
+		DefaultActions:  in.DefaultActions,
+	}
+
+	m.Listeners[*listener.ListenerArn] = listener
+	return &elbv2.CreateListenerOutput{Listeners: []*elbv2.Listener{listener}}, nil
+}
+
+func (m *mockDirectELBV2) ModifyListener(in *elbv2.ModifyListenerInput) (*elbv2.ModifyListenerOutput, error) {
+	listener := m.Listeners[*in.ListenerArn]
+	listener.Certificates = in.Certificates
+	listener.DefaultActions = in.DefaultActions
+	return &elbv2.ModifyListenerOutput{Listeners: []*elbv2.Listener{listener}}, nil
+}
+
+func (m *mockDirectELBV2) DeleteListener(in *elbv2.DeleteListenerInput) (*elbv2.DeleteListenerOutput, error) {
+	delete(m.Listeners, *in.ListenerArn)
+	return &elbv2.DeleteListenerOutput{}, nil
+}
+
+func (m *mockDirectELBV2) DescribeListenerCertificates(in *elbv2.DescribeListenerCertificatesInput) (*elbv2.DescribeListenerCertificatesOutput, error) {
+	out := &elbv2.DescribeListenerCertificatesOutput{}
+	for _, certificateARN := range m.Certificates[*in.ListenerArn] {
+		out.Certificates = append(out.Certificates, &elbv2.Certificate{CertificateArn: aws.String(certificateARN), IsDefault: aws.Bool(false)})
+	}
+
+	return out, nil
+}
+
+func (m *mockDirectELBV2) AddListenerCertificates(in *elbv2.AddListenerCertificatesInput) (*elbv2.AddListenerCertificatesOutput, error) {
+	for _, certificate := range in.Certificates {
+		m.Certificates[*in.ListenerArn] = append(m.Certificates[*in.ListenerArn], *certificate.CertificateArn)
+	}
+
+	return &elbv2.AddListenerCertificatesOutput{}, nil
+}
+
+func (m *mockDirectELBV2) RemoveListenerCertificates(in *elbv2.RemoveListenerCertificatesInput) (*elbv2.RemoveListenerCertificatesOutput, error) {
+	remove := map[string]bool{}
+	for _, certificate := range in.Certificates {
+		remove[*certificate.CertificateArn] = true
+	}
+
+	certificateARNs := []string{}
+	for _, certificateARN := range m.Certificates[*in.ListenerArn] {
+		if !remove[certificateARN] {
+			certificateARNs = append(certificateARNs, certificateARN)
+		}
+	}
+
+	m.Certificates[*in.ListenerArn] = certificateARNs
+	return &elbv2.RemoveListenerCertificatesOutput{}, nil
+}
+
+func (m *mockDirectELBV2) DescribeTags(in *elbv2.DescribeTagsInput) (*elbv2.DescribeTagsOutput, error) {
+	out := &elbv2.DescribeTagsOutput{}
+	for _, arn := range in.ResourceArns {
+		description := &elbv2.TagDescription{ResourceArn: arn}
+		for key, value := range m.Tags[*arn] {
+			description.Tags = append(description.Tags, &elbv2.Tag{Key: aws.String(key), Value: aws.String(value)})
+		}
+		out.TagDescriptions = append(out.TagDescriptions, description)
+	}
+
+	return out, nil
+}
+
+func (m *mockDirectELBV2) AddTags(in *elbv2.AddTagsInput) (*elbv2.AddTagsOutput, error) {
+	for _, arn := range in.ResourceArns {
+		if m.Tags[*arn] == nil {
+			m.Tags[*arn] = map[string]string{}
+		}
+		for _, tag := range in.Tags {
+			m.Tags[*arn][*tag.Key] = *tag.Value
+		}
+	}
+
+	return &elbv2.AddTagsOutput{}, nil
+}
+
+// mockDirectEC2 keeps the security group rules, by group, CIDR and port, with their descriptions
+type mockDirectEC2 struct {
+	ec2iface.EC2API
+	Rules map[securityGroupRule]string
+}
+
+func (m *mockDirectEC2) DescribeSecurityGroups(in *ec2.DescribeSecurityGroupsInput) (*ec2.DescribeSecurityGroupsOutput, error) {
+	out := &ec2.DescribeSecurityGroupsOutput{}
+	for _, groupID := range in.GroupIds {
+		group := &ec2.SecurityGroup{GroupId: groupID}
+		for rule, description := range m.Rules {
+			if rule.GroupID != *groupID {
+				continue
+			}
+
+			permission := &ec2.IpPermission{IpProtocol: aws.String("tcp"), FromPort: aws.Int64(rule.Port), ToPort: aws.Int64(rule.Port)}
+			permission.IpRanges = []*ec2.IpRange{{CidrIp: aws.String(rule.CIDR), Description: aws.String(description)}}
+			group.IpPermissions = append(group.IpPermissions, permission)
+		}
+		out.SecurityGroups = append(out.SecurityGroups, group)
+	}
+
+	return out, nil
+}
+
+func (m *mockDirectEC2) AuthorizeSecurityGroupIngress(in *ec2.AuthorizeSecurityGroupIngressInput) (*ec2.AuthorizeSecurityGroupIngressOutput, error) {
+	for _, permission := range in.IpPermissions {
+		for _, ipRange := range permission.IpRanges {
+			m.Rules[securityGroupRule{GroupID: *in.GroupId, CIDR: *ipRange.CidrIp, Port: *permission.FromPort}] = aws.StringValue(ipRange.Description)
+		}
+	}
+
+	return &ec2.AuthorizeSecurityGroupIngressOutput{}, nil
+}
+
+func (m *mockDirectEC2) RevokeSecurityGroupIngress(in *ec2.RevokeSecurityGroupIngressInput) (*ec2.RevokeSecurityGroupIngressOutput, error) {
+	for _, permission := range in.IpPermissions {
+		for _, ipRange := range permission.IpRanges {
+			delete(m.Rules, securityGroupRule{GroupID: *in.GroupId, CIDR: *ipRange.CidrIp, Port: *permission.FromPort})
+		}
+	}
+
+	return &ec2.RevokeSecurityGroupIngressOutput{}, nil
+}
@@ -2,8 +2,12 @@ package ingress
 
 import (
 	"bytes"
+	"fmt"
 	"html/template"
+	"path"
+	"sort"
 
+	corev1 "k8s.io/api/core/v1"
 	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
@@ -15,13 +19,31 @@ events { worker_connections 1024; }
 
 http {
     sendfile on;
-
+    server_names_hash_bucket_size 128;
+{{ range .Servers }}
     server {
-      listen {{ .Port }};
-{{ range .Ingress.Spec.Rules -}}
-{{ range .IngressRuleValue.HTTP.Paths }}
+{{- if .Default }}
+      listen {{ $.Port }} default_server;
+{{- if .TLS }}
+      listen {{ $.TLSPort }} ssl default_server;
+{{- end }}
+      server_name _;
+{{- else }}
+      listen {{ $.Port }};
+{{- if .TLS }}
+      listen {{ $.TLSPort }} ssl;
+{{- end }}
+      server_name {{ .Host }};
+{{- end }}
+{{- if .TLS }}
+
+      ssl_certificate     {{ .TLS.Certificate }};
+      ssl_certificate_key {{ .TLS.Key }};
+{{- end }}
+{{ range .Locations }}
       location {{ .Path }} {
-        proxy_pass         http://{{ .Backend.ServiceName }}:{{ IntValue .Backend.ServicePort }};
+{{- if .Upstream }}
+        proxy_pass         http://{{ .Upstream }};
         proxy_redirect     off;
         proxy_set_header   Host $host;
         proxy_set_header   X-Real-IP $remote_addr;
@@ -29,30 +51,258 @@ http {
         proxy_set_header   X-Forwarded-Host $server_name;
 				proxy_http_version 1.1;
 				proxy_set_header Connection "";
+{{- else if .Unavailable }}
+        return 503;
+{{- else }}
+        return 404;
+{{- end }}
       }
 {{ end }}
-{{- end }}
+    }
+{{ end }}
+    server {
+      listen {{ .HealthzPort }};
+
+      location = {{ .HealthzPath }} {
+        access_log off;
+        return 200 "ok\n";
+      }
     }
 }
 `
 
-func buildNginxConfig(instance *extensionsv1beta1.Ingress) string {
-	t, err := template.New("").Funcs(template.FuncMap{
-		"IntValue": func(d intstr.IntOrString) int {
-			return d.IntValue()
-		},
-	}).Parse(nginxConfigTemplate)
+// nginxTLSPath is where the secrets of the tls section of an ingress are mounted in the proxy, in a directory per secret
+const nginxTLSPath = "/etc/nginx-tls"
+
+// nginxServer is a server block of the proxy, for a host of the rules or the default server for the rules without a host
+type nginxServer struct {
+	Host    string
+	Default bool
+	// TLS is the certificate the server terminates TLS with, nil when it only serves plain HTTP
+	TLS       *nginxCertificate
+	Locations []nginxLocation
+}
+
+// nginxCertificate is the certificate and key of a kubernetes.io/tls secret mounted in the proxy
+type nginxCertificate struct {
+	Certificate string
+	Key         string
+}
+
+func secretCertificate(secretName string) *nginxCertificate {
+	return &nginxCertificate{
+		Certificate: path.Join(nginxTLSPath, secretName, corev1.TLSCertKey),
+		Key:         path.Join(nginxTLSPath, secretName, corev1.TLSPrivateKeyKey),
+	}
+}
+
+// getTLSSecretNames returns the secrets of the tls section of the ingress, sorted and without duplicates
+func getTLSSecretNames(instance *extensionsv1beta1.Ingress) []string {
+	secretNames := map[string]bool{}
+	for _, tls := range instance.Spec.TLS {
+		secretNames[tls.SecretName] = true
+	}
+
+	names := getListFromMap(secretNames)
+	sort.Strings(names)
+	return names
+}
+
+// getServerCertificates returns the certificate of each host of the tls section, the first entry for a host wins.
+// The default server, under the empty host, takes the entry without hosts or else the first entry, nginx needs
+// a certificate to answer clients with other or no server names.
+func getServerCertificates(instance *extensionsv1beta1.Ingress) map[string]*nginxCertificate {
+	certificates := map[string]*nginxCertificate{}
+	for _, tls := range instance.Spec.TLS {
+		if len(tls.Hosts) == 0 {
+			if _, ok := certificates[""]; !ok {
+				certificates[""] = secretCertificate(tls.SecretName)
+			}
+		}
+
+		for _, host := range tls.Hosts {
+			if _, ok := certificates[host]; !ok {
+				certificates[host] = secretCertificate(tls.SecretName)
+			}
+		}
+	}
+
+	if _, ok := certificates[""]; !ok && len(instance.Spec.TLS) > 0 {
+		certificates[""] = secretCertificate(instance.Spec.TLS[0].SecretName)
+	}
+
+	return certificates
+}
+
+// nginxLocation proxies a path to the host:port of a service, requests are answered with 404 when it has none
+// and with 503 when its service or port does not exist
+type nginxLocation struct {
+	Path        string
+	Upstream    string
+	Unavailable bool
+}
+
+// backendUpstreams are the host:port upstreams of the backends of an ingress by backendKey, with the service ports
+// resolved to numbers. Backends that are missing have no upstream.
+type backendUpstreams map[string]string
+
+func backendKey(backend *extensionsv1beta1.IngressBackend) string {
+	return fmt.Sprintf("%s:%s", backend.ServiceName, backend.ServicePort.String())
+}
+
+// staticUpstreams are the upstreams of the backends with a port number, used without cluster access.
+// Named ports need the service to be resolved.
+func staticUpstreams(instance *extensionsv1beta1.Ingress) backendUpstreams {
+	upstreams := backendUpstreams{}
+	for _, backend := range getBackends(instance) {
+		if backend.ServicePort.Type == intstr.Int {
+			upstreams[backendKey(&backend)] = fmt.Sprintf("%s:%d", backend.ServiceName, backend.ServicePort.IntValue())
+		}
+	}
+
+	return upstreams
+}
+
+// getCustomErrorBackend returns the custom error service of the ingress as a backend, nil when it is not set or invalid
+func getCustomErrorBackend(instance *extensionsv1beta1.Ingress) *extensionsv1beta1.IngressBackend {
+	address, err := getCustomErrorService(instance)
+	if err != nil || address == "" {
+		return nil
+	}
+
+	name, port, _ := parseServiceAddress(address)
+	return &extensionsv1beta1.IngressBackend{ServiceName: name, ServicePort: intstr.FromInt(port)}
+}
+
+// getBackends returns the backends of the paths of all rules, the default backend and the custom error service
+func getBackends(instance *extensionsv1beta1.Ingress) []extensionsv1beta1.IngressBackend {
+	backends := []extensionsv1beta1.IngressBackend{}
+	for _, rule := range instance.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+
+		for _, path := range rule.HTTP.Paths {
+			backends = append(backends, path.Backend)
+		}
+	}
+
+	if instance.Spec.Backend != nil {
+		backends = append(backends, *instance.Spec.Backend)
+	}
+
+	if backend := getCustomErrorBackend(instance); backend != nil {
+		backends = append(backends, *backend)
+	}
+
+	return backends
+}
+
+func (u backendUpstreams) location(backend *extensionsv1beta1.IngressBackend) nginxLocation {
+	upstream, ok := u[backendKey(backend)]
+	return nginxLocation{Upstream: upstream, Unavailable: !ok}
+}
+
+// getFallbackLocation returns the location for the requests no path of a server matches: the default backend of the ingress,
+// its custom error service or the default backend of the controller, in that order. Requests get a 404 when none is set.
+func getFallbackLocation(instance *extensionsv1beta1.Ingress, upstreams backendUpstreams) nginxLocation {
+	if instance.Spec.Backend != nil {
+		return upstreams.location(instance.Spec.Backend)
+	}
+
+	if backend := getCustomErrorBackend(instance); backend != nil {
+		return upstreams.location(backend)
+	}
+
+	upstream, _ := ControllerOptions.defaultBackend()
+	return nginxLocation{Upstream: upstream}
+}
+
+// buildNginxServers groups the paths of the rules by host, with the default server first and the hosts in order.
+// Wildcard hosts are passed to nginx as they are, it prefers exact hosts over wildcards.
+// Locations are sorted by path so the config does not change between reconciles. Every server without a root path
+// sends the requests no path matches to the fallback location. Hosts of the tls section get a server even without rules,
+// so they are answered with their own certificate.
+func buildNginxServers(instance *extensionsv1beta1.Ingress, upstreams backendUpstreams) []nginxServer {
+	locations := map[string]map[string]nginxLocation{"": {}}
+	certificates := getServerCertificates(instance)
+	for host := range certificates {
+		locations[host] = map[string]nginxLocation{}
+	}
+
+	for _, rule := range instance.Spec.Rules {
+		if locations[rule.Host] == nil {
+			locations[rule.Host] = map[string]nginxLocation{}
+		}
+
+		if rule.HTTP == nil {
+			continue
+		}
+
+		for _, path := range rule.HTTP.Paths {
+			if path.Path == "" {
+				path.Path = "/"
+			}
+
+			// The first rule for a path wins, like for any other ingress controller
+			if _, ok := locations[rule.Host][path.Path]; !ok {
+				locations[rule.Host][path.Path] = upstreams.location(&path.Backend)
+			}
+		}
+	}
+
+	fallback := getFallbackLocation(instance, upstreams)
+
+	hosts := []string{}
+	for host := range locations {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	servers := []nginxServer{}
+	for _, host := range hosts {
+		server := nginxServer{Host: host, Default: host == "", TLS: certificates[host]}
+		// Without a fallback, requests for other paths would get the nginx welcome page
+		if _, ok := locations[host]["/"]; !ok {
+			locations[host]["/"] = fallback
+		}
+
+		paths := []string{}
+		for path := range locations[host] {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+
+		for _, path := range paths {
+			location := locations[host][path]
+			location.Path = path
+			server.Locations = append(server.Locations, location)
+		}
+		servers = append(servers, server)
+	}
+
+	return servers
+}
+
+func buildNginxConfig(instance *extensionsv1beta1.Ingress, upstreams backendUpstreams) string {
+	t, err := template.New("").Parse(nginxConfigTemplate)
 	if err != nil {
 		panic(err)
 	}
 
 	buf := bytes.NewBuffer([]byte{})
 	if err := t.Execute(buf, struct {
-		Ingress *extensionsv1beta1.Ingress
-		Port    int
+		Servers     []nginxServer
+		Port        int
+		TLSPort     int
+		HealthzPort int
+		HealthzPath string
 	}{
-		Ingress: instance,
-		Port:    getNginxServicePort(instance),
+		Servers:     buildNginxServers(instance, upstreams),
+		Port:        getNginxServicePort(instance),
+		TLSPort:     DefaultNginxTLSPort,
+		HealthzPort: DefaultNginxHealthzPort,
+		HealthzPath: DefaultHealthCheckPath,
 	}); err != nil {
 		panic(err)
 	}
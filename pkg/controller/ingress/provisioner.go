@@ -0,0 +1,251 @@
+package ingress
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/cloudformation/cloudformationiface"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+	cfn "github.com/danushkaf/aws-nlb-ingress-controller/pkg/cloudformation"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+)
+
+// Provisioner backends, selected with the --provisioner flag of the manager
+const (
+	ProvisionerCloudFormation = "cloudformation"
+	ProvisionerDirect         = "direct"
+	ProvisionerExport         = "export"
+)
+
+// The capabilities and tags stacks are created with
+var (
+	stackCapabilities = []string{"CAPABILITY_IAM"}
+	stackTags         = map[string]string{"managedBy": "amazon-nlb-ingress-controller"}
+)
+
+// Options configures the controller, the manager sets them from its flags before adding the controller
+type Options struct {
+	// Provisioner is the backend that creates the load balancers
+	Provisioner string
+	// ExportDir is the directory the export provisioner writes the templates to, ConfigMaps are used when it is empty
+	ExportDir string
+	// DefaultBackendService is the namespace/name:port of the service for requests no path of an ingress matches
+	DefaultBackendService string
+}
+
+// Validate checks the options set from the flags
+func (o Options) Validate() error {
+	switch o.Provisioner {
+	case ProvisionerCloudFormation, ProvisionerDirect, ProvisionerExport:
+	default:
+		return fmt.Errorf("unknown provisioner %q, must be %s, %s or %s", o.Provisioner, ProvisionerCloudFormation, ProvisionerDirect, ProvisionerExport)
+	}
+
+	if o.ExportDir != "" && o.Provisioner != ProvisionerExport {
+		return fmt.Errorf("the export directory needs the %s provisioner", ProvisionerExport)
+	}
+
+	if _, err := o.defaultBackend(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// defaultBackend returns the upstream of the default backend service, empty when it is not set
+func (o Options) defaultBackend() (string, error) {
+	if o.DefaultBackendService == "" {
+		return "", nil
+	}
+
+	parts := strings.SplitN(o.DefaultBackendService, "/", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return "", fmt.Errorf("default backend service %q must be of the form namespace/name:port", o.DefaultBackendService)
+	}
+
+	name, port, err := parseServiceAddress(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("invalid default backend service: %s", err)
+	}
+
+	// The proxy runs in the namespace of the ingress, the service is addressed through its namespace
+	return fmt.Sprintf("%s.%s.svc:%d", name, parts[0], port), nil
+}
+
+// ControllerOptions are the options the controller is added with
+var ControllerOptions = Options{
+	Provisioner: ProvisionerCloudFormation,
+}
+
+// updateStatus tells how far an update of the load balancer got
+type updateStatus int
+
+const (
+	// updatePending is a change still being planned, the next reconcile carries on with it
+	updatePending updateStatus = iota
+	// updateWaiting is a change waiting for approval or for the pipeline
+	updateWaiting
+	// updateApplied is a change applied to the load balancer, or no change at all
+	updateApplied
+)
+
+// provisioner creates, updates and deletes the load balancer of an ingress.
+// Both backends describe the load balancer as a stack, with the CloudFormation stack statuses and the outputs of the template,
+// so the reconciler handles them alike.
+type provisioner interface {
+	// describe returns the stack of the ingress, nil when it does not exist
+	describe(instance *extensionsv1beta1.Ingress) (*cloudformation.Stack, error)
+	create(instance *extensionsv1beta1.Ingress, cfg *cfn.TemplateConfig) error
+	// update applies cfg to the existing load balancer
+	update(instance *extensionsv1beta1.Ingress, cfg *cfn.TemplateConfig) (updateStatus, error)
+	delete(instance *extensionsv1beta1.Ingress) error
+	// targetGroupARNs returns the target group of the load balancer and its TLS target group, empty when it has none
+	targetGroupARNs(instance *extensionsv1beta1.Ingress) (string, string, error)
+	// subnetIDs returns the subnets of the load balancer, empty when it does not exist
+	subnetIDs(instance *extensionsv1beta1.Ingress) ([]string, error)
+}
+
+// newProvisioner returns the provisioner backend selected by the options for the reconciler
+func newProvisioner(options Options, r *ReconcileIngress) provisioner {
+	switch options.Provisioner {
+	case ProvisionerDirect:
+		return &directProvisioner{
+			elbv2Svc: r.elbv2Svc,
+			ec2Svc:   r.ec2Svc,
+			log:      r.log,
+		}
+	case ProvisionerExport:
+		return &exportProvisioner{r: r, dir: options.ExportDir}
+	}
+
+	return &cloudFormationProvisioner{r}
+}
+
+// cloudFormationProvisioner manages the load balancer through a CloudFormation stack named after the ingress
+type cloudFormationProvisioner struct {
+	r *ReconcileIngress
+}
+
+func (p *cloudFormationProvisioner) describe(instance *extensionsv1beta1.Ingress) (*cloudformation.Stack, error) {
+	stack, err := cfn.DescribeStack(p.r.cfnSvc, instance.ObjectMeta.Name)
+	if err != nil && cfn.IsDoesNotExist(err, instance.ObjectMeta.Name) {
+		return nil, nil
+	}
+
+	return stack, err
+}
+
+func (p *cloudFormationProvisioner) create(instance *extensionsv1beta1.Ingress, cfg *cfn.TemplateConfig) error {
+	b, err := cfn.BuildNLBTemplateFromIngressRule(cfg).YAML()
+	if err != nil {
+		return err
+	}
+
+	p.r.log.Info("creating cloudformation stack")
+	_, err = p.r.cfnSvc.CreateStack(&cloudformation.CreateStackInput{
+		TemplateBody: aws.String(string(b)),
+		StackName:    aws.String(instance.GetObjectMeta().GetName()),
+		Capabilities: aws.StringSlice(stackCapabilities),
+		Tags:         buildStackTags(),
+	})
+
+	return err
+}
+
+func buildStackTags() []*cloudformation.Tag {
+	tags := []*cloudformation.Tag{}
+	for _, key := range getSortedKeys(stackTags) {
+		tags = append(tags, &cloudformation.Tag{Key: aws.String(key), Value: aws.String(stackTags[key])})
+	}
+
+	return tags
+}
+
+func (p *cloudFormationProvisioner) update(instance *extensionsv1beta1.Ingress, cfg *cfn.TemplateConfig) (updateStatus, error) {
+	b, err := cfn.BuildNLBTemplateFromIngressRule(cfg).YAML()
+	if err != nil {
+		return updatePending, err
+	}
+
+	return p.r.applyChangeSet(instance, b)
+}
+
+// delete deletes the stack, a stack that does not exist is already gone
+func (p *cloudFormationProvisioner) delete(instance *extensionsv1beta1.Ingress) error {
+	_, err := p.r.cfnSvc.DeleteStack(&cloudformation.DeleteStackInput{
+		StackName: aws.String(instance.GetObjectMeta().GetName()),
+	})
+	if cfn.IsDoesNotExist(err, instance.GetObjectMeta().GetName()) {
+		return nil
+	}
+
+	return err
+}
+
+func (p *cloudFormationProvisioner) targetGroupARNs(instance *extensionsv1beta1.Ingress) (string, string, error) {
+	return getStackTargetGroupARNs(p.r.cfnSvc, instance.ObjectMeta.Name)
+}
+
+// getStackTargetGroupARNs returns the target groups of the stack, the TLS target group is empty for stacks without spec.tls
+func getStackTargetGroupARNs(cfnSvc cloudformationiface.CloudFormationAPI, stackName string) (string, string, error) {
+	resourceIDs, err := cfn.GetResourceIDs(cfnSvc, stackName)
+	if err != nil {
+		return "", "", err
+	}
+
+	targetGroupARN, ok := resourceIDs[cfn.TargetGroupResourceName]
+	if !ok {
+		return "", "", fmt.Errorf("resource %s not found", cfn.TargetGroupResourceName)
+	}
+
+	return targetGroupARN, resourceIDs[cfn.TLSTargetGroupResourceName], nil
+}
+
+func (p *cloudFormationProvisioner) subnetIDs(instance *extensionsv1beta1.Ingress) ([]string, error) {
+	return getStackSubnetIDs(p.r.cfnSvc, p.r.elbv2Svc, instance.ObjectMeta.Name)
+}
+
+// getStackSubnetIDs returns the subnets of the load balancer of the stack, empty when the stack or its load balancer does not exist
+func getStackSubnetIDs(cfnSvc cloudformationiface.CloudFormationAPI, elbv2Svc elbv2iface.ELBV2API, stackName string) ([]string, error) {
+	resourceIDs, err := cfn.GetResourceIDs(cfnSvc, stackName)
+	if cfn.IsDoesNotExist(err, stackName) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	loadBalancerARN := resourceIDs[cfn.LoadBalancerResourceName]
+	if loadBalancerARN == "" {
+		return nil, nil
+	}
+
+	out, err := elbv2Svc.DescribeLoadBalancers(&elbv2.DescribeLoadBalancersInput{
+		LoadBalancerArns: aws.StringSlice([]string{loadBalancerARN}),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return loadBalancerSubnetIDs(out.LoadBalancers), nil
+}
+
+// loadBalancerSubnetIDs returns the subnets of the availability zones of the load balancers
+func loadBalancerSubnetIDs(loadBalancers []*elbv2.LoadBalancer) []string {
+	subnetIDs := []string{}
+	for _, lb := range loadBalancers {
+		for _, zone := range lb.AvailabilityZones {
+			subnetIDs = append(subnetIDs, aws.StringValue(zone.SubnetId))
+		}
+	}
+
+	return subnetIDs
+}
+
+// managesStacks tests if the load balancers are CloudFormation stacks, which have events and drift detection
+func (r *ReconcileIngress) managesStacks() bool {
+	_, ok := r.provisioner.(*cloudFormationProvisioner)
+	return ok
+}
@@ -0,0 +1,146 @@
+package ingress
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	cfn "github.com/danushkaf/aws-nlb-ingress-controller/pkg/cloudformation"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Recovery policies for stacks in a failed state
+const (
+	RecoveryPolicyAuto = "auto"
+	RecoveryPolicyNone = "none"
+
+	DefaultRecoveryMaxRetries     = 5
+	DefaultRecoveryBackoffSeconds = 30
+	maxRecoveryBackoff            = time.Hour
+)
+
+// recoveryPolicy controls how often and how fast a failed stack is recovered
+type recoveryPolicy struct {
+	Enabled    bool
+	MaxRetries int
+	Backoff    time.Duration
+}
+
+// delay is the time to wait after the given number of attempts, doubling with every attempt
+func (p *recoveryPolicy) delay(attempts int) time.Duration {
+	delay := p.Backoff
+	for i := 1; i < attempts && delay < maxRecoveryBackoff; i++ {
+		delay *= 2
+	}
+
+	if delay > maxRecoveryBackoff {
+		return maxRecoveryBackoff
+	}
+
+	return delay
+}
+
+// getRecoveryAttempts returns the number and time of the recovery attempts recorded on the ingress
+func getRecoveryAttempts(ingress *extensionsv1beta1.Ingress) (int, time.Time) {
+	attempts, _ := strconv.Atoi(ingress.ObjectMeta.Annotations[IngressAnnotationRecoveryAttempts])
+	last, _ := time.Parse(time.RFC3339, ingress.ObjectMeta.Annotations[IngressAnnotationRecoveryLastAttempt])
+	return attempts, last
+}
+
+// clearRecoveryAttempts resets the recovery attempts once the stack is healthy, returning true when they were set
+func clearRecoveryAttempts(ingress *extensionsv1beta1.Ingress) bool {
+	if _, ok := ingress.ObjectMeta.Annotations[IngressAnnotationRecoveryAttempts]; !ok {
+		return false
+	}
+
+	delete(ingress.ObjectMeta.Annotations, IngressAnnotationRecoveryAttempts)
+	delete(ingress.ObjectMeta.Annotations, IngressAnnotationRecoveryLastAttempt)
+	return true
+}
+
+// recover applies the recovery policy of the ingress to a stack in a failed state.
+// Stacks that failed to create are deleted so they get recreated, failed update rollbacks are continued
+// and failed deletes are retried, at the maximum backoff once the retries are used up.
+func (r *ReconcileIngress) recover(instance *extensionsv1beta1.Ingress, stack *cloudformation.Stack) (reconcile.Result, error) {
+	stackName := instance.ObjectMeta.Name
+	status := aws.StringValue(stack.StackStatus)
+
+	policy, err := getRecoveryPolicy(instance)
+	if err != nil {
+		r.log.Error("invalid recovery policy", zap.Error(err))
+		return reconcile.Result{}, err
+	}
+
+	if r.exportsStacks() {
+		r.log.Info("stack failed and is left to the pipeline", zap.String("stackName", stackName), zap.String("status", status))
+		return reconcile.Result{}, nil
+	}
+
+	if !policy.Enabled {
+		r.log.Info("stack failed and recovery is disabled", zap.String("stackName", stackName), zap.String("status", status))
+		return reconcile.Result{}, nil
+	}
+
+	attempts, last := getRecoveryAttempts(instance)
+	delay := policy.delay(attempts)
+	if attempts >= policy.MaxRetries {
+		if status != cloudformation.StackStatusDeleteFailed {
+			r.log.Error("giving up recovering stack", zap.String("stackName", stackName), zap.String("status", status), zap.Int("attempts", attempts))
+			return reconcile.Result{}, nil
+		}
+
+		// The finalizer holds the deleted ingress until the stack is gone, so failed deletes are never given up
+		delay = maxRecoveryBackoff
+		r.recorder.Event(instance, corev1.EventTypeWarning, "DeleteRetriesExhausted", fmt.Sprintf(
+			"stack %s failed to delete %d times, retrying every %s. Remove the resources that fail to delete or the %s finalizer",
+			stackName, attempts, maxRecoveryBackoff, FinalizerCFNStack))
+	}
+
+	if attempts > 0 {
+		if wait := time.Until(last.Add(delay)); wait > 0 {
+			r.log.Info("waiting to recover stack", zap.String("stackName", stackName), zap.Duration("wait", wait))
+			return reconcile.Result{RequeueAfter: wait}, nil
+		}
+	}
+
+	r.log.Info("recovering stack", zap.String("stackName", stackName), zap.String("status", status), zap.Int("attempt", attempts+1))
+	switch status {
+	case cloudformation.StackStatusUpdateRollbackFailed:
+		if _, err := r.cfnSvc.ContinueUpdateRollback(&cloudformation.ContinueUpdateRollbackInput{
+			StackName: aws.String(stackName),
+		}); err != nil {
+			r.log.Error("unable to continue update rollback", zap.Error(err))
+			return reconcile.Result{}, err
+		}
+	case cloudformation.StackStatusDeleteFailed:
+		if r.managesStacks() {
+			failed, err := cfn.FailedResources(r.cfnSvc, stackName)
+			if err != nil {
+				r.log.Error("unable to list resources that failed to delete", zap.Error(err))
+				return reconcile.Result{}, err
+			}
+
+			r.log.Info("retrying delete", zap.String("stackName", stackName), zap.Strings("failedResources", failed))
+		}
+		fallthrough
+	default:
+		// Stacks that never finished creating are deleted and created again on a later reconcile
+		if err := r.provisioner.delete(instance); err != nil {
+			r.log.Error("unable to delete failed stack", zap.Error(err))
+			return reconcile.Result{}, err
+		}
+	}
+
+	if instance.ObjectMeta.Annotations == nil {
+		instance.ObjectMeta.Annotations = map[string]string{}
+	}
+	instance.ObjectMeta.Annotations[IngressAnnotationRecoveryAttempts] = strconv.Itoa(attempts + 1)
+	instance.ObjectMeta.Annotations[IngressAnnotationRecoveryLastAttempt] = time.Now().UTC().Format(time.RFC3339)
+
+	return reconcile.Result{RequeueAfter: 20 * time.Second}, nil
+}
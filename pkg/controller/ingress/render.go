@@ -0,0 +1,97 @@
+package ingress
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	cfn "github.com/danushkaf/aws-nlb-ingress-controller/pkg/cloudformation"
+	"github.com/danushkaf/aws-nlb-ingress-controller/pkg/network"
+	"go.uber.org/zap"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Rendered is what the controller creates for an ingress
+type Rendered struct {
+	// Template is the CloudFormation template of the stack in YAML
+	Template []byte
+	// NginxConfig is the nginx.conf of the reverse proxy
+	NginxConfig string
+	// Objects are the ConfigMap, Deployment and Service of the reverse proxy
+	Objects []metav1.Object
+}
+
+// Render builds the stack template and reverse proxy resources for the ingress without cluster or AWS access,
+// using the description in place of the worker node lookups
+func Render(instance *extensionsv1beta1.Ingress, description *network.Description, log *zap.Logger) (*Rendered, error) {
+	if err := description.Validate(); err != nil {
+		return nil, err
+	}
+
+	r := &ReconcileIngress{log: log}
+	cfg, err := buildTemplateConfig(instance)
+	if err != nil {
+		return nil, err
+	}
+
+	subnets := description.Ec2Subnets()
+	if annotatedSubnetIds := getAnnotationList(instance, IngressAnnotationSubnets); len(annotatedSubnetIds) > 0 {
+		subnets = filterSubnets(subnets, annotatedSubnetIds)
+	}
+
+	cfg.Network, err = r.buildNetwork(instance, description.Vpc(), subnets, nil, description.InstanceIDs, description.SecurityGroupIDs, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// NodePorts are allocated when the proxy service is created, so they come from the description
+	if getTargetType(instance) == cfn.TargetTypeIP {
+		cfg.NodePort, cfg.HealthCheckNodePort = getNginxServicePort(instance), DefaultNginxHealthzPort
+	} else if description.NodePort == 0 || description.HealthCheckNodePort == 0 {
+		return nil, fmt.Errorf("nodePort and healthCheckNodePort must be set for %s targets", cfn.TargetTypeInstance)
+	} else {
+		cfg.NodePort, cfg.HealthCheckNodePort = description.NodePort, description.HealthCheckNodePort
+	}
+
+	if len(instance.Spec.TLS) > 0 {
+		if getTargetType(instance) == cfn.TargetTypeIP {
+			cfg.TLSNodePort = DefaultNginxTLSPort
+		} else if description.TLSNodePort == 0 {
+			return nil, fmt.Errorf("tlsNodePort must be set for %s targets of an ingress with spec.tls", cfn.TargetTypeInstance)
+		} else {
+			cfg.TLSNodePort = description.TLSNodePort
+		}
+	}
+
+	template, err := cfn.BuildNLBTemplateFromIngressRule(cfg).YAML()
+	if err != nil {
+		return nil, err
+	}
+
+	// Named service ports need the services, their locations answer with 503
+	upstreams := staticUpstreams(instance)
+
+	return &Rendered{
+		Template:    template,
+		NginxConfig: buildNginxConfig(instance, upstreams),
+		Objects:     r.buildReverseProxyResources(instance, upstreams),
+	}, nil
+}
+
+// filterSubnets keeps the subnets with the given IDs
+func filterSubnets(subnets []*ec2.Subnet, subnetIDs []string) []*ec2.Subnet {
+	ids := map[string]bool{}
+	for _, id := range subnetIDs {
+		ids[id] = true
+	}
+
+	filtered := []*ec2.Subnet{}
+	for _, subnet := range subnets {
+		if ids[aws.StringValue(subnet.SubnetId)] {
+			filtered = append(filtered, subnet)
+		}
+	}
+
+	return filtered
+}
@@ -0,0 +1,129 @@
+package ingress
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// ProxyAnnotationTLSChecksum records the checksum of the tls secrets on the proxy pods, a new checksum rolls the pods
+// so nginx loads the new certificates
+const ProxyAnnotationTLSChecksum = "nlb.ingress.kubernetes.io/tls-checksum"
+
+// validateTLS checks the tls section of the ingress. Port 443 of the load balancer either terminates TLS with the
+// certificate-arn certificates or passes it through to the proxy, not both.
+func validateTLS(ingress *extensionsv1beta1.Ingress) error {
+	if len(ingress.Spec.TLS) == 0 {
+		return nil
+	}
+
+	if len(getAnnotationList(ingress, IngressAnnotationCertificateARN)) > 0 {
+		return fmt.Errorf("spec.tls can not be combined with %s, both listen on port 443", IngressAnnotationCertificateARN)
+	}
+
+	for _, tls := range ingress.Spec.TLS {
+		if tls.SecretName == "" {
+			return fmt.Errorf("spec.tls of ingress %s has an entry without a secret", ingress.Name)
+		}
+	}
+
+	return nil
+}
+
+// getTLSChecksum hashes the secrets of the tls section, empty without one. It fails for missing secrets and secrets
+// that are not of type kubernetes.io/tls, the proxy pods could not start with them.
+func (r *ReconcileIngress) getTLSChecksum(instance *extensionsv1beta1.Ingress) (string, error) {
+	secretNames := getTLSSecretNames(instance)
+	if len(secretNames) == 0 {
+		return "", nil
+	}
+
+	hash := sha256.New()
+	for _, secretName := range secretNames {
+		secret := &corev1.Secret{}
+		if err := r.Get(context.TODO(), k8stypes.NamespacedName{Name: secretName, Namespace: instance.Namespace}, secret); err != nil {
+			return "", fmt.Errorf("unable to fetch tls secret %s: %s", secretName, err)
+		}
+
+		if secret.Type != corev1.SecretTypeTLS {
+			return "", fmt.Errorf("secret %s is of type %s, want %s", secretName, secret.Type, corev1.SecretTypeTLS)
+		}
+
+		fmt.Fprintf(hash, "%s\n", secretName)
+		for _, key := range []string{corev1.TLSCertKey, corev1.TLSPrivateKeyKey} {
+			hash.Write(secret.Data[key])
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// setTLSChecksum records the checksum on the pod template of the proxy
+func setTLSChecksum(deploy *appsv1.Deployment, checksum string) {
+	if deploy.Spec.Template.ObjectMeta.Annotations == nil {
+		deploy.Spec.Template.ObjectMeta.Annotations = map[string]string{}
+	}
+
+	deploy.Spec.Template.ObjectMeta.Annotations[ProxyAnnotationTLSChecksum] = checksum
+}
+
+// syncTLSSecrets rolls the proxy pods when the tls secrets changed since they were started
+func (r *ReconcileIngress) syncTLSSecrets(instance *extensionsv1beta1.Ingress) error {
+	checksum, err := r.getTLSChecksum(instance)
+	if err != nil {
+		r.recorder.Event(instance, corev1.EventTypeWarning, "InvalidTLSSecret", err.Error())
+		return err
+	}
+
+	deploy := &appsv1.Deployment{}
+	if err := r.Get(context.TODO(), k8stypes.NamespacedName{Name: createReverseProxyResourceName(instance.Name), Namespace: instance.Namespace}, deploy); err != nil {
+		r.log.Error("unable to fetch proxy deployment", zap.Error(err))
+		return err
+	}
+
+	if deploy.Spec.Template.ObjectMeta.Annotations[ProxyAnnotationTLSChecksum] == checksum {
+		return nil
+	}
+
+	r.log.Info("tls secrets changed, rolling out proxy", zap.String("name", deploy.Name))
+	setTLSChecksum(deploy, checksum)
+	return r.Update(context.TODO(), deploy)
+}
+
+// mapSecretToIngresses returns a handler enqueuing the nlb ingresses of the namespace of a secret that use it
+func mapSecretToIngresses(c client.Client) handler.ToRequestsFunc {
+	return func(o handler.MapObject) []reconcile.Request {
+		ingresses := &extensionsv1beta1.IngressList{}
+		if err := c.List(context.TODO(), ingresses, client.InNamespace(o.Meta.GetNamespace())); err != nil {
+			return nil
+		}
+
+		requests := []reconcile.Request{}
+		for _, ingress := range ingresses.Items {
+			if ingress.Annotations[IngressClassAnnotation] != "nlb" {
+				continue
+			}
+
+			for _, tls := range ingress.Spec.TLS {
+				if tls.SecretName == o.Meta.GetName() {
+					requests = append(requests, reconcile.Request{
+						NamespacedName: k8stypes.NamespacedName{Name: ingress.Name, Namespace: ingress.Namespace},
+					})
+					break
+				}
+			}
+		}
+
+		return requests
+	}
+}
@@ -0,0 +1,106 @@
+package network
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// Description describes the worker node network by hand, standing in for the EC2 lookups when there is no AWS access
+type Description struct {
+	VpcID            string              `json:"vpcId"`
+	VpcCIDRs         []string            `json:"vpcCidrs"`
+	VpcIPv6CIDRs     []string            `json:"vpcIpv6Cidrs,omitempty"`
+	Subnets          []SubnetDescription `json:"subnets"`
+	SecurityGroupIDs []string            `json:"securityGroupIds"`
+	InstanceIDs      []string            `json:"instanceIds"`
+	// NodePort, HealthCheckNodePort and TLSNodePort stand in for the NodePorts Kubernetes allocates to the proxy service
+	NodePort            int `json:"nodePort,omitempty"`
+	HealthCheckNodePort int `json:"healthCheckNodePort,omitempty"`
+	TLSNodePort         int `json:"tlsNodePort,omitempty"`
+}
+
+// SubnetDescription describes a subnet of the vpc, Tags are the keys of the tags set on it
+type SubnetDescription struct {
+	ID               string   `json:"id"`
+	AvailabilityZone string   `json:"availabilityZone"`
+	CIDR             string   `json:"cidr"`
+	IPv6CIDRs        []string `json:"ipv6Cidrs,omitempty"`
+	Tags             []string `json:"tags,omitempty"`
+}
+
+// Validate checks the description has everything the template needs
+func (d *Description) Validate() error {
+	if d.VpcID == "" {
+		return fmt.Errorf("vpcId must be set")
+	}
+
+	if len(d.VpcCIDRs) == 0 {
+		return fmt.Errorf("vpcCidrs must list the CIDR blocks of vpc %s", d.VpcID)
+	}
+
+	if len(d.Subnets) == 0 {
+		return fmt.Errorf("subnets must list the subnets of vpc %s", d.VpcID)
+	}
+
+	if len(d.InstanceIDs) == 0 {
+		return fmt.Errorf("instanceIds must list the worker nodes")
+	}
+
+	return nil
+}
+
+// Vpc returns the vpc as EC2 describes it, the first CIDR block is the primary one
+func (d *Description) Vpc() *ec2.Vpc {
+	vpc := &ec2.Vpc{
+		VpcId: aws.String(d.VpcID),
+	}
+
+	for i, cidr := range d.VpcCIDRs {
+		if i == 0 {
+			vpc.CidrBlock = aws.String(cidr)
+		}
+		vpc.CidrBlockAssociationSet = append(vpc.CidrBlockAssociationSet, &ec2.VpcCidrBlockAssociation{
+			CidrBlock:      aws.String(cidr),
+			CidrBlockState: &ec2.VpcCidrBlockState{State: aws.String(ec2.VpcCidrBlockStateCodeAssociated)},
+		})
+	}
+
+	for _, cidr := range d.VpcIPv6CIDRs {
+		vpc.Ipv6CidrBlockAssociationSet = append(vpc.Ipv6CidrBlockAssociationSet, &ec2.VpcIpv6CidrBlockAssociation{
+			Ipv6CidrBlock:      aws.String(cidr),
+			Ipv6CidrBlockState: &ec2.VpcCidrBlockState{State: aws.String(ec2.VpcCidrBlockStateCodeAssociated)},
+		})
+	}
+
+	return vpc
+}
+
+// Ec2Subnets returns the subnets as EC2 describes them
+func (d *Description) Ec2Subnets() []*ec2.Subnet {
+	subnets := []*ec2.Subnet{}
+	for _, description := range d.Subnets {
+		subnet := &ec2.Subnet{
+			SubnetId:         aws.String(description.ID),
+			VpcId:            aws.String(d.VpcID),
+			AvailabilityZone: aws.String(description.AvailabilityZone),
+			CidrBlock:        aws.String(description.CIDR),
+		}
+
+		for _, cidr := range description.IPv6CIDRs {
+			subnet.Ipv6CidrBlockAssociationSet = append(subnet.Ipv6CidrBlockAssociationSet, &ec2.SubnetIpv6CidrBlockAssociation{
+				Ipv6CidrBlock:      aws.String(cidr),
+				Ipv6CidrBlockState: &ec2.SubnetCidrBlockState{State: aws.String(ec2.SubnetCidrBlockStateCodeAssociated)},
+			})
+		}
+
+		for _, key := range description.Tags {
+			subnet.Tags = append(subnet.Tags, &ec2.Tag{Key: aws.String(key), Value: aws.String("1")})
+		}
+
+		subnets = append(subnets, subnet)
+	}
+
+	return subnets
+}
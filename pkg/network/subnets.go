@@ -0,0 +1,305 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+)
+
+// Load balancer schemes and the subnet role tags used to discover subnets for them
+const (
+	SchemeInternal           = "internal"
+	SchemeInternetFacing     = "internet-facing"
+	TagSubnetRoleELB         = "kubernetes.io/role/elb"
+	TagSubnetRoleInternalELB = "kubernetes.io/role/internal-elb"
+)
+
+// IP address types of the load balancer
+const (
+	IPAddressTypeIPv4      = "ipv4"
+	IPAddressTypeDualStack = "dualstack"
+)
+
+// SubnetChoice is the subnet selected for an Availability Zone and the reason it won
+type SubnetChoice struct {
+	SubnetID         string
+	AvailabilityZone string
+	Reason           string
+	Subnet           *ec2.Subnet
+}
+
+// SubnetMapping pins the load balancer address in a subnet to an Elastic IP or a private IP
+type SubnetMapping struct {
+	SubnetID           string
+	AllocationID       string
+	PrivateIPv4Address string
+}
+
+// SubnetRoleTag returns the subnet role tag for the load balancer scheme
+func SubnetRoleTag(scheme string) string {
+	if scheme == SchemeInternetFacing {
+		return TagSubnetRoleELB
+	}
+
+	return TagSubnetRoleInternalELB
+}
+
+// DescribeSubnets fetches the subnets with the given IDs
+func DescribeSubnets(ec2svc ec2iface.EC2API, subnetIDs []string) ([]*ec2.Subnet, error) {
+	output, err := ec2svc.DescribeSubnets(&ec2.DescribeSubnetsInput{
+		SubnetIds: aws.StringSlice(subnetIDs),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error describing subnets: %s", err)
+	}
+
+	return output.Subnets, nil
+}
+
+// DiscoverSubnets finds the subnets in the VPC tagged for load balancers of the given scheme
+func DiscoverSubnets(ec2svc ec2iface.EC2API, vpcID string, scheme string) ([]*ec2.Subnet, error) {
+	tag := SubnetRoleTag(scheme)
+	output, err := ec2svc.DescribeSubnets(&ec2.DescribeSubnetsInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("vpc-id"),
+				Values: aws.StringSlice([]string{vpcID}),
+			},
+			{
+				Name:   aws.String("tag-key"),
+				Values: aws.StringSlice([]string{tag}),
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error describing subnets: %s", err)
+	}
+
+	if len(output.Subnets) == 0 {
+		return nil, fmt.Errorf("no subnets tagged %s found in vpc %s", tag, vpcID)
+	}
+
+	return output.Subnets, nil
+}
+
+func hasTag(subnet *ec2.Subnet, key string) bool {
+	for _, tag := range subnet.Tags {
+		if aws.StringValue(tag.Key) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// SelectSubnets picks one subnet per Availability Zone, since an NLB accepts at most one subnet in each zone.
+// Within a zone subnets tagged with roleTag win, then the subnet in inUse, the subnets of the existing load balancer,
+// then the subnet with the most free IP addresses, then the lowest subnet ID. Free IP addresses change over time,
+// preferring the subnets in use keeps the load balancer in its subnets across reconciles.
+// The choices are sorted by Availability Zone so the result is stable across reconciles.
+func SelectSubnets(vpcID string, subnets []*ec2.Subnet, roleTag string, inUse []string) ([]SubnetChoice, error) {
+	used := map[string]bool{}
+	for _, subnetID := range inUse {
+		used[subnetID] = true
+	}
+
+	zones := map[string][]*ec2.Subnet{}
+	for _, subnet := range subnets {
+		if aws.StringValue(subnet.VpcId) != vpcID {
+			return nil, fmt.Errorf("subnet %s is in vpc %s, expected %s", aws.StringValue(subnet.SubnetId), aws.StringValue(subnet.VpcId), vpcID)
+		}
+
+		zone := aws.StringValue(subnet.AvailabilityZone)
+		zones[zone] = append(zones[zone], subnet)
+	}
+
+	if len(zones) == 0 {
+		return nil, fmt.Errorf("no subnets found in vpc %s", vpcID)
+	}
+
+	choices := []SubnetChoice{}
+	for zone, candidates := range zones {
+		sort.Slice(candidates, func(i, j int) bool {
+			a, b := candidates[i], candidates[j]
+			if hasTag(a, roleTag) != hasTag(b, roleTag) {
+				return hasTag(a, roleTag)
+			}
+			if used[aws.StringValue(a.SubnetId)] != used[aws.StringValue(b.SubnetId)] {
+				return used[aws.StringValue(a.SubnetId)]
+			}
+			if aws.Int64Value(a.AvailableIpAddressCount) != aws.Int64Value(b.AvailableIpAddressCount) {
+				return aws.Int64Value(a.AvailableIpAddressCount) > aws.Int64Value(b.AvailableIpAddressCount)
+			}
+			return aws.StringValue(a.SubnetId) < aws.StringValue(b.SubnetId)
+		})
+
+		choices = append(choices, SubnetChoice{
+			SubnetID:         aws.StringValue(candidates[0].SubnetId),
+			AvailabilityZone: zone,
+			Reason:           selectionReason(candidates, roleTag, used),
+			Subnet:           candidates[0],
+		})
+	}
+
+	sort.Slice(choices, func(i, j int) bool {
+		return choices[i].AvailabilityZone < choices[j].AvailabilityZone
+	})
+
+	return choices, nil
+}
+
+// selectionReason explains why the first of the sorted candidates was chosen
+func selectionReason(candidates []*ec2.Subnet, roleTag string, used map[string]bool) string {
+	if len(candidates) == 1 {
+		return "only candidate in availability zone"
+	}
+
+	first, second := candidates[0], candidates[1]
+	if hasTag(first, roleTag) != hasTag(second, roleTag) {
+		return fmt.Sprintf("tagged %s", roleTag)
+	}
+	if used[aws.StringValue(first.SubnetId)] != used[aws.StringValue(second.SubnetId)] {
+		return "used by the load balancer"
+	}
+	if aws.Int64Value(first.AvailableIpAddressCount) != aws.Int64Value(second.AvailableIpAddressCount) {
+		return fmt.Sprintf("most available IP addresses (%d)", aws.Int64Value(first.AvailableIpAddressCount))
+	}
+	return "lowest subnet ID"
+}
+
+// PairSubnetMappings pairs the Elastic IP allocations and private IPv4 addresses with the selected subnets.
+// Allocations are paired with the subnets in Availability Zone order, private addresses with the subnet whose CIDR contains them.
+// Each list that is set must have exactly one entry per selected Availability Zone.
+func PairSubnetMappings(choices []SubnetChoice, allocationIDs []string, privateIPv4Addresses []string) ([]SubnetMapping, error) {
+	if len(allocationIDs) == 0 && len(privateIPv4Addresses) == 0 {
+		return nil, nil
+	}
+
+	if len(allocationIDs) > 0 && len(allocationIDs) != len(choices) {
+		return nil, fmt.Errorf("got %d elastic ip allocations for %d availability zones %s, need one per availability zone", len(allocationIDs), len(choices), choiceZones(choices))
+	}
+
+	if len(privateIPv4Addresses) > 0 && len(privateIPv4Addresses) != len(choices) {
+		return nil, fmt.Errorf("got %d private ipv4 addresses for %d availability zones %s, need one per availability zone", len(privateIPv4Addresses), len(choices), choiceZones(choices))
+	}
+
+	mappings := make([]SubnetMapping, len(choices))
+	for i, choice := range choices {
+		mappings[i].SubnetID = choice.SubnetID
+		if len(allocationIDs) > 0 {
+			mappings[i].AllocationID = allocationIDs[i]
+		}
+	}
+
+	for _, address := range privateIPv4Addresses {
+		ip := net.ParseIP(address)
+		if ip == nil || ip.To4() == nil {
+			return nil, fmt.Errorf("%q is not an ipv4 address", address)
+		}
+
+		i, err := subnetForIP(choices, ip)
+		if err != nil {
+			return nil, err
+		}
+
+		if mappings[i].PrivateIPv4Address != "" {
+			return nil, fmt.Errorf("private ipv4 addresses %s and %s are both in subnet %s, need one per availability zone", mappings[i].PrivateIPv4Address, address, choices[i].SubnetID)
+		}
+		mappings[i].PrivateIPv4Address = address
+	}
+
+	return mappings, nil
+}
+
+// subnetForIP returns the index of the selected subnet whose CIDR contains ip
+func subnetForIP(choices []SubnetChoice, ip net.IP) (int, error) {
+	for i, choice := range choices {
+		if choice.Subnet == nil {
+			continue
+		}
+
+		_, cidr, err := net.ParseCIDR(aws.StringValue(choice.Subnet.CidrBlock))
+		if err == nil && cidr.Contains(ip) {
+			return i, nil
+		}
+	}
+
+	return 0, fmt.Errorf("private ipv4 address %s is not in any of the selected subnets %s", ip, choiceSubnetIDs(choices))
+}
+
+func choiceZones(choices []SubnetChoice) []string {
+	zones := make([]string, len(choices))
+	for i, choice := range choices {
+		zones[i] = choice.AvailabilityZone
+	}
+	return zones
+}
+
+func choiceSubnetIDs(choices []SubnetChoice) []string {
+	ids := make([]string, len(choices))
+	for i, choice := range choices {
+		ids[i] = choice.SubnetID
+	}
+	return ids
+}
+
+// SubnetIPv6CidrBlocks returns the IPv6 CIDR blocks associated with the subnet
+func SubnetIPv6CidrBlocks(subnet *ec2.Subnet) []string {
+	cidrs := []string{}
+	for _, association := range subnet.Ipv6CidrBlockAssociationSet {
+		if association.Ipv6CidrBlockState != nil && aws.StringValue(association.Ipv6CidrBlockState.State) == ec2.SubnetCidrBlockStateCodeAssociated {
+			cidrs = append(cidrs, aws.StringValue(association.Ipv6CidrBlock))
+		}
+	}
+	return cidrs
+}
+
+// VpcCidrBlocks returns the IPv4 CIDR blocks associated with the vpc, the primary block first
+func VpcCidrBlocks(vpc *ec2.Vpc) []string {
+	cidrs := []string{}
+	if vpc.CidrBlock != nil {
+		cidrs = append(cidrs, aws.StringValue(vpc.CidrBlock))
+	}
+
+	for _, association := range vpc.CidrBlockAssociationSet {
+		cidr := aws.StringValue(association.CidrBlock)
+		if cidr == aws.StringValue(vpc.CidrBlock) {
+			continue
+		}
+
+		if association.CidrBlockState != nil && aws.StringValue(association.CidrBlockState.State) == ec2.VpcCidrBlockStateCodeAssociated {
+			cidrs = append(cidrs, cidr)
+		}
+	}
+	return cidrs
+}
+
+// VpcIPv6CidrBlocks returns the IPv6 CIDR blocks associated with the vpc
+func VpcIPv6CidrBlocks(vpc *ec2.Vpc) []string {
+	cidrs := []string{}
+	for _, association := range vpc.Ipv6CidrBlockAssociationSet {
+		if association.Ipv6CidrBlockState != nil && aws.StringValue(association.Ipv6CidrBlockState.State) == ec2.VpcCidrBlockStateCodeAssociated {
+			cidrs = append(cidrs, aws.StringValue(association.Ipv6CidrBlock))
+		}
+	}
+	return cidrs
+}
+
+// RequireIPv6 checks every selected subnet has an IPv6 CIDR block, which dualstack load balancers need
+func RequireIPv6(choices []SubnetChoice) error {
+	missing := []string{}
+	for _, choice := range choices {
+		if choice.Subnet == nil || len(SubnetIPv6CidrBlocks(choice.Subnet)) == 0 {
+			missing = append(missing, choice.SubnetID)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("dualstack load balancers need subnets with an ipv6 cidr block, subnets %v have none", missing)
+	}
+
+	return nil
+}
@@ -0,0 +1,272 @@
+package network
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func newSubnet(id, zone string, freeIPs int64, tags ...string) *ec2.Subnet {
+	subnet := &ec2.Subnet{
+		SubnetId:                aws.String(id),
+		VpcId:                   aws.String("vpc-foo"),
+		AvailabilityZone:        aws.String(zone),
+		AvailableIpAddressCount: aws.Int64(freeIPs),
+	}
+	for _, tag := range tags {
+		subnet.Tags = append(subnet.Tags, &ec2.Tag{Key: aws.String(tag), Value: aws.String("1")})
+	}
+	return subnet
+}
+
+func TestSelectSubnets(t *testing.T) {
+	tests := []struct {
+		name    string
+		subnets []*ec2.Subnet
+		inUse   []string
+		want    map[string]string
+		reasons map[string]string
+		wantErr bool
+	}{
+		{
+			name: "one subnet per zone",
+			subnets: []*ec2.Subnet{
+				newSubnet("subnet-b", "us-west-2b", 10),
+				newSubnet("subnet-a", "us-west-2a", 10),
+			},
+			want:    map[string]string{"us-west-2a": "subnet-a", "us-west-2b": "subnet-b"},
+			reasons: map[string]string{"us-west-2a": "only candidate in availability zone", "us-west-2b": "only candidate in availability zone"},
+		},
+		{
+			name: "prefers tagged subnets over free IPs",
+			subnets: []*ec2.Subnet{
+				newSubnet("subnet-a", "us-west-2a", 1000),
+				newSubnet("subnet-b", "us-west-2a", 10, TagSubnetRoleInternalELB),
+			},
+			want:    map[string]string{"us-west-2a": "subnet-b"},
+			reasons: map[string]string{"us-west-2a": "tagged kubernetes.io/role/internal-elb"},
+		},
+		{
+			name: "prefers most free IPs",
+			subnets: []*ec2.Subnet{
+				newSubnet("subnet-a", "us-west-2a", 10),
+				newSubnet("subnet-b", "us-west-2a", 20),
+			},
+			want:    map[string]string{"us-west-2a": "subnet-b"},
+			reasons: map[string]string{"us-west-2a": "most available IP addresses (20)"},
+		},
+		{
+			name: "prefers the subnet in use over free IPs",
+			subnets: []*ec2.Subnet{
+				newSubnet("subnet-a", "us-west-2a", 10),
+				newSubnet("subnet-b", "us-west-2a", 20),
+			},
+			inUse:   []string{"subnet-a"},
+			want:    map[string]string{"us-west-2a": "subnet-a"},
+			reasons: map[string]string{"us-west-2a": "used by the load balancer"},
+		},
+		{
+			name: "prefers tagged subnets over the subnet in use",
+			subnets: []*ec2.Subnet{
+				newSubnet("subnet-a", "us-west-2a", 10),
+				newSubnet("subnet-b", "us-west-2a", 10, TagSubnetRoleInternalELB),
+			},
+			inUse:   []string{"subnet-a"},
+			want:    map[string]string{"us-west-2a": "subnet-b"},
+			reasons: map[string]string{"us-west-2a": "tagged kubernetes.io/role/internal-elb"},
+		},
+		{
+			name: "falls back to lowest subnet ID",
+			subnets: []*ec2.Subnet{
+				newSubnet("subnet-b", "us-west-2a", 10),
+				newSubnet("subnet-a", "us-west-2a", 10),
+			},
+			want:    map[string]string{"us-west-2a": "subnet-a"},
+			reasons: map[string]string{"us-west-2a": "lowest subnet ID"},
+		},
+		{
+			name: "rejects subnets from another vpc",
+			subnets: []*ec2.Subnet{
+				newSubnet("subnet-a", "us-west-2a", 10),
+				&ec2.Subnet{SubnetId: aws.String("subnet-b"), VpcId: aws.String("vpc-bar"), AvailabilityZone: aws.String("us-west-2b")},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "fails without subnets",
+			subnets: []*ec2.Subnet{},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SelectSubnets("vpc-foo", tt.subnets, TagSubnetRoleInternalELB, tt.inUse)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SelectSubnets() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			gotSubnets := map[string]string{}
+			gotReasons := map[string]string{}
+			for i, choice := range got {
+				if i > 0 && got[i-1].AvailabilityZone >= choice.AvailabilityZone {
+					t.Errorf("SelectSubnets() choices not sorted by zone: %v", got)
+				}
+				gotSubnets[choice.AvailabilityZone] = choice.SubnetID
+				gotReasons[choice.AvailabilityZone] = choice.Reason
+			}
+			if !reflect.DeepEqual(gotSubnets, tt.want) {
+				t.Errorf("SelectSubnets() = %v, want %v", gotSubnets, tt.want)
+			}
+			if !reflect.DeepEqual(gotReasons, tt.reasons) {
+				t.Errorf("SelectSubnets() reasons = %v, want %v", gotReasons, tt.reasons)
+			}
+		})
+	}
+}
+
+func TestPairSubnetMappings(t *testing.T) {
+	choices := []SubnetChoice{
+		{SubnetID: "subnet-a", AvailabilityZone: "us-west-2a", Subnet: &ec2.Subnet{CidrBlock: aws.String("10.0.0.0/24")}},
+		{SubnetID: "subnet-b", AvailabilityZone: "us-west-2b", Subnet: &ec2.Subnet{CidrBlock: aws.String("10.0.1.0/24")}},
+	}
+
+	tests := []struct {
+		name                 string
+		allocationIDs        []string
+		privateIPv4Addresses []string
+		want                 []SubnetMapping
+		wantErr              bool
+	}{
+		{
+			name: "no addresses keeps plain subnets",
+		},
+		{
+			name:          "pairs allocations in zone order",
+			allocationIDs: []string{"eipalloc-a", "eipalloc-b"},
+			want: []SubnetMapping{
+				{SubnetID: "subnet-a", AllocationID: "eipalloc-a"},
+				{SubnetID: "subnet-b", AllocationID: "eipalloc-b"},
+			},
+		},
+		{
+			name:                 "pairs private addresses with the subnet containing them",
+			privateIPv4Addresses: []string{"10.0.1.10", "10.0.0.10"},
+			want: []SubnetMapping{
+				{SubnetID: "subnet-a", PrivateIPv4Address: "10.0.0.10"},
+				{SubnetID: "subnet-b", PrivateIPv4Address: "10.0.1.10"},
+			},
+		},
+		{
+			name:          "rejects allocation count mismatch",
+			allocationIDs: []string{"eipalloc-a"},
+			wantErr:       true,
+		},
+		{
+			name:                 "rejects private address count mismatch",
+			privateIPv4Addresses: []string{"10.0.0.10", "10.0.1.10", "10.0.1.11"},
+			wantErr:              true,
+		},
+		{
+			name:                 "rejects two private addresses in one subnet",
+			privateIPv4Addresses: []string{"10.0.0.10", "10.0.0.11"},
+			wantErr:              true,
+		},
+		{
+			name:                 "rejects private address outside the subnets",
+			privateIPv4Addresses: []string{"10.0.0.10", "10.0.2.10"},
+			wantErr:              true,
+		},
+		{
+			name:                 "rejects invalid private address",
+			privateIPv4Addresses: []string{"10.0.0.10", "foo"},
+			wantErr:              true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PairSubnetMappings(choices, tt.allocationIDs, tt.privateIPv4Addresses)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PairSubnetMappings() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PairSubnetMappings() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireIPv6(t *testing.T) {
+	ipv6Subnet := func(id, state string) SubnetChoice {
+		return SubnetChoice{
+			SubnetID: id,
+			Subnet: &ec2.Subnet{
+				SubnetId: aws.String(id),
+				Ipv6CidrBlockAssociationSet: []*ec2.SubnetIpv6CidrBlockAssociation{
+					{
+						Ipv6CidrBlock:      aws.String("2600:1f14::/64"),
+						Ipv6CidrBlockState: &ec2.SubnetCidrBlockState{State: aws.String(state)},
+					},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		choices []SubnetChoice
+		wantErr bool
+	}{
+		{
+			name:    "all subnets have ipv6",
+			choices: []SubnetChoice{ipv6Subnet("subnet-a", "associated"), ipv6Subnet("subnet-b", "associated")},
+		},
+		{
+			name:    "subnet without ipv6",
+			choices: []SubnetChoice{ipv6Subnet("subnet-a", "associated"), {SubnetID: "subnet-b", Subnet: newSubnet("subnet-b", "us-west-2b", 10)}},
+			wantErr: true,
+		},
+		{
+			name:    "ipv6 block still associating",
+			choices: []SubnetChoice{ipv6Subnet("subnet-a", "associating")},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := RequireIPv6(tt.choices); (err != nil) != tt.wantErr {
+				t.Errorf("RequireIPv6() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVpcCidrBlocks(t *testing.T) {
+	association := func(cidr, state string) *ec2.VpcCidrBlockAssociation {
+		return &ec2.VpcCidrBlockAssociation{
+			CidrBlock:      aws.String(cidr),
+			CidrBlockState: &ec2.VpcCidrBlockState{State: aws.String(state)},
+		}
+	}
+
+	vpc := &ec2.Vpc{
+		CidrBlock: aws.String("10.0.0.0/16"),
+		CidrBlockAssociationSet: []*ec2.VpcCidrBlockAssociation{
+			association("10.0.0.0/16", "associated"),
+			association("100.64.0.0/16", "associated"),
+			association("100.65.0.0/16", "disassociated"),
+		},
+	}
+
+	want := []string{"10.0.0.0/16", "100.64.0.0/16"}
+	if got := VpcCidrBlocks(vpc); !reflect.DeepEqual(got, want) {
+		t.Errorf("VpcCidrBlocks() = %v, want %v", got, want)
+	}
+}
@@ -15,6 +15,8 @@ type Network struct {
 	InstanceIDs      []string
 	SecurityGroupIDs []string
 	SubnetIDs        []string
+	Subnets          []SubnetChoice
+	SubnetMappings   []SubnetMapping
 	ASGNames         []string
 	Vpc              *ec2.Vpc
 }